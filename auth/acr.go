@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInsufficientACR is given to the forbidden handler by RequireACR when the
+// authentication context class (acr) of the token is below the minimum.
+var ErrInsufficientACR = errors.New("insufficient authentication assurance level")
+
+// WithACRLevels sets the authentication context class (acr) values known to
+// RequireACR, ordered from the lowest assurance level to the highest, like
+// []string{"low", "medium", "high"}.
+func WithACRLevels(levels []string) Option {
+	return func(a *Auth) {
+		a.acrLevels = append([]string(nil), levels...)
+	}
+}
+
+// acrRank returns the position of the level in the configured ordering, or
+// -1 when the level isn't known.
+func (a *Auth) acrRank(level string) int {
+	for i, l := range a.acrLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// RequireACR returns middleware that only lets requests through when the
+// acr claim in the request context is at least minLevel in the ordering set
+// by WithACRLevels. It must run after Authenticate. Requests without Claims
+// are handed to the unauthorized handler with ErrNoClaims and requests with
+// a lower or unknown acr are handed to the forbidden handler with
+// ErrInsufficientACR. When minLevel isn't one of the configured levels no
+// acr can satisfy it, so every request with Claims is handed to the forbidden
+// handler with ErrInsufficientACR.
+func (a *Auth) RequireACR(minLevel string) func(http.Handler) http.Handler {
+	min := a.acrRank(minLevel)
+
+	m := func(next http.Handler) http.Handler {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := FromContext(r.Context())
+			if !ok {
+				a.unauthorized(w, r, ErrNoClaims)
+				return
+			}
+
+			if min < 0 {
+				a.forbidden(w, r, errors.Wrapf(ErrInsufficientACR, "acr level %q not configured", minLevel))
+				return
+			}
+
+			if a.acrRank(claims.ACR) < min {
+				a.forbidden(w, r, ErrInsufficientACR)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(h)
+	}
+
+	return m
+}
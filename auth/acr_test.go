@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRequireACR(t *testing.T) {
+	tests := []struct {
+		name     string
+		levels   []string
+		minLevel string
+		acr      string
+		token    bool
+		status   int
+	}{
+		{name: "equal", levels: []string{"low", "high"}, minLevel: "low", acr: "low", token: true, status: http.StatusOK},
+		{name: "higher", levels: []string{"low", "high"}, minLevel: "low", acr: "high", token: true, status: http.StatusOK},
+		{name: "lower", levels: []string{"low", "high"}, minLevel: "high", acr: "low", token: true, status: http.StatusForbidden},
+		{name: "unknown acr", levels: []string{"low", "high"}, minLevel: "low", acr: "other", token: true, status: http.StatusForbidden},
+		{name: "no acr", levels: []string{"low", "high"}, minLevel: "low", token: true, status: http.StatusForbidden},
+		{name: "unconfigured level", levels: []string{"low", "high"}, minLevel: "max", acr: "high", token: true, status: http.StatusForbidden},
+		{name: "no levels", minLevel: "low", acr: "low", token: true, status: http.StatusForbidden},
+		{name: "no claims", levels: []string{"low"}, minLevel: "low", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got error
+			record := func(w http.ResponseWriter, r *http.Request, err error) {
+				got = err
+				WriteErrorResponse(w, http.StatusForbidden, err)
+			}
+			a := newTestAuth(t, WithACRLevels(tt.levels), WithForbiddenHandler(record))
+
+			next := &okHandler{}
+			h := a.RequireACR(tt.minLevel)(next)
+
+			tokenStr := ""
+			if tt.token {
+				claims := newClaims("user", RoleUser)
+				claims.ACR = tt.acr
+				tokenStr = mustGenerate(t, a, claims)
+				h = a.Authenticate(h)
+			}
+
+			if status := serveToken(t, h, tokenStr); status != tt.status {
+				t.Fatalf("status is %d, want %d", status, tt.status)
+			}
+			if tt.status == http.StatusForbidden && !errors.Is(got, ErrInsufficientACR) {
+				t.Errorf("forbidden error is %v, want %v", got, ErrInsufficientACR)
+			}
+			if tt.status == http.StatusOK && next.claims.ACR != tt.acr {
+				t.Errorf("acr is %q, want %q", next.claims.ACR, tt.acr)
+			}
+		})
+	}
+}
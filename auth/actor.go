@@ -0,0 +1,14 @@
+package auth
+
+// Impersonator returns the claims of the operator acting on behalf of the
+// subject when the token represents an impersonated session. The bool is
+// false for regular tokens.
+//
+// Authorization helpers such as Authorized only evaluate the outer claims,
+// which belong to the impersonated user. The actor is carried for auditing.
+func (c Claims) Impersonator() (Claims, bool) {
+	if c.Actor == nil {
+		return Claims{}, false
+	}
+	return *c.Actor, true
+}
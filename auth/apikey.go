@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// APIKeyPrefix starts every API key so keys are recognizable, for example by
+// secret scanners, and distinguishable from JWTs.
+const APIKeyPrefix = "wfk_"
+
+// apiKeyBytes is the amount of randomness in an API key.
+const apiKeyBytes = 32
+
+// ErrUnknownAPIKey is returned when an API key isn't in the KeyStore.
+var ErrUnknownAPIKey = errors.New("unknown api key")
+
+// KeyStore persists the claims of API keys. The key is the SHA-256 hash of
+// the API key, never the API key itself, so a leaked store doesn't leak
+// usable keys. API keys don't expire, they live until revoked.
+type KeyStore interface {
+	Put(hash string, claims Claims) error
+	Get(hash string) (Claims, error)
+	Delete(hash string) error
+}
+
+// WithKeyStore configures the store backing API keys.
+func WithKeyStore(s KeyStore) Option {
+	return func(a *Auth) {
+		a.apiKeys = s
+	}
+}
+
+// GenerateAPIKey stores the claims in the KeyStore and returns a new random
+// API key for them. The roles and scope of the claims apply to every request
+// made with the key. The key is only returned here, it can't be recovered
+// from the store.
+func (a *Auth) GenerateAPIKey(claims Claims) (string, error) {
+	if a.apiKeys == nil {
+		return "", errors.New("key store not configured")
+	}
+
+	b := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating api key")
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	if claims.Id == "" {
+		id, err := a.tokenID()
+		if err != nil {
+			return "", errors.Wrap(err, "generating token id")
+		}
+		claims.Id = id
+	}
+	claims.IssuedAt = a.clock().Unix()
+	claims.ExpiresAt = 0
+
+	if err := a.apiKeys.Put(hashToken(key), claims); err != nil {
+		return "", errors.Wrap(err, "storing api key")
+	}
+
+	return key, nil
+}
+
+// ValidateAPIKey looks up the claims of the API key. The checks configured for
+// tokens, such as the Revoker, apply to API keys too. Errors are returned as
+// a *ValidationError.
+func (a *Auth) ValidateAPIKey(key string) (Claims, error) {
+	if a.apiKeys == nil {
+		return Claims{}, newValidationError(errors.New("key store not configured"))
+	}
+
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return Claims{}, newValidationError(ErrTokenMalformed)
+	}
+
+	claims, err := a.apiKeys.Get(hashToken(key))
+	if err != nil {
+		return Claims{}, newValidationError(errors.Wrap(err, "looking up api key"))
+	}
+
+	if err := a.checkPolicies(claims); err != nil {
+		return Claims{}, newValidationError(err)
+	}
+
+	return claims, nil
+}
+
+// RotateAPIKey replaces the API key with a new one for the same claims. The
+// old key stops working immediately.
+func (a *Auth) RotateAPIKey(key string) (string, error) {
+	claims, err := a.ValidateAPIKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	claims.Id = ""
+	next, err := a.GenerateAPIKey(claims)
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.apiKeys.Delete(hashToken(key)); err != nil {
+		return "", errors.Wrap(err, "deleting api key")
+	}
+
+	return next, nil
+}
+
+// RevokeAPIKey removes the API key from the KeyStore.
+func (a *Auth) RevokeAPIKey(key string) error {
+	if a.apiKeys == nil {
+		return errors.New("key store not configured")
+	}
+
+	if err := a.apiKeys.Delete(hashToken(key)); err != nil {
+		return errors.Wrap(err, "deleting api key")
+	}
+
+	return nil
+}
+
+// MemoryKeyStore is an in-memory KeyStore. It is safe for concurrent use.
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]Claims
+}
+
+// NewMemoryKeyStore constructs an empty MemoryKeyStore.
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{
+		keys: make(map[string]Claims),
+	}
+}
+
+// Put implements the KeyStore interface.
+func (m *MemoryKeyStore) Put(hash string, claims Claims) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys[hash] = cloneClaims(claims)
+
+	return nil
+}
+
+// Get implements the KeyStore interface.
+func (m *MemoryKeyStore) Get(hash string) (Claims, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	claims, exists := m.keys[hash]
+	if !exists {
+		return Claims{}, ErrUnknownAPIKey
+	}
+
+	return cloneClaims(claims), nil
+}
+
+// Delete implements the KeyStore interface.
+func (m *MemoryKeyStore) Delete(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.keys, hash)
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestAPIKey(t *testing.T) {
+	store := NewMemoryKeyStore()
+	a := newTestAuth(t, WithKeyStore(store))
+
+	key, err := a.GenerateAPIKey(newClaims("service", RoleUser))
+	if err != nil {
+		t.Fatalf("generating api key: %v", err)
+	}
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		t.Errorf("key %q doesn't start with %q", key, APIKeyPrefix)
+	}
+
+	if _, exists := store.keys[key]; exists {
+		t.Error("store holds the api key itself")
+	}
+	if _, exists := store.keys[hashToken(key)]; !exists {
+		t.Error("store doesn't hold the hash of the api key")
+	}
+
+	claims, err := a.ValidateAPIKey(key)
+	if err != nil {
+		t.Fatalf("validating api key: %v", err)
+	}
+	if claims.Subject != "service" || !reflect.DeepEqual(claims.Roles, []string{RoleUser}) {
+		t.Errorf("claims are %+v, want the service with the USER role", claims)
+	}
+	if claims.Id == "" {
+		t.Error("claims have no id")
+	}
+	if claims.IssuedAt != testNow.Unix() || claims.ExpiresAt != 0 {
+		t.Errorf("iat is %d and exp is %d, want %d and none", claims.IssuedAt, claims.ExpiresAt, testNow.Unix())
+	}
+}
+
+func TestValidateAPIKeyErrors(t *testing.T) {
+	a := newTestAuth(t, WithKeyStore(NewMemoryKeyStore()))
+
+	tests := []struct {
+		name string
+		key  string
+		err  error
+	}{
+		{name: "unknown", key: APIKeyPrefix + "unknown", err: ErrUnknownAPIKey},
+		{name: "no prefix", key: "unknown", err: ErrTokenMalformed},
+		{name: "jwt", key: mustGenerate(t, a, newClaims("user")), err: ErrTokenMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.ValidateAPIKey(tt.key)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err is %v, want %v", err, tt.err)
+			}
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Errorf("error is %T, want *ValidationError", err)
+			}
+		})
+	}
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	a := newTestAuth(t, WithKeyStore(NewMemoryKeyStore()))
+
+	key, err := a.GenerateAPIKey(newClaims("service", RoleUser))
+	if err != nil {
+		t.Fatalf("generating api key: %v", err)
+	}
+	old, err := a.ValidateAPIKey(key)
+	if err != nil {
+		t.Fatalf("validating api key: %v", err)
+	}
+
+	next, err := a.RotateAPIKey(key)
+	if err != nil {
+		t.Fatalf("rotating api key: %v", err)
+	}
+	if next == key {
+		t.Fatal("rotated key is the old key")
+	}
+
+	if _, err := a.ValidateAPIKey(key); !errors.Is(err, ErrUnknownAPIKey) {
+		t.Errorf("old key err is %v, want %v", err, ErrUnknownAPIKey)
+	}
+
+	claims, err := a.ValidateAPIKey(next)
+	if err != nil {
+		t.Fatalf("validating rotated api key: %v", err)
+	}
+	if claims.Subject != old.Subject || !reflect.DeepEqual(claims.Roles, old.Roles) {
+		t.Errorf("claims are %+v, want %+v", claims, old)
+	}
+	if claims.Id == old.Id {
+		t.Error("rotated key kept the old id")
+	}
+
+	if _, err := a.RotateAPIKey(key); !errors.Is(err, ErrUnknownAPIKey) {
+		t.Errorf("rotating old key err is %v, want %v", err, ErrUnknownAPIKey)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	revoker := NewMemoryRevoker()
+	a := newTestAuth(t, WithKeyStore(NewMemoryKeyStore()), WithRevoker(revoker))
+
+	key, err := a.GenerateAPIKey(newClaims("service"))
+	if err != nil {
+		t.Fatalf("generating api key: %v", err)
+	}
+	if err := a.RevokeAPIKey(key); err != nil {
+		t.Fatalf("revoking api key: %v", err)
+	}
+	if _, err := a.ValidateAPIKey(key); !errors.Is(err, ErrUnknownAPIKey) {
+		t.Errorf("err is %v, want %v", err, ErrUnknownAPIKey)
+	}
+
+	// The Revoker configured for tokens applies to the id of the key too.
+	key, err = a.GenerateAPIKey(newClaims("service"))
+	if err != nil {
+		t.Fatalf("generating api key: %v", err)
+	}
+	claims, err := a.ValidateAPIKey(key)
+	if err != nil {
+		t.Fatalf("validating api key: %v", err)
+	}
+	if err := revoker.Revoke(claims.Id, testNow.Add(time.Hour)); err != nil {
+		t.Fatalf("revoking id: %v", err)
+	}
+	if _, err := a.ValidateAPIKey(key); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("err is %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestAPIKeyWithoutStore(t *testing.T) {
+	a := newTestAuth(t)
+
+	if _, err := a.GenerateAPIKey(newClaims("service")); err == nil {
+		t.Error("generated an api key without a store")
+	}
+	if _, err := a.ValidateAPIKey(APIKeyPrefix + "key"); err == nil {
+		t.Error("validated an api key without a store")
+	}
+	if err := a.RevokeAPIKey(APIKeyPrefix + "key"); err == nil {
+		t.Error("revoked an api key without a store")
+	}
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"time"
+)
+
+// These are the values for AuditEvent.Outcome.
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEvent describes a token issued or validated. The raw token is never
+// included. The username and email are only set when the Auth is configured
+// with WithAuditUserInfo.
+type AuditEvent struct {
+	Time      time.Time
+	Outcome   string
+	TokenID   string
+	Subject   string
+	TokenType string
+
+	// Reason is the Code of the Reason a token was rejected, like
+	// "token_expired", and empty on success. Failed issuance uses
+	// "invalid_token".
+	Reason string
+
+	UserName string
+	Email    string
+}
+
+// AuditSink receives an AuditEvent for every token decision. The methods are
+// called synchronously, on the goroutine generating or validating the token,
+// so they must be fast. A sink writing to a slow destination should buffer
+// the events itself. The auth/slogaudit package provides a sink logging with
+// log/slog.
+type AuditSink interface {
+	// OnIssue is called each time GenerateToken signs, or fails to sign, a
+	// token.
+	OnIssue(e AuditEvent)
+
+	// OnValidate is called each time a token is validated by ValidateToken
+	// or one of its variants. For rejected tokens the token id, subject and
+	// type are the ones the token claims, unverified, when it can be decoded.
+	OnValidate(e AuditEvent)
+}
+
+// WithAuditSink configures the sink receiving an AuditEvent for every token
+// issued and validated. By default nothing is audited.
+func WithAuditSink(s AuditSink) Option {
+	return func(a *Auth) {
+		a.audit = s
+	}
+}
+
+// WithAuditUserInfo includes the username and email of the claims in audit
+// events. They are personal data, so by default they are left out.
+func WithAuditUserInfo() Option {
+	return func(a *Auth) {
+		a.auditUserInfo = true
+	}
+}
+
+// auditEvent builds the event for the claims.
+func (a *Auth) auditEvent(claims Claims, err error) AuditEvent {
+	e := AuditEvent{
+		Time:      a.clock(),
+		Outcome:   AuditOutcomeSuccess,
+		TokenID:   claims.Id,
+		Subject:   claims.Subject,
+		TokenType: claims.TokenType,
+	}
+
+	if err != nil {
+		e.Outcome = AuditOutcomeFailure
+		e.Reason = ReasonOf(err).Code()
+	}
+
+	if a.auditUserInfo {
+		e.UserName = claims.UserName
+		e.Email = claims.Email
+	}
+
+	return e
+}
+
+// auditIssue reports the issuance of the token for the claims. The claims of
+// an issued token are decoded from it to include the generated token id.
+func (a *Auth) auditIssue(tokenStr string, claims Claims, err error) {
+	if a.audit == nil {
+		return
+	}
+
+	if err == nil {
+		if issued, perr := ParseUnverified(tokenStr); perr == nil {
+			claims = issued
+		}
+	}
+	a.audit.OnIssue(a.auditEvent(claims, err))
+}
+
+// auditValidate reports the validation of the token. The claims of rejected
+// tokens are decoded without verification.
+func (a *Auth) auditValidate(tokenStr string, claims Claims, err error) {
+	if a.audit == nil {
+		return
+	}
+
+	if err != nil {
+		claims, _ = ParseUnverified(tokenStr)
+	}
+	a.audit.OnValidate(a.auditEvent(claims, err))
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// recordingSink is an AuditSink recording the events it receives.
+type recordingSink struct {
+	issued    []AuditEvent
+	validated []AuditEvent
+}
+
+func (s *recordingSink) OnIssue(e AuditEvent) {
+	s.issued = append(s.issued, e)
+}
+
+func (s *recordingSink) OnValidate(e AuditEvent) {
+	s.validated = append(s.validated, e)
+}
+
+// userClaims returns claims for the subject carrying a username and email.
+func userClaims(subject string) Claims {
+	claims := newClaims(subject)
+	claims.UserName = "jdoe"
+	claims.Email = "jdoe@example.com"
+	return claims
+}
+
+func TestAuditIssue(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		claims   Claims
+		outcome  string
+		reason   string
+		userInfo bool
+	}{
+		{name: "issued", claims: userClaims("user"), outcome: AuditOutcomeSuccess},
+		{name: "issued with user info", opts: []Option{WithAuditUserInfo()}, claims: userClaims("user"), outcome: AuditOutcomeSuccess, userInfo: true},
+		{
+			name: "expired claims",
+			claims: func() Claims {
+				c := userClaims("user")
+				c.ExpiresAt = testNow.Add(-time.Minute).Unix()
+				return c
+			}(),
+			outcome: AuditOutcomeFailure,
+			reason:  "invalid_token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			a := newTestAuth(t, append([]Option{WithAuditSink(sink)}, tt.opts...)...)
+
+			tokenStr, _ := a.GenerateToken(tt.claims)
+
+			if len(sink.issued) != 1 {
+				t.Fatalf("got %d issue events, want 1", len(sink.issued))
+			}
+			if len(sink.validated) != 0 {
+				t.Errorf("got %d validate events, want 0", len(sink.validated))
+			}
+
+			e := sink.issued[0]
+			if e.Outcome != tt.outcome {
+				t.Errorf("outcome is %q, want %q", e.Outcome, tt.outcome)
+			}
+			if e.Reason != tt.reason {
+				t.Errorf("reason is %q, want %q", e.Reason, tt.reason)
+			}
+			if !e.Time.Equal(testNow) {
+				t.Errorf("time is %v, want %v", e.Time, testNow)
+			}
+			if e.Subject != "user" {
+				t.Errorf("subject is %q, want %q", e.Subject, "user")
+			}
+
+			if tt.outcome == AuditOutcomeSuccess {
+				claims, err := ParseUnverified(tokenStr)
+				if err != nil {
+					t.Fatalf("parsing token: %v", err)
+				}
+				if claims.Id == "" || e.TokenID != claims.Id {
+					t.Errorf("token id is %q, want %q", e.TokenID, claims.Id)
+				}
+			}
+
+			checkUserInfo(t, e, tt.userInfo)
+		})
+	}
+}
+
+func TestAuditValidate(t *testing.T) {
+	signer := newTestAuth(t)
+	withIssuer := newTestAuth(t, WithIssuer("other"), WithAudience("other"))
+	otherSecret, err := New("another-secret-of-at-least-32-bytes", "HS256", WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	notYetValid := userClaims("user")
+	notYetValid.NotBefore = testNow.Add(10 * time.Minute).Unix()
+
+	refresh := userClaims("user")
+	refresh.TokenType = TokenTypeRefresh
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		token   func(t *testing.T) string
+		prepare func(t *testing.T, a *Auth, tokenStr string)
+		advance time.Duration
+		reason  string
+		subject string
+	}{
+		{
+			name:    "valid",
+			token:   func(t *testing.T) string { return mustGenerate(t, signer, userClaims("user")) },
+			subject: "user",
+		},
+		{
+			name:    "expired",
+			token:   func(t *testing.T) string { return mustGenerate(t, signer, userClaims("user")) },
+			advance: 2 * time.Hour,
+			reason:  "token_expired",
+			subject: "user",
+		},
+		{
+			name:    "not yet valid",
+			token:   func(t *testing.T) string { return mustGenerate(t, signer, notYetValid) },
+			reason:  "token_not_yet_valid",
+			subject: "user",
+		},
+		{
+			name:    "bad signature",
+			token:   func(t *testing.T) string { return mustGenerate(t, otherSecret, userClaims("user")) },
+			reason:  "bad_signature",
+			subject: "user",
+		},
+		{
+			name:    "bad issuer",
+			opts:    []Option{WithIssuer("issuer")},
+			token:   func(t *testing.T) string { return mustGenerate(t, withIssuer, userClaims("user")) },
+			reason:  "bad_issuer",
+			subject: "user",
+		},
+		{
+			name:    "bad audience",
+			opts:    []Option{WithAudience("audience")},
+			token:   func(t *testing.T) string { return mustGenerate(t, withIssuer, userClaims("user")) },
+			reason:  "bad_audience",
+			subject: "user",
+		},
+		{
+			name:  "revoked",
+			opts:  []Option{WithRevoker(NewMemoryRevoker())},
+			token: func(t *testing.T) string { return mustGenerate(t, signer, userClaims("user")) },
+			prepare: func(t *testing.T, a *Auth, tokenStr string) {
+				if err := a.Revoke(tokenStr); err != nil {
+					t.Fatalf("revoking token: %v", err)
+				}
+			},
+			reason:  "token_revoked",
+			subject: "user",
+		},
+		{
+			name:   "malformed",
+			token:  func(t *testing.T) string { return "not-a-token" },
+			reason: "malformed_token",
+		},
+		{
+			name:    "wrong token type",
+			token:   func(t *testing.T) string { return mustGenerate(t, signer, refresh) },
+			reason:  "invalid_token",
+			subject: "user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			sink := &recordingSink{}
+			opts := append([]Option{WithClock(clock.Now)}, tt.opts...)
+			a := newTestAuth(t, opts...)
+
+			tokenStr := tt.token(t)
+			if tt.prepare != nil {
+				tt.prepare(t, a, tokenStr)
+			}
+			clock.Advance(tt.advance)
+
+			// The sink is added last so only the validation below is recorded.
+			WithAuditSink(sink)(a)
+
+			_, err := a.ValidateToken(tokenStr)
+			if (err == nil) != (tt.reason == "") {
+				t.Fatalf("err is %v, want reason %q", err, tt.reason)
+			}
+
+			if len(sink.validated) != 1 {
+				t.Fatalf("got %d validate events, want 1", len(sink.validated))
+			}
+			if len(sink.issued) != 0 {
+				t.Errorf("got %d issue events, want 0", len(sink.issued))
+			}
+
+			e := sink.validated[0]
+			outcome := AuditOutcomeSuccess
+			if tt.reason != "" {
+				outcome = AuditOutcomeFailure
+			}
+			if e.Outcome != outcome {
+				t.Errorf("outcome is %q, want %q", e.Outcome, outcome)
+			}
+			if e.Reason != tt.reason {
+				t.Errorf("reason is %q, want %q", e.Reason, tt.reason)
+			}
+			if want := testNow.Add(tt.advance); !e.Time.Equal(want) {
+				t.Errorf("time is %v, want %v", e.Time, want)
+			}
+			if e.Subject != tt.subject {
+				t.Errorf("subject is %q, want %q", e.Subject, tt.subject)
+			}
+
+			claims, _ := ParseUnverified(tokenStr)
+			if e.TokenID != claims.Id {
+				t.Errorf("token id is %q, want %q", e.TokenID, claims.Id)
+			}
+
+			checkUserInfo(t, e, false)
+		})
+	}
+}
+
+func TestAuditValidateUserInfo(t *testing.T) {
+	sink := &recordingSink{}
+	a := newTestAuth(t, WithAuditSink(sink), WithAuditUserInfo())
+
+	if _, err := a.ValidateToken(mustGenerate(t, a, userClaims("user"))); err != nil {
+		t.Fatalf("validating token: %v", err)
+	}
+
+	if len(sink.validated) != 1 {
+		t.Fatalf("got %d validate events, want 1", len(sink.validated))
+	}
+	checkUserInfo(t, sink.validated[0], true)
+}
+
+// checkUserInfo checks the username and email of the event are only set when
+// they are wanted.
+func checkUserInfo(t *testing.T, e AuditEvent, want bool) {
+	t.Helper()
+
+	wantName, wantEmail := "", ""
+	if want {
+		wantName, wantEmail = "jdoe", "jdoe@example.com"
+	}
+	if e.UserName != wantName {
+		t.Errorf("username is %q, want %q", e.UserName, wantName)
+	}
+	if e.Email != wantEmail {
+		t.Errorf("email is %q, want %q", e.Email, wantEmail)
+	}
+}
@@ -2,96 +2,715 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
 	jwt "github.com/golang-jwt/jwt/v4"
 	"github.com/pkg/errors"
 )
 
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// Key is used to store/retrieve a Claims value from a context.Context.
+const Key ctxKey = 1
+
 // These are the expected values for Claims.Roles.
 const (
 	RoleAdmin = "ADMIN"
 	RoleUser  = "USER"
 )
 
-// Claims represents the authorization claims transmitted via a JWT.
+// Claims represents the authorization claims transmitted via a JWT. The
+// Audience field replaces the single string audience of jwt.StandardClaims
+// so tokens carrying several audiences can be represented. Extra holds any
+// other claims, encoded at the top level of the token.
 type Claims struct {
 	jwt.StandardClaims
-	Name     string   `json:"name"`
-	UserName string   `json:"username"`
-	Roles    []string `json:"roles"`
+	Audience      ClaimStrings           `json:"aud,omitempty"`
+	Name          string                 `json:"name"`
+	UserName      string                 `json:"username"`
+	Roles         []string               `json:"roles"`
+	Scope         string                 `json:"scope,omitempty"`
+	TokenType     string                 `json:"typ,omitempty"`
+	Family        string                 `json:"fam,omitempty"`
+	SessionID     string                 `json:"sid,omitempty"`
+	TenantID      string                 `json:"tid,omitempty"`
+	Fingerprint   string                 `json:"fpt,omitempty"`
+	AMR           []string               `json:"amr,omitempty"`
+	ACR           string                 `json:"acr,omitempty"`
+	Email         string                 `json:"email,omitempty"`
+	EmailVerified bool                   `json:"email_verified,omitempty"`
+	GivenName     string                 `json:"given_name,omitempty"`
+	FamilyName    string                 `json:"family_name,omitempty"`
+	Picture       string                 `json:"picture,omitempty"`
+	Actor         *Claims                `json:"act,omitempty"`
+	Extra         map[string]interface{} `json:"-"`
+
+	// validation is set while the parser of an Auth decodes into the
+	// Claims, see Valid.
+	validation *claimsValidation
 }
 
-// Authorized returns true if the claims has at least one of the provided roles.
-func (c Claims) Authorized(roles ...string) bool {
-	for _, has := range c.Roles {
-		for _, want := range roles {
-			if has == want {
-				return true
-			}
+// ClaimStrings is a claim holding one or more strings, like the audience.
+// It decodes from a JSON string or array of strings. A single value is
+// encoded as a string and several values as an array.
+type ClaimStrings []string
+
+// MarshalJSON implements the json.Marshaler interface.
+func (cs ClaimStrings) MarshalJSON() ([]byte, error) {
+	if len(cs) == 1 {
+		return json.Marshal(cs[0])
+	}
+	return json.Marshal([]string(cs))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (cs *ClaimStrings) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*cs = ClaimStrings{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("claim must be a string or an array of strings")
+	}
+	*cs = list
+
+	return nil
+}
+
+// Contains returns true if s is one of the values.
+func (cs ClaimStrings) Contains(s string) bool {
+	for _, v := range cs {
+		if v == s {
+			return true
 		}
 	}
 	return false
 }
 
+// Authorized returns true if the claims has at least one of the provided roles.
+// Roles are compared exactly, see AuthorizedWith to change that.
+func (c Claims) Authorized(roles ...string) bool {
+	return c.AuthorizedWith(ExactRoleMatch, roles...)
+}
+
+// HasRole returns true if the claims has the provided role.
+func (c Claims) HasRole(role string) bool {
+	return c.Authorized(role)
+}
+
+// HasAnyRole returns true if the claims has at least one of the provided
+// roles. It is the same check as Authorized with a name that makes the OR
+// semantics explicit.
+func (c Claims) HasAnyRole(roles ...string) bool {
+	return c.Authorized(roles...)
+}
+
+// HasAllRoles returns true if the claims has every one of the provided roles.
+// Like Authorized, it returns false when no roles are provided.
+func (c Claims) HasAllRoles(roles ...string) bool {
+	return c.HasAllRolesWith(ExactRoleMatch, roles...)
+}
+
+// FromContext returns the Claims stored in the context under Key. The bool
+// is false when no Claims value is present.
+func FromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(Key).(Claims)
+	return claims, ok
+}
+
+// MustFromContext returns the Claims stored in the context under Key. It
+// panics when no Claims value is present, so it should only be used by
+// handlers that always run behind the Authenticate middleware.
+func MustFromContext(ctx context.Context) Claims {
+	claims, ok := FromContext(ctx)
+	if !ok {
+		panic("auth: claims missing from context, is the handler behind the Authenticate middleware?")
+	}
+	return claims
+}
+
 // Auth is used to authenticate clients. It can generate a token for a
 // set of user claims and recreate the claims by parsing the token.
 type Auth struct {
-	signingKey string
-	method     jwt.SigningMethod
-	keyFunc    func(t *jwt.Token) (interface{}, error)
-	parser     jwt.Parser
+	// keyMu guards the signing key, kid, secrets and public keys which can
+	// be rotated while the Auth is in use, and the keys and parser methods
+	// changed by AcceptMethod and AddSigningKey.
+	keyMu         sync.RWMutex
+	signingKey    interface{}
+	signer        Signer
+	kid           string
+	secrets       map[string][]byte
+	methodKeys    map[string]interface{}
+	methodAlgs    map[string]bool
+	publicKeys    map[string]interface{}
+	signingKeys   map[string]interface{}
+	encryptionKey []byte
+	method        jwt.SigningMethod
+	keyFunc       func(t *jwt.Token) (interface{}, error)
+	parser        jwt.Parser
+
+	unauthorized      ErrorHandler
+	forbidden         ErrorHandler
+	unauthorizedSet   bool
+	forbiddenSet      bool
+	extractor         TokenExtractor
+	headerName        string
+	authScheme        string
+	wsParam           string
+	roleMatch         RoleComparator
+	roleClaim         string
+	usernameClaim     string
+	keycloak          bool
+	keycloakClients   []string
+	hierarchy         map[string][]string
+	revoker           Revoker
+	limiter           FailureLimiter
+	limiterKey        func(r *http.Request) string
+	minIAT            MinIATStore
+	tokens            TokenStore
+	apiKeys           KeyStore
+	idempotency       IdempotencyStore
+	idempotencyWindow time.Duration
+	families          FamilyStore
+	sessions          RefreshStore
+	sessionStore      SessionStore
+	accessTTL         time.Duration
+	maxLifetime       time.Duration
+	maxTokenBytes     int
+	refreshTTL        time.Duration
+	issuer            string
+	trustedIssuers    []string
+	audience          string
+	leeway            time.Duration
+	issuanceSkew      time.Duration
+	clock             func() time.Time
+	jwks              *jwks
+	jwksInterval      time.Duration
+	jwksMaxAge        time.Duration
+	httpClient        *http.Client
+
+	allowNoExpiry  bool
+	reissueExpired bool
+	acrLevels      []string
+	strictClaims   bool
+	compress       bool
+	serviceTokens  bool
+	autoClaims     bool
+	compactClaims  bool
+	idGenerator    func() string
+	validators     []ValidateFunc
+	metrics        MetricsCollector
+	audit          AuditSink
+	auditUserInfo  bool
+	cache          *validationCache
 }
 
 // New creates an Auth to support authentication/authorization.
-func New(signingKey string, alg string) (*Auth, error) {
+func New(signingKey string, alg string, opts ...Option) (*Auth, error) {
+	method, err := hmacMethod(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if signingKey == "" {
+		return nil, errors.New("signing key is empty")
+	}
+
+	return newHMACAuth(method, map[string][]byte{"": []byte(signingKey)}, "", opts), nil
+}
+
+// signingMethod looks up the signing method for the algorithm. The "none"
+// algorithm is rejected since it produces tokens anyone can forge.
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	if alg == jwt.SigningMethodNone.Alg() {
+		return nil, errors.New("configuring algorithm: none is insecure")
+	}
+
 	method := jwt.GetSigningMethod(alg)
 	if method == nil {
 		return nil, errors.Errorf("configuring algorithm")
 	}
 
-	keyFunc := func(t *jwt.Token) (interface{}, error) {
-		return []byte(signingKey), nil
+	return method, nil
+}
+
+// hmacMethod looks up the signing method for the algorithm and requires it
+// to be an HMAC method, the only kind that can use a shared secret.
+func hmacMethod(alg string) (jwt.SigningMethod, error) {
+	method, err := signingMethod(alg)
+	if err != nil {
+		return nil, err
 	}
 
+	if _, ok := method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, errors.Errorf("configuring algorithm: %s requires an asymmetric key", alg)
+	}
+
+	return method, nil
+}
+
+// newAuth constructs an Auth that signs with signingKey and verifies using
+// keyFunc. When kid is not empty it is set as the kid header of generated
+// tokens. A nil signingKey produces an Auth that can only validate tokens.
+// The options are applied last so they can override any default.
+func newAuth(method jwt.SigningMethod, signingKey interface{}, kid string, keyFunc jwt.Keyfunc, opts []Option) *Auth {
 	// Create the token parser to use. The algorithm used to sign the JWT must be
 	// validated to avoid a critical vulnerability:
 	// https://auth0.com/blog/critical-vulnerabilities-in-json-web-token-libraries/
+	//
+	// The parser checks the issuer and audience by calling Claims.Valid. The
+	// times are validated by validateClaims instead so the configured clock
+	// and leeway are honored.
 	parser := jwt.Parser{
-		ValidMethods: []string{alg},
+		ValidMethods: []string{method.Alg()},
 	}
 
 	a := Auth{
 		signingKey: signingKey,
+		kid:        kid,
 		method:     method,
 		keyFunc:    keyFunc,
 		parser:     parser,
+
+		unauthorized: defaultUnauthorized,
+		forbidden:    defaultForbidden,
+		extractor:    FromHeader,
+		headerName:   "Authorization",
+		authScheme:   "Bearer",
+		roleMatch:    ExactRoleMatch,
+		metrics:      noopMetrics{},
+		accessTTL:    DefaultAccessTTL,
+		refreshTTL:   DefaultRefreshTTL,
+		clock:        time.Now,
+		jwksInterval: DefaultJWKSRefreshInterval,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for _, opt := range opts {
+		opt(&a)
+	}
+	if a.encryptionKey == nil {
+		a.encryptionKey = deriveEncryptionKey(signingKey)
 	}
+	a.shareClock()
 
-	return &a, nil
+	return &a
 }
 
+// ErrNoExpiry is returned by GenerateToken for claims without an expiration
+// unless the Auth was configured with AllowNoExpiry.
+var ErrNoExpiry = errors.New("token has no expiration")
+
 // GenerateToken generates a signed JWT token string representing the user Claims.
+// A unique token id (jti) is assigned when the claims don't provide one. The
+// configured issuer and audience are set when the claims don't provide them.
+// The claims must carry an expiration that hasn't passed yet.
 func (a *Auth) GenerateToken(claims Claims) (string, error) {
-	token := jwt.NewWithClaims(a.method, claims)
-	str, err := token.SignedString([]byte(a.signingKey))
+	return a.GenerateTokenContext(context.Background(), claims)
+}
+
+// GenerateTokenWithID is GenerateToken but also returns the token id (jti)
+// so it can be logged when the token is minted.
+func (a *Auth) GenerateTokenWithID(claims Claims) (string, string, error) {
+	if claims.Id == "" {
+		id, err := a.tokenID()
+		if err != nil {
+			return "", "", errors.Wrap(err, "generating token id")
+		}
+		claims.Id = id
+	}
+
+	str, err := a.GenerateToken(claims)
 	if err != nil {
-		return "", errors.Wrap(err, "signing token")
+		return "", "", err
 	}
 
+	return str, claims.Id, nil
+}
+
+// GenerateTokenContext is GenerateToken with a context, which is used to
+// record an auth.Generate span.
+func (a *Auth) GenerateTokenContext(ctx context.Context, claims Claims) (string, error) {
+	return a.issue(ctx, claims, a.generate)
+}
+
+// issue signs the claims with gen, recording the span, metrics and audit
+// event of the issuance.
+func (a *Auth) issue(ctx context.Context, claims Claims, gen func(Claims) (string, error)) (string, error) {
+	_, span := tracer.Start(ctx, "auth.Generate")
+	defer span.End()
+
+	str, err := gen(claims)
+	if err == nil {
+		err = a.checkTokenSize(str)
+	}
+	if err != nil {
+		recordError(span, err)
+		a.auditIssue("", claims, err)
+		return "", err
+	}
+
+	a.metrics.TokenGenerated()
+	a.auditIssue(str, claims, nil)
+
 	return str, nil
 }
 
+// generate signs the claims with the signing method and active key of the
+// Auth.
+func (a *Auth) generate(claims Claims) (string, error) {
+	signingKey, kid := a.activeKey()
+	if signingKey == nil && a.signer == nil {
+		return "", errors.New("signing key not configured")
+	}
+
+	return a.generateWith(claims, a.method, signingKey, kid, a.signer)
+}
+
+// generateWith signs the claims using the method with signingKey, or with
+// signer when it isn't nil. When kid is not empty it is set as the kid header.
+func (a *Auth) generateWith(claims Claims, method jwt.SigningMethod, signingKey interface{}, kid string, signer Signer) (string, error) {
+	now := a.clock()
+
+	switch {
+	case claims.ExpiresAt == 0:
+		if !a.allowNoExpiry && !a.isServiceToken(claims) {
+			return "", ErrNoExpiry
+		}
+	case !now.Before(time.Unix(claims.ExpiresAt, 0)):
+		return "", errors.Wrap(ErrTokenExpired, "generating token")
+	case claims.NotBefore >= claims.ExpiresAt:
+		return "", errors.New("token would expire before it becomes valid")
+	default:
+		if err := a.checkLifetime(claims.ExpiresAt, now); err != nil {
+			return "", errors.Wrap(err, "generating token")
+		}
+	}
+
+	// The time claims are always written, in seconds, as other libraries may
+	// require them.
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = now.Unix()
+	}
+	if a.issuanceSkew > 0 {
+		backdateClaims(&claims, now, a.issuanceSkew)
+	}
+
+	if claims.Issuer == "" {
+		claims.Issuer = a.issuer
+	}
+	if len(claims.Audience) == 0 && a.audience != "" {
+		claims.Audience = ClaimStrings{a.audience}
+	}
+
+	if a.autoClaims {
+		fillRegisteredClaims(&claims, now)
+	}
+
+	if claims.Id == "" {
+		id, err := a.tokenID()
+		if err != nil {
+			return "", errors.Wrap(err, "generating token id")
+		}
+		claims.Id = id
+	}
+
+	var token *jwt.Token
+	if a.compactClaims {
+		token = jwt.NewWithClaims(method, compactClaims(claims))
+	} else {
+		token = jwt.NewWithClaims(method, claims)
+	}
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	input, err := a.signingInput(token)
+	if err != nil {
+		return "", errors.Wrap(err, "encoding token")
+	}
+
+	if signer != nil {
+		return signWith(signer, input)
+	}
+
+	sig, err := token.Method.Sign(input, signingKey)
+	if err != nil {
+		return "", errors.Wrap(err, "signing token")
+	}
+
+	return input + "." + sig, nil
+}
+
+// GenerateTokenWithTTL generates a signed JWT token string for the claims that
+// expires ttl from now. The IssuedAt and ExpiresAt of the claims are replaced.
+func (a *Auth) GenerateTokenWithTTL(claims Claims, ttl time.Duration) (string, error) {
+	now := a.clock()
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+
+	return a.GenerateToken(claims)
+}
+
 // ValidateToken recreates the Claims that were used to generate a token. It
-// verifies that the token was signed using our key.
+// verifies that the token was signed using our key. Only access tokens, and
+// tokens without a type, are accepted. Refresh tokens are rejected since they
+// must only be presented to Refresh, and tokens for other purposes must be
+// validated with ValidateTokenOfType. Any error returned
+// is a *ValidationError describing why the token was rejected.
 func (a *Auth) ValidateToken(tokenStr string) (Claims, error) {
-	var claims Claims
-	token, err := a.parser.ParseWithClaims(tokenStr, &claims, a.keyFunc)
+	return a.ValidateTokenContext(context.Background(), tokenStr)
+}
+
+// ValidateTokenContext is ValidateToken with a context, which is used to
+// record an auth.Validate span. For JWKS backed validation, cancelling the
+// context stops waiting on any fetch of the key set made for this token. The
+// fetch itself is shared with concurrent validations and carries on.
+func (a *Auth) ValidateTokenContext(ctx context.Context, tokenStr string) (Claims, error) {
+	claims, _, err := a.validateToken(ctx, tokenStr, TokenTypeAccess)
+	return claims, err
+}
+
+// ValidateTokenDetailed is ValidateToken but also returns the underlying
+// token so callers can inspect the header (alg, kid), the signature or the
+// signing method. The returned token must not be modified.
+func (a *Auth) ValidateTokenDetailed(tokenStr string) (Claims, *jwt.Token, error) {
+	claims, token, err := a.validateToken(context.Background(), tokenStr, TokenTypeAccess)
+	if err != nil {
+		return Claims{}, nil, err
+	}
+
+	// The token may be shared with the validation cache, so hand out a copy
+	// pointing at the caller's claims.
+	t := *token
+	c := claims
+	t.Claims = &c
+
+	return claims, &t, nil
+}
+
+// validateToken implements ValidateTokenContext and records the span and
+// metrics for the validation. The token must be of the type typ. Tokens
+// without a type are access tokens.
+func (a *Auth) validateToken(ctx context.Context, tokenStr string, typ string) (Claims, *jwt.Token, error) {
+	ctx, span := tracer.Start(ctx, "auth.Validate")
+	defer span.End()
+
+	claims, token, err := a.validate(ctx, tokenStr)
+	if err == nil && claims.TokenType != typ && !a.acceptsAs(claims, typ) {
+		err = newValidationError(errors.Wrapf(ErrInvalidTokenType, "expected %s token", typ))
+	}
+
 	if err != nil {
-		return Claims{}, errors.Wrap(err, "parsing token")
+		recordError(span, err)
+		a.metrics.TokenValidated(ReasonOf(err).Code())
+		a.auditValidate(tokenStr, Claims{}, err)
+		return Claims{}, nil, err
+	}
+
+	a.metrics.TokenValidated("")
+	a.auditValidate(tokenStr, claims, nil)
+	if a.isServiceToken(claims) {
+		a.recordServiceToken()
+	}
+
+	return claims, token, nil
+}
+
+// acceptsAs returns true if tokens of another type can be used as tokens of
+// type typ. Tokens without a type, service tokens and Keycloak access tokens
+// are access tokens.
+func (a *Auth) acceptsAs(claims Claims, typ string) bool {
+	if typ != TokenTypeAccess {
+		return false
+	}
+	return claims.TokenType == "" || a.isServiceToken(claims) || a.isKeycloakAccess(claims)
+}
+
+// validate parses and verifies the token regardless of its type. Errors are
+// returned as a *ValidationError.
+func (a *Auth) validate(ctx context.Context, tokenStr string) (Claims, *jwt.Token, error) {
+	claims, token, err := a.verify(ctx, tokenStr)
+	if err != nil {
+		return Claims{}, nil, newValidationError(err)
+	}
+
+	return claims, token, nil
+}
+
+// verify parses the token, verifies its signature and validates its claims.
+func (a *Auth) verify(ctx context.Context, tokenStr string) (Claims, *jwt.Token, error) {
+	claims, token, err := a.parse(ctx, tokenStr)
+	if err != nil {
+		return Claims{}, nil, err
+	}
+
+	if err := a.validateClaims(claims); err != nil {
+		return Claims{}, nil, errors.Wrap(err, "validating claims")
+	}
+
+	if err := a.checkPolicies(claims); err != nil {
+		return Claims{}, nil, err
+	}
+
+	return claims, token, nil
+}
+
+// parse parses the token and verifies its signature. The claims are not
+// validated. When the validation cache is enabled, tokens that were already
+// verified are served from it. The Claims field of the returned token is
+// nil, the claims are returned separately.
+func (a *Auth) parse(ctx context.Context, tokenStr string) (Claims, *jwt.Token, error) {
+	// Cached claims of a JWKS backed Auth are only served while the key set
+	// is fresh. Once it is stale the token is verified again, which refreshes
+	// the key set or rejects the token with ErrStaleKeySet.
+	var gen uint64
+	if a.cache != nil {
+		if a.jwks == nil || !a.jwks.stale() {
+			if claims, token, ok := a.cache.get(tokenStr, a.clock()); ok {
+				return claims, token, nil
+			}
+		}
+		gen = a.cache.generation()
 	}
 
-	if !token.Valid {
-		return Claims{}, errors.New("invalid token")
+	keyFunc := a.keyFunc
+	if a.jwks != nil {
+		keyFunc = a.jwks.keyFuncContext(ctx)
 	}
 
-	return claims, nil
+	// The parser needs a pointer to decode into, which would escape to the
+	// heap on every call. Borrow one from the pool and copy the result out.
+	buf := claimsPool.Get().(*Claims)
+	defer func() {
+		*buf = Claims{}
+		claimsPool.Put(buf)
+	}()
+
+	buf.validation = &claimsValidation{a: a}
+
+	token, payload, err := a.parseToken(tokenStr, buf, a.methodKeyFunc(keyFunc))
+	if err != nil {
+		return Claims{}, nil, errors.Wrap(err, "parsing token")
+	}
+
+	claims := *buf
+	claims.validation = nil
+	token.Claims = nil
+
+	if err := a.mapClaims(payload, &claims); err != nil {
+		return Claims{}, nil, errors.Wrap(err, "mapping claims")
+	}
+
+	if a.cache != nil {
+		a.cache.add(tokenStr, claims, token, gen, a.clock())
+	}
+
+	return claims, token, nil
+}
+
+// parseToken does what jwt.Parser.ParseWithClaims does, decoding the header
+// only once for both the signature and the payload, which may be compressed.
+// The signature is verified before the payload is decoded, so only tokens we
+// signed are ever inflated or checked by WithStrictClaims. The claims are
+// decoded into claims and checked by Claims.Valid. The decoded payload is
+// returned with the token.
+func (a *Auth) parseToken(tokenStr string, claims *Claims, keyFunc jwt.Keyfunc) (*jwt.Token, []byte, error) {
+	parts, header, err := splitToken(tokenStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alg, _ := header["alg"].(string)
+	method := jwt.GetSigningMethod(alg)
+	valid := false
+	for _, m := range a.verifyParser().ValidMethods {
+		if m == alg {
+			valid = true
+		}
+	}
+	if method == nil || !valid {
+		return nil, nil, errors.Wrapf(ErrInvalidSignature, "signing method %s is invalid", alg)
+	}
+
+	token := jwt.Token{
+		Raw:       tokenStr,
+		Method:    method,
+		Header:    header,
+		Claims:    claims,
+		Signature: parts[2],
+	}
+
+	key, err := keyFunc(&token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := method.Verify(parts[0]+"."+parts[1], parts[2], key); err != nil {
+		return nil, nil, ErrInvalidSignature
+	}
+
+	payload, err := decodePayload(header, parts[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if a.strictClaims {
+		if err := checkClaimTypes(payload); err != nil {
+			return nil, nil, errors.Wrap(err, "checking claims")
+		}
+	}
+
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, nil, errors.Wrap(ErrTokenMalformed, "decoding claims")
+	}
+
+	if err := claims.Valid(); err != nil {
+		return nil, nil, err
+	}
+
+	token.Valid = true
+
+	return &token, payload, nil
+}
+
+// claimsPool holds the Claims values parse decodes into. Every value is reset
+// to its zero value before it is returned to the pool so claims of one token
+// can never leak into the next.
+var claimsPool = sync.Pool{
+	New: func() interface{} {
+		return new(Claims)
+	},
+}
+
+// tokenID returns the id for a new token from the generator configured with
+// WithIDGenerator, or a random UUID when there is none.
+func (a *Auth) tokenID() (string, error) {
+	if a.idGenerator != nil {
+		return a.idGenerator(), nil
+	}
+	return newTokenID()
+}
+
+// newTokenID generates a random version 4 UUID to use as a token id.
+func newTokenID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
 }
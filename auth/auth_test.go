@@ -0,0 +1,353 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// testSecret is the HMAC secret of the Auth returned by newTestAuth.
+const testSecret = "test-secret-with-enough-entropy-for-hs256"
+
+// testNow is the time of the clock of the Auth returned by newTestAuth.
+var testNow = time.Date(2026, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+// testClock is a clock for WithClock that only moves when advanced. It starts
+// at testNow.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// newTestClock returns a testClock set to testNow.
+func newTestClock() *testClock {
+	return &testClock{now: testNow}
+}
+
+// Now returns the current time of the clock.
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// newTestAuth constructs an HS256 Auth whose clock is stopped at testNow.
+// The options are applied after the clock so they can replace it.
+func newTestAuth(t testing.TB, opts ...Option) *Auth {
+	t.Helper()
+
+	opts = append([]Option{WithClock(func() time.Time { return testNow })}, opts...)
+	a, err := New(testSecret, "HS256", opts...)
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	return a
+}
+
+// newClaims returns claims for the subject issued at testNow and expiring an
+// hour later.
+func newClaims(subject string, roles ...string) Claims {
+	var c Claims
+	c.Subject = subject
+	c.Roles = roles
+	c.IssuedAt = testNow.Unix()
+	c.ExpiresAt = testNow.Add(time.Hour).Unix()
+	return c
+}
+
+// mustGenerate generates a token for the claims, failing the test on error.
+func mustGenerate(t testing.TB, a *Auth, claims Claims) string {
+	t.Helper()
+
+	tokenStr, err := a.GenerateToken(claims)
+	if err != nil {
+		t.Fatalf("generating token: %v", err)
+	}
+
+	return tokenStr
+}
+
+func TestNew(t *testing.T) {
+	priv, pub := newRSAKeyPEM(t)
+
+	tests := []struct {
+		name  string
+		new   func() (*Auth, error)
+		valid bool
+	}{
+		{name: "hmac", new: func() (*Auth, error) { return New(testSecret, "HS256") }, valid: true},
+		{name: "none", new: func() (*Auth, error) { return New(testSecret, "none") }},
+		{name: "unknown algorithm", new: func() (*Auth, error) { return New(testSecret, "XX256") }},
+		{name: "empty secret", new: func() (*Auth, error) { return New("", "HS256") }},
+		{name: "asymmetric algorithm", new: func() (*Auth, error) { return New(testSecret, "RS256") }},
+		{name: "asymmetric", new: func() (*Auth, error) { return NewAsymmetric(priv, pub, "RS256") }, valid: true},
+		{name: "asymmetric none", new: func() (*Auth, error) { return NewAsymmetric(priv, pub, "none") }},
+		{name: "asymmetric hmac", new: func() (*Auth, error) { return NewAsymmetric(priv, pub, "HS256") }},
+		{name: "validator hmac", new: func() (*Auth, error) { return NewValidator(pub, "HS256") }},
+		{name: "validator wrong key type", new: func() (*Auth, error) { return NewValidator(pub, "ES256") }},
+		{
+			name:  "keys",
+			new:   func() (*Auth, error) { return NewWithKeys(map[string]string{"k1": testSecret}, "k1", "HS256") },
+			valid: true,
+		},
+		{
+			name: "keys empty secret",
+			new: func() (*Auth, error) {
+				return NewWithKeys(map[string]string{"k1": testSecret, "k2": ""}, "k1", "HS256")
+			},
+		},
+		{
+			name: "keys asymmetric algorithm",
+			new:  func() (*Auth, error) { return NewWithKeys(map[string]string{"k1": testSecret}, "k1", "RS256") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := tt.new()
+			if tt.valid && err != nil {
+				t.Fatalf("constructing auth: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("auth was constructed")
+			}
+			if !tt.valid && a != nil {
+				t.Error("auth returned with the error")
+			}
+		})
+	}
+}
+
+func TestValidateTokenRejectsNone(t *testing.T) {
+	a := newTestAuth(t)
+
+	claims := newClaims("user", RoleAdmin)
+	token := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	tokenStr, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+
+	// Also try the none token with the signature of a real one attached.
+	parts := strings.Split(mustGenerate(t, a, claims), ".")
+	forged := strings.TrimSuffix(tokenStr, ".") + "." + parts[2]
+
+	for _, tokenStr := range []string{tokenStr, forged} {
+		if _, err := a.ValidateToken(tokenStr); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("error is %v, want %v", err, ErrInvalidSignature)
+		}
+	}
+}
+
+func TestGenerateTokenWithTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		advance time.Duration
+		ttl     time.Duration
+		err     error
+	}{
+		{name: "hour", ttl: time.Hour},
+		{name: "minute later", advance: 10 * time.Minute, ttl: time.Minute},
+		{name: "zero", ttl: 0, err: ErrTokenExpired},
+		{name: "negative", ttl: -time.Minute, err: ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, WithClock(clock.Now))
+			clock.Advance(tt.advance)
+
+			// The times of the claims are replaced.
+			claims := newClaims("user")
+			claims.IssuedAt = testNow.Add(-24 * time.Hour).Unix()
+			claims.ExpiresAt = testNow.Add(24 * time.Hour).Unix()
+
+			tokenStr, err := a.GenerateTokenWithTTL(claims, tt.ttl)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err is %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("generating token: %v", err)
+			}
+
+			got, err := a.ValidateToken(tokenStr)
+			if err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+
+			now := clock.Now()
+			if got.IssuedAt != now.Unix() {
+				t.Errorf("iat is %v, want %v", time.Unix(got.IssuedAt, 0).UTC(), now)
+			}
+			if want := now.Add(tt.ttl).Unix(); got.ExpiresAt != want || got.ExpiresAt-got.IssuedAt != int64(tt.ttl/time.Second) {
+				t.Errorf("exp is %v, want %v", time.Unix(got.ExpiresAt, 0).UTC(), now.Add(tt.ttl))
+			}
+		})
+	}
+}
+
+func TestGenerateTokenNoExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		err  error
+	}{
+		{name: "rejected", err: ErrNoExpiry},
+		{name: "allowed", opts: []Option{AllowNoExpiry()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t, tt.opts...)
+
+			claims := newClaims("user")
+			claims.ExpiresAt = 0
+
+			tokenStr, err := a.GenerateToken(claims)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err is %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("generating token: %v", err)
+			}
+
+			got, err := a.ValidateToken(tokenStr)
+			if err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+			if got.ExpiresAt != 0 {
+				t.Errorf("exp is %v, want none", time.Unix(got.ExpiresAt, 0).UTC())
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	claims := newClaims("user", RoleUser)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		ok   bool
+	}{
+		{name: "claims", ctx: context.WithValue(context.Background(), Key, claims), ok: true},
+		{name: "empty", ctx: context.Background()},
+		{name: "pointer", ctx: context.WithValue(context.Background(), Key, &claims)},
+		{name: "untyped key", ctx: context.WithValue(context.Background(), 1, claims)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FromContext(tt.ctx)
+			if ok != tt.ok {
+				t.Fatalf("ok is %v, want %v", ok, tt.ok)
+			}
+			if ok && got.Subject != claims.Subject {
+				t.Errorf("subject is %q, want %q", got.Subject, claims.Subject)
+			}
+
+			func() {
+				defer func() {
+					if r := recover(); (r != nil) == tt.ok {
+						t.Errorf("MustFromContext panicked with %v", r)
+					}
+				}()
+				if got := MustFromContext(tt.ctx); got.Subject != claims.Subject {
+					t.Errorf("subject is %q, want %q", got.Subject, claims.Subject)
+				}
+			}()
+		})
+	}
+}
+
+func TestHasAllRoles(t *testing.T) {
+	tests := []struct {
+		name  string
+		has   []string
+		roles []string
+		want  bool
+	}{
+		{name: "all", has: []string{RoleAdmin, RoleUser}, roles: []string{RoleAdmin, RoleUser}, want: true},
+		{name: "subset", has: []string{RoleAdmin, RoleUser}, roles: []string{RoleUser}, want: true},
+		{name: "one missing", has: []string{RoleUser}, roles: []string{RoleAdmin, RoleUser}},
+		{name: "none held", roles: []string{RoleUser}},
+		{name: "no roles asked", has: []string{RoleUser}},
+		{name: "case differs", has: []string{"admin"}, roles: []string{RoleAdmin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := newClaims("user", tt.has...)
+			if got := claims.HasAllRoles(tt.roles...); got != tt.want {
+				t.Errorf("HasAllRoles is %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasAnyRole(t *testing.T) {
+	tests := []struct {
+		name  string
+		has   []string
+		roles []string
+		want  bool
+	}{
+		{name: "one of", has: []string{RoleUser}, roles: []string{RoleAdmin, RoleUser}, want: true},
+		{name: "all", has: []string{RoleAdmin, RoleUser}, roles: []string{RoleAdmin, RoleUser}, want: true},
+		{name: "none match", has: []string{RoleUser}, roles: []string{RoleAdmin}},
+		{name: "none held", roles: []string{RoleUser}},
+		{name: "no roles asked", has: []string{RoleUser}},
+		{name: "case differs", has: []string{"admin"}, roles: []string{RoleAdmin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := newClaims("user", tt.has...)
+			if got := claims.HasAnyRole(tt.roles...); got != tt.want {
+				t.Errorf("HasAnyRole is %v, want %v", got, tt.want)
+			}
+			if got := claims.Authorized(tt.roles...); got != tt.want {
+				t.Errorf("Authorized is %v, want %v", got, tt.want)
+			}
+			if len(tt.roles) == 1 {
+				if got := claims.HasRole(tt.roles[0]); got != tt.want {
+					t.Errorf("HasRole is %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkValidateToken(b *testing.B) {
+	a := newTestAuth(b)
+	tokenStr := mustGenerate(b, a, newClaims("user", RoleAdmin, RoleUser))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := a.ValidateToken(tokenStr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
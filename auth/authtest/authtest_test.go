@@ -0,0 +1,80 @@
+package authtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mromero1591/web-foundation/auth"
+)
+
+func TestNewTestAuth(t *testing.T) {
+	now := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	clock := func() time.Time { return now }
+
+	tests := []struct {
+		name      string
+		opts      []auth.Option
+		issuedAt  time.Time
+		expiresAt time.Time
+		wantIat   time.Time
+		wantExp   time.Time
+	}{
+		{name: "default clock"},
+		{name: "auth clock", opts: []auth.Option{auth.WithClock(clock)}, wantIat: now, wantExp: now.Add(time.Hour)},
+		{
+			name:     "issued at",
+			opts:     []auth.Option{auth.WithClock(clock)},
+			issuedAt: now.Add(-30 * time.Minute),
+			wantIat:  now.Add(-30 * time.Minute),
+			wantExp:  now.Add(30 * time.Minute),
+		},
+		{
+			name:      "expires at",
+			opts:      []auth.Option{auth.WithClock(clock)},
+			expiresAt: now.Add(time.Minute),
+			wantIat:   now,
+			wantExp:   now.Add(time.Minute),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, mint := NewTestAuth(tt.opts...)
+
+			var claims auth.Claims
+			claims.Subject = "user"
+			if !tt.issuedAt.IsZero() {
+				claims.IssuedAt = tt.issuedAt.Unix()
+			}
+			if !tt.expiresAt.IsZero() {
+				claims.ExpiresAt = tt.expiresAt.Unix()
+			}
+
+			got, err := a.ValidateToken(mint(claims))
+			if err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+
+			if !tt.wantIat.IsZero() && got.IssuedAt != tt.wantIat.Unix() {
+				t.Errorf("iat is %v, want %v", time.Unix(got.IssuedAt, 0), tt.wantIat)
+			}
+			if !tt.wantExp.IsZero() && got.ExpiresAt != tt.wantExp.Unix() {
+				t.Errorf("exp is %v, want %v", time.Unix(got.ExpiresAt, 0), tt.wantExp)
+			}
+		})
+	}
+}
+
+func TestContextWithClaims(t *testing.T) {
+	var claims auth.Claims
+	claims.Subject = "user"
+
+	got, ok := auth.FromContext(ContextWithClaims(context.Background(), claims))
+	if !ok {
+		t.Fatal("no claims in the context")
+	}
+	if got.Subject != claims.Subject {
+		t.Errorf("subject is %q, want %q", got.Subject, claims.Subject)
+	}
+}
@@ -0,0 +1,41 @@
+package authtest_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/mromero1591/web-foundation/auth"
+	"github.com/mromero1591/web-foundation/auth/authtest"
+)
+
+func ExampleNewTestAuth() {
+	a, mint := authtest.NewTestAuth()
+
+	h := a.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := auth.MustFromContext(r.Context())
+		fmt.Fprint(w, "hello ", claims.Subject)
+	}))
+
+	var claims auth.Claims
+	claims.Subject = "jane"
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+mint(claims))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	fmt.Println(w.Code, w.Body.String())
+	// Output: 200 hello jane
+}
+
+func ExampleContextWithClaims() {
+	var claims auth.Claims
+	claims.Subject = "jane"
+
+	ctx := authtest.ContextWithClaims(context.Background(), claims)
+
+	fmt.Println(auth.MustFromContext(ctx).Subject)
+	// Output: jane
+}
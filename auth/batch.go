@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// ValidationResult is the outcome of validating one token in ValidateBatch.
+type ValidationResult struct {
+	Claims Claims
+	Err    error
+}
+
+// ValidateBatch validates the tokens in parallel on a pool of GOMAXPROCS
+// workers. The results are in the same order as the tokens. Tokens not yet
+// validated when ctx is cancelled get the error of the context.
+func (a *Auth) ValidateBatch(ctx context.Context, tokens []string) []ValidationResult {
+	results := make([]ValidationResult, len(tokens))
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(tokens) {
+		workers = len(tokens)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i].Claims, results[i].Err = a.ValidateTokenContext(ctx, tokens[i])
+			}
+		}()
+	}
+
+	for i := range tokens {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestValidateBatch(t *testing.T) {
+	a := newTestAuth(t)
+
+	tokens := make([]string, 20)
+	for i := range tokens {
+		tokens[i] = mustGenerate(t, a, newClaims(fmt.Sprintf("user-%d", i)))
+	}
+	tokens[7] = "invalid"
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		tokens []string
+		err    func(i int) error
+	}{
+		{
+			name:   "mixed",
+			ctx:    context.Background(),
+			tokens: tokens,
+			err: func(i int) error {
+				if i == 7 {
+					return ErrTokenMalformed
+				}
+				return nil
+			},
+		},
+		{name: "empty", ctx: context.Background()},
+		{name: "cancelled", ctx: cancelled, tokens: tokens, err: func(int) error { return context.Canceled }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := a.ValidateBatch(tt.ctx, tt.tokens)
+			if len(results) != len(tt.tokens) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.tokens))
+			}
+
+			for i, r := range results {
+				want := tt.err(i)
+				if want == nil {
+					if r.Err != nil {
+						t.Errorf("token %d: %v", i, r.Err)
+					}
+					if subject := fmt.Sprintf("user-%d", i); r.Claims.Subject != subject {
+						t.Errorf("token %d has subject %q, want %q", i, r.Claims.Subject, subject)
+					}
+					continue
+				}
+				if !errors.Is(r.Err, want) {
+					t.Errorf("token %d: error is %v, want %v", i, r.Err, want)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkValidateBatch(b *testing.B) {
+	a, _ := newRSASigner(b, "k1")
+
+	tokens := make([]string, 100)
+	for i := range tokens {
+		tokens[i] = mustGenerate(b, a, newClaims(fmt.Sprintf("user-%d", i)))
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, tokenStr := range tokens {
+				if _, err := a.ValidateToken(tokenStr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, r := range a.ValidateBatch(context.Background(), tokens) {
+				if r.Err != nil {
+					b.Fatal(r.Err)
+				}
+			}
+		}
+	})
+}
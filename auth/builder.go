@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoSubject is returned by ClaimsBuilder.BuildE when neither a subject
+// nor a username was set.
+var ErrNoSubject = errors.New("claims require a subject or username")
+
+// ClaimsBuilder constructs Claims fluently:
+//
+//	claims, err := auth.NewClaimsBuilder().
+//		WithSubject(userID).
+//		WithRoles(auth.RoleUser).
+//		WithExpiry(time.Hour).
+//		BuildE()
+type ClaimsBuilder struct {
+	claims Claims
+	ttl    time.Duration
+	exp    time.Time
+	nbf    time.Time
+	clock  func() time.Time
+}
+
+// NewClaimsBuilder constructs a ClaimsBuilder that uses time.Now as its
+// clock.
+func NewClaimsBuilder() *ClaimsBuilder {
+	return &ClaimsBuilder{
+		clock: time.Now,
+	}
+}
+
+// WithClock replaces the clock used to stamp the time based claims.
+func (b *ClaimsBuilder) WithClock(clock func() time.Time) *ClaimsBuilder {
+	b.clock = clock
+	return b
+}
+
+// WithSubject sets the subject (sub) claim.
+func (b *ClaimsBuilder) WithSubject(sub string) *ClaimsBuilder {
+	b.claims.Subject = sub
+	return b
+}
+
+// WithUsername sets the username claim.
+func (b *ClaimsBuilder) WithUsername(username string) *ClaimsBuilder {
+	b.claims.UserName = username
+	return b
+}
+
+// WithName sets the name claim.
+func (b *ClaimsBuilder) WithName(name string) *ClaimsBuilder {
+	b.claims.Name = name
+	return b
+}
+
+// WithRoles sets the roles claim.
+func (b *ClaimsBuilder) WithRoles(roles ...string) *ClaimsBuilder {
+	b.claims.Roles = append([]string(nil), roles...)
+	return b
+}
+
+// WithAudience sets the audience (aud) claim to one or more audiences.
+func (b *ClaimsBuilder) WithAudience(aud ...string) *ClaimsBuilder {
+	b.claims.Audience = append(ClaimStrings(nil), aud...)
+	return b
+}
+
+// WithFingerprint binds the claims to a client fingerprint, see
+// Auth.ValidateTokenBound.
+func (b *ClaimsBuilder) WithFingerprint(fingerprint string) *ClaimsBuilder {
+	b.claims.Fingerprint = fingerprint
+	return b
+}
+
+// WithTokenType sets the purpose of the token (typ), see
+// Auth.ValidateTokenOfType.
+func (b *ClaimsBuilder) WithTokenType(typ string) *ClaimsBuilder {
+	b.claims.TokenType = typ
+	return b
+}
+
+// WithExpiry makes the claims expire ttl after they are built.
+func (b *ClaimsBuilder) WithExpiry(ttl time.Duration) *ClaimsBuilder {
+	b.ttl = ttl
+	b.exp = time.Time{}
+	return b
+}
+
+// ExpiresAt makes the claims expire at t.
+func (b *ClaimsBuilder) ExpiresAt(t time.Time) *ClaimsBuilder {
+	b.exp = t
+	b.ttl = 0
+	return b
+}
+
+// NotBefore makes the claims valid only from t on, for example to schedule
+// access. ValidateToken rejects the token with ErrTokenNotYetValid until then.
+func (b *ClaimsBuilder) NotBefore(t time.Time) *ClaimsBuilder {
+	b.nbf = t
+	return b
+}
+
+// Build returns the claims with iat set to the current time of the builder's
+// clock. The nbf is set to the time given to NotBefore, or to the current
+// time. Use BuildE to also validate the claims.
+func (b *ClaimsBuilder) Build() Claims {
+	now := b.clock()
+
+	claims := cloneClaims(b.claims)
+	claims.IssuedAt = now.Unix()
+	claims.NotBefore = now.Unix()
+	if !b.nbf.IsZero() {
+		claims.NotBefore = b.nbf.Unix()
+	}
+
+	switch {
+	case !b.exp.IsZero():
+		claims.ExpiresAt = b.exp.Unix()
+	case b.ttl != 0:
+		claims.ExpiresAt = now.Add(b.ttl).Unix()
+	}
+
+	return claims
+}
+
+// BuildE is Build but returns ErrNoSubject when neither a subject nor a
+// username was set.
+func (b *ClaimsBuilder) BuildE() (Claims, error) {
+	if b.claims.Subject == "" && b.claims.UserName == "" {
+		return Claims{}, ErrNoSubject
+	}
+
+	return b.Build(), nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestClaimsBuilderNotBefore(t *testing.T) {
+	tests := []struct {
+		name    string
+		nbf     time.Time
+		advance time.Duration
+		opts    []Option
+		err     error
+	}{
+		{name: "default nbf"},
+		{name: "past nbf", nbf: testNow.Add(-time.Minute)},
+		{name: "future nbf", nbf: testNow.Add(10 * time.Minute), err: ErrTokenNotYetValid},
+		{name: "nbf reached", nbf: testNow.Add(10 * time.Minute), advance: 10 * time.Minute},
+		{name: "before nbf", nbf: testNow.Add(10 * time.Minute), advance: 9 * time.Minute, err: ErrTokenNotYetValid},
+		{name: "within leeway", nbf: testNow.Add(10 * time.Minute), advance: 9 * time.Minute, opts: []Option{WithLeeway(time.Minute)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, append([]Option{WithClock(clock.Now)}, tt.opts...)...)
+
+			b := NewClaimsBuilder().WithClock(clock.Now).WithSubject("user").WithExpiry(time.Hour)
+			if !tt.nbf.IsZero() {
+				b.NotBefore(tt.nbf)
+			}
+			claims := b.Build()
+
+			want := testNow
+			if !tt.nbf.IsZero() {
+				want = tt.nbf
+			}
+			if claims.NotBefore != want.Unix() {
+				t.Errorf("nbf is %v, want %v", time.Unix(claims.NotBefore, 0), want)
+			}
+
+			tokenStr := mustGenerate(t, a, claims)
+			clock.Advance(tt.advance)
+
+			_, err := a.ValidateToken(tokenStr)
+			if tt.err == nil && err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error is %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestGenerateTokenNotBeforeAfterExpiry(t *testing.T) {
+	a := newTestAuth(t)
+
+	claims := NewClaimsBuilder().
+		WithClock(func() time.Time { return testNow }).
+		WithSubject("user").
+		WithExpiry(time.Hour).
+		NotBefore(testNow.Add(time.Hour)).
+		Build()
+
+	if _, err := a.GenerateToken(claims); err == nil {
+		t.Error("token valid from its expiry was generated")
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// WithValidationCache enables a cache of up to size verified tokens so
+// repeated validations of the same token skip the signature verification
+// and JSON decoding. The time based claims, revocation and custom validators
+// are still checked on every validation, so a cached token stops validating
+// as soon as it expires or is revoked. Tokens are cached for at most
+// validationCacheTTL and never past their expiration, and the cache is
+// cleared when a key is removed, by RemoveKey or a JWKS refresh, so tokens
+// signed with the key stop validating. The least recently used token is
+// evicted when the cache is full.
+func WithValidationCache(size int) Option {
+	return func(a *Auth) {
+		if size > 0 {
+			a.cache = newValidationCache(size)
+		}
+	}
+}
+
+// validationCacheTTL is the longest a verified token is cached.
+const validationCacheTTL = 5 * time.Minute
+
+// cacheEntry is the value stored in each element of the LRU list.
+type cacheEntry struct {
+	key     [sha256.Size]byte
+	claims  Claims
+	token   *jwt.Token
+	expires time.Time
+}
+
+// validationCache is an LRU cache of verified claims keyed by the hash of
+// the token. It is safe for concurrent use. The generation is incremented
+// each time the cache is cleared, so tokens verified with a key removed in
+// the meantime aren't added back.
+type validationCache struct {
+	mu      sync.Mutex
+	size    int
+	gen     uint64
+	lru     *list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+// newValidationCache constructs a validationCache holding up to size tokens.
+func newValidationCache(size int) *validationCache {
+	return &validationCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element, size),
+	}
+}
+
+// get returns a copy of the cached claims and the parsed token. Entries
+// expired at now are removed.
+func (c *validationCache) get(tokenStr string, now time.Time) (Claims, *jwt.Token, bool) {
+	key := sha256.Sum256([]byte(tokenStr))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[key]
+	if !exists {
+		return Claims{}, nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if !now.Before(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return Claims{}, nil, false
+	}
+	c.lru.MoveToFront(elem)
+
+	return cloneClaims(entry.claims), entry.token, true
+}
+
+// generation returns the current generation of the cache.
+func (c *validationCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gen
+}
+
+// add caches a copy of the claims and the parsed token, verified at now
+// while the cache was at generation gen. The entry expires after
+// validationCacheTTL, or at the expiration of the token when sooner.
+func (c *validationCache) add(tokenStr string, claims Claims, token *jwt.Token, gen uint64, now time.Time) {
+	expires := now.Add(validationCacheTTL)
+	if claims.ExpiresAt != 0 {
+		if exp := time.Unix(claims.ExpiresAt, 0); exp.Before(expires) {
+			expires = exp
+		}
+	}
+	if !now.Before(expires) {
+		return
+	}
+
+	key := sha256.Sum256([]byte(tokenStr))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	if elem, exists := c.entries[key]; exists {
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, claims: cloneClaims(claims), token: token, expires: expires})
+
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// clear removes every cached token.
+func (c *validationCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.lru.Init()
+	c.entries = make(map[[sha256.Size]byte]*list.Element, c.size)
+}
+
+// clearCache clears the validation cache, if enabled, after a key was
+// removed.
+func (a *Auth) clearCache() {
+	if a.cache != nil {
+		a.cache.clear()
+	}
+}
+
+// cloneClaims returns a deep copy of the claims so callers can't modify the
+// cached value.
+func cloneClaims(c Claims) Claims {
+	if c.Roles != nil {
+		c.Roles = append([]string(nil), c.Roles...)
+	}
+	if c.Audience != nil {
+		c.Audience = append(ClaimStrings(nil), c.Audience...)
+	}
+	if c.AMR != nil {
+		c.AMR = append([]string(nil), c.AMR...)
+	}
+	if c.Extra != nil {
+		extra := make(map[string]interface{}, len(c.Extra))
+		for k, v := range c.Extra {
+			extra[k] = v
+		}
+		c.Extra = extra
+	}
+	if c.Actor != nil {
+		actor := cloneClaims(*c.Actor)
+		c.Actor = &actor
+	}
+	return c
+}
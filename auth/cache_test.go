@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+)
+
+// cachedExpiry returns when the cache entry for the token expires, and false
+// when it isn't cached.
+func cachedExpiry(a *Auth, tokenStr string) (time.Time, bool) {
+	a.cache.mu.Lock()
+	defer a.cache.mu.Unlock()
+
+	elem, exists := a.cache.entries[sha256.Sum256([]byte(tokenStr))]
+	if !exists {
+		return time.Time{}, false
+	}
+	return elem.Value.(*cacheEntry).expires, true
+}
+
+func TestValidationCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		exp      time.Duration
+		lifetime time.Duration
+		advance  time.Duration
+		cached   bool
+	}{
+		{name: "capped by ttl", exp: time.Hour, lifetime: validationCacheTTL, advance: validationCacheTTL - time.Second, cached: true},
+		{name: "capped by exp", exp: time.Minute, lifetime: time.Minute, advance: time.Minute - time.Second, cached: true},
+		{name: "ttl elapsed", exp: time.Hour, lifetime: validationCacheTTL, advance: validationCacheTTL},
+		{name: "token expired", exp: time.Minute, lifetime: time.Minute, advance: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, WithClock(clock.Now), WithValidationCache(10))
+
+			claims := newClaims("user")
+			claims.ExpiresAt = testNow.Add(tt.exp).Unix()
+			tokenStr := mustGenerate(t, a, claims)
+
+			if _, err := a.ValidateToken(tokenStr); err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+			expires, ok := cachedExpiry(a, tokenStr)
+			if want := testNow.Add(tt.lifetime); !ok || !expires.Equal(want) {
+				t.Fatalf("cache entry expires at %v (cached %v), want %v", expires, ok, want)
+			}
+
+			clock.Advance(tt.advance)
+			if _, _, ok := a.cache.get(tokenStr, clock.Now()); ok != tt.cached {
+				t.Errorf("token cached is %v, want %v", ok, tt.cached)
+			}
+			if _, ok := cachedExpiry(a, tokenStr); ok != tt.cached {
+				t.Errorf("entry kept is %v, want %v", ok, tt.cached)
+			}
+		})
+	}
+}
+
+func TestValidationCacheRemoveKey(t *testing.T) {
+	a, err := NewWithKeys(map[string]string{
+		"k1": "first-secret-with-enough-entropy-for-hs256",
+		"k2": "second-secret-with-enough-entropy-for-hs256",
+	}, "k1", "HS256", WithClock(func() time.Time { return testNow }), WithValidationCache(10))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	old := mustGenerate(t, a, newClaims("user"))
+	if err := a.SetActiveKey("k2", "second-secret-with-enough-entropy-for-hs256"); err != nil {
+		t.Fatalf("rotating key: %v", err)
+	}
+	current := mustGenerate(t, a, newClaims("user"))
+
+	for _, tokenStr := range []string{old, current} {
+		if _, err := a.ValidateToken(tokenStr); err != nil {
+			t.Fatalf("validating token: %v", err)
+		}
+	}
+
+	if err := a.RemoveKey("k1"); err != nil {
+		t.Fatalf("removing key: %v", err)
+	}
+
+	if _, err := a.ValidateToken(old); err == nil {
+		t.Error("token signed with the removed key was accepted")
+	}
+	if _, err := a.ValidateToken(current); err != nil {
+		t.Errorf("token signed with the active key was rejected: %v", err)
+	}
+}
+
+func TestValidationCacheJWKSKeyRemoved(t *testing.T) {
+	signer1, key1 := newRSASigner(t, "k1")
+	signer2, key2 := newRSASigner(t, "k2")
+	token1 := mustGenerate(t, signer1, newClaims("user"))
+	token2 := mustGenerate(t, signer2, newClaims("user"))
+
+	s := newJWKSServer(t, key1, key2)
+	a := newJWKSAuth(t, s, WithValidationCache(10))
+
+	for _, tokenStr := range []string{token1, token2} {
+		if _, err := a.ValidateToken(tokenStr); err != nil {
+			t.Fatalf("validating token: %v", err)
+		}
+	}
+
+	// A refresh keeping every key keeps the cache.
+	if err := a.jwks.fetch(context.Background()); err != nil {
+		t.Fatalf("refreshing key set: %v", err)
+	}
+	if n := a.cache.lru.Len(); n != 2 {
+		t.Errorf("%d tokens cached after an unchanged refresh, want 2", n)
+	}
+
+	// The key set is still fresh after k1 is dropped, but its tokens must
+	// not be served from the cache.
+	s.setKeys(key2)
+	if err := a.jwks.fetch(context.Background()); err != nil {
+		t.Fatalf("refreshing key set: %v", err)
+	}
+
+	if _, err := a.ValidateToken(token1); err == nil {
+		t.Error("token signed with the removed key was accepted")
+	}
+	if _, err := a.ValidateToken(token2); err != nil {
+		t.Errorf("token signed with the remaining key was rejected: %v", err)
+	}
+}
+
+func TestValidationCacheIgnoresStaleGeneration(t *testing.T) {
+	c := newValidationCache(10)
+
+	gen := c.generation()
+	c.clear()
+	c.add("token", newClaims("user"), nil, gen, testNow)
+
+	if _, _, ok := c.get("token", testNow); ok {
+		t.Error("token verified before the cache was cleared was added")
+	}
+}
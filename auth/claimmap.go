@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WithRoleClaim reads Claims.Roles from the claim at path instead of "roles".
+// The path may be dotted to reach into nested objects, as in
+// "realm_access.roles" for Keycloak. The claim may hold an array of strings
+// or a single string.
+func WithRoleClaim(path string) Option {
+	return func(a *Auth) {
+		a.roleClaim = path
+	}
+}
+
+// WithUsernameClaim reads Claims.UserName from the claim at path instead of
+// "username", as in "preferred_username" for OpenID Connect providers. The
+// path may be dotted like for WithRoleClaim.
+func WithUsernameClaim(path string) Option {
+	return func(a *Auth) {
+		a.usernameClaim = path
+	}
+}
+
+// mapClaims fills the claims configured with WithRoleClaim,
+// WithUsernameClaim and WithKeycloakRoles from the decoded payload of the
+// token. Claims missing from the payload are left empty.
+func (a *Auth) mapClaims(payload []byte, claims *Claims) error {
+	if a.roleClaim == "" && a.usernameClaim == "" && !a.keycloak {
+		return nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return errors.Wrap(ErrTokenMalformed, "decoding payload")
+	}
+
+	if a.roleClaim != "" {
+		claims.Roles = nil
+		switch v := claimAt(m, a.roleClaim).(type) {
+		case string:
+			claims.Roles = []string{v}
+		case []interface{}:
+			for _, role := range v {
+				if s, ok := role.(string); ok {
+					claims.Roles = append(claims.Roles, s)
+				}
+			}
+		}
+	}
+
+	if a.usernameClaim != "" {
+		claims.UserName, _ = claimAt(m, a.usernameClaim).(string)
+	}
+
+	if a.keycloak {
+		var kc KeycloakClaims
+		if err := json.Unmarshal(payload, &kc); err != nil {
+			return errors.Wrap(ErrTokenMalformed, "decoding keycloak roles")
+		}
+		claims.Roles = append(claims.Roles, kc.FlattenRoles(a.keycloakClients...)...)
+	}
+
+	return nil
+}
+
+// claimAt returns the value at the dotted path in the claims, or nil.
+func claimAt(m map[string]interface{}, path string) interface{} {
+	var v interface{} = m
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithRoleClaim(t *testing.T) {
+	times := fmt.Sprintf(`"iat":%d,"exp":%d`, testNow.Unix(), testNow.Add(time.Hour).Unix())
+
+	tests := []struct {
+		name    string
+		path    string
+		payload string
+		roles   []string
+	}{
+		{
+			name:    "default claim",
+			payload: `{"sub":"user","roles":["USER"],` + times + `}`,
+			roles:   []string{RoleUser},
+		},
+		{
+			name:    "custom claim",
+			path:    "groups",
+			payload: `{"sub":"user","groups":["USER","ADMIN"],` + times + `}`,
+			roles:   []string{RoleUser, RoleAdmin},
+		},
+		{
+			name:    "single string",
+			path:    "group",
+			payload: `{"sub":"user","group":"ADMIN",` + times + `}`,
+			roles:   []string{RoleAdmin},
+		},
+		{
+			name:    "nested claim",
+			path:    "realm_access.roles",
+			payload: `{"sub":"user","realm_access":{"roles":["USER"]},` + times + `}`,
+			roles:   []string{RoleUser},
+		},
+		{
+			name:    "non-string roles skipped",
+			path:    "groups",
+			payload: `{"sub":"user","groups":["USER",1,null],` + times + `}`,
+			roles:   []string{RoleUser},
+		},
+		{
+			name:    "missing claim",
+			path:    "groups",
+			payload: `{"sub":"user","roles":["ADMIN"],` + times + `}`,
+		},
+		{
+			name:    "missing nested claim",
+			path:    "realm_access.roles",
+			payload: `{"sub":"user","realm_access":"USER",` + times + `}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.path != "" {
+				opts = append(opts, WithRoleClaim(tt.path))
+			}
+			a := newTestAuth(t, opts...)
+
+			claims, err := a.ValidateToken(signPayload(t, tt.payload))
+			if err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+			if !reflect.DeepEqual(claims.Roles, tt.roles) {
+				t.Errorf("roles are %q, want %q", claims.Roles, tt.roles)
+			}
+
+			// A token missing the claim holds no roles at all, whatever the
+			// "roles" claim says.
+			if len(tt.roles) == 0 && a.Authorized(claims, RoleAdmin) {
+				t.Error("claims are authorized for ADMIN")
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"io"
+	"strings"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// maxInflatedPayload caps the size a compressed payload may decompress to,
+// so a small token can't expand into a decompression bomb.
+const maxInflatedPayload = 256 << 10
+
+// WithCompression compresses the payload of generated tokens with DEFLATE
+// and marks them with the "zip":"DEF" header, for tokens carrying so many
+// roles or scopes they get too large for some proxies. Compressed tokens are
+// always accepted by ValidateToken, whether the option is set or not, but
+// other JWT libraries may not understand them.
+func WithCompression() Option {
+	return func(a *Auth) {
+		a.compress = true
+	}
+}
+
+// signingInput returns the header and payload of the token as they are
+// signed, compressing the payload when WithCompression is set.
+func (a *Auth) signingInput(token *jwt.Token) (string, error) {
+	if !a.compress {
+		return token.SigningString()
+	}
+
+	token.Header["zip"] = "DEF"
+
+	header, err := json.Marshal(token.Header)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(token.Claims)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return jwt.EncodeSegment(header) + "." + jwt.EncodeSegment(buf.Bytes()), nil
+}
+
+// splitToken splits the token into its three segments and decodes the
+// header.
+func splitToken(tokenStr string) ([]string, map[string]interface{}, error) {
+	parts := strings.Split(tokenStr, ".")
+	if len(parts) != 3 {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	data, err := jwt.DecodeSegment(parts[0])
+	if err != nil {
+		return nil, nil, errors.Wrap(ErrTokenMalformed, "decoding header")
+	}
+
+	var header map[string]interface{}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, nil, errors.Wrap(ErrTokenMalformed, "decoding header")
+	}
+
+	return parts, header, nil
+}
+
+// isCompressed returns true if the header marks the payload as compressed.
+// Compression algorithms other than DEFLATE are rejected.
+func isCompressed(header map[string]interface{}) (bool, error) {
+	zip, exists := header["zip"]
+	if !exists {
+		return false, nil
+	}
+
+	if zip != "DEF" {
+		return false, errors.Wrapf(ErrTokenMalformed, "unsupported compression %v", zip)
+	}
+
+	return true, nil
+}
+
+// decodePayload decodes the payload segment of a token with the header,
+// decompressing it when the header says it is compressed.
+func decodePayload(header map[string]interface{}, segment string) ([]byte, error) {
+	compressed, err := isCompressed(header)
+	if err != nil {
+		return nil, err
+	}
+
+	if compressed {
+		return inflate(segment)
+	}
+
+	payload, err := jwt.DecodeSegment(segment)
+	if err != nil {
+		return nil, errors.Wrap(ErrTokenMalformed, "decoding payload")
+	}
+
+	return payload, nil
+}
+
+// inflate decodes and decompresses a payload segment, refusing payloads
+// larger than maxInflatedPayload.
+func inflate(segment string) ([]byte, error) {
+	data, err := jwt.DecodeSegment(segment)
+	if err != nil {
+		return nil, errors.Wrap(ErrTokenMalformed, "decoding payload")
+	}
+
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	payload, err := io.ReadAll(io.LimitReader(r, maxInflatedPayload+1))
+	if err != nil {
+		return nil, errors.Wrap(ErrTokenMalformed, "decompressing payload")
+	}
+	if len(payload) > maxInflatedPayload {
+		return nil, errors.Wrap(ErrTokenMalformed, "decompressed payload too large")
+	}
+
+	return payload, nil
+}
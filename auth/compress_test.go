@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// manyRoles returns n distinct roles, as carried by users of large
+// organisations.
+func manyRoles(n int) []string {
+	roles := make([]string, n)
+	for i := range roles {
+		roles[i] = fmt.Sprintf("org:engineering:team-%02d:maintainer", i)
+	}
+	return roles
+}
+
+// deflate compresses the data like WithCompression does.
+func deflate(t testing.TB, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		t.Fatalf("compressing: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("compressing: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("compressing: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// signSegments returns an HS256 token signed with testSecret for the raw
+// header and payload segment.
+func signSegments(t testing.TB, header string, payload []byte) string {
+	t.Helper()
+
+	input := jwt.EncodeSegment([]byte(header)) + "." + jwt.EncodeSegment(payload)
+	sig, err := jwt.SigningMethodHS256.Sign(input, []byte(testSecret))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+
+	return input + "." + sig
+}
+
+func TestCompression(t *testing.T) {
+	compressed := newTestAuth(t, WithCompression())
+	plain := newTestAuth(t)
+
+	claims := newClaims("user", manyRoles(50)...)
+	exp := fmt.Sprintf(`{"sub":"user","exp":%d}`, claims.ExpiresAt)
+
+	tests := []struct {
+		name     string
+		validate *Auth
+		tokenStr string
+		err      error
+	}{
+		{name: "compressed", validate: compressed, tokenStr: mustGenerate(t, compressed, claims)},
+		{name: "compressed without option", validate: plain, tokenStr: mustGenerate(t, compressed, claims)},
+		{name: "plain with option", validate: compressed, tokenStr: mustGenerate(t, plain, claims)},
+		{
+			name:     "unsupported compression",
+			validate: compressed,
+			tokenStr: signSegments(t, `{"alg":"HS256","zip":"GZIP"}`, deflate(t, []byte(exp))),
+			err:      ErrTokenMalformed,
+		},
+		{
+			name:     "not deflated",
+			validate: compressed,
+			tokenStr: signSegments(t, `{"alg":"HS256","zip":"DEF"}`, []byte(exp)),
+			err:      ErrTokenMalformed,
+		},
+		{
+			name:     "decompression bomb",
+			validate: compressed,
+			tokenStr: signSegments(t, `{"alg":"HS256","zip":"DEF"}`, deflate(t, bytes.Repeat([]byte(" "), maxInflatedPayload+1))),
+			err:      ErrTokenMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.validate.ValidateToken(tt.tokenStr)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error is %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+			if len(got.Roles) != len(claims.Roles) {
+				t.Errorf("token has %d roles, want %d", len(got.Roles), len(claims.Roles))
+			}
+		})
+	}
+
+	if c, p := mustGenerate(t, compressed, claims), mustGenerate(t, plain, claims); len(c) >= len(p) {
+		t.Errorf("compressed token is %d bytes, plain one %d", len(c), len(p))
+	}
+}
+
+func BenchmarkCompression(b *testing.B) {
+	claims := newClaims("user", manyRoles(50)...)
+
+	for _, bb := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "plain"},
+		{name: "compressed", opts: []Option{WithCompression()}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			a := newTestAuth(b, bb.opts...)
+
+			var size int
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tokenStr := mustGenerate(b, a, claims)
+				if _, err := a.ValidateToken(tokenStr); err != nil {
+					b.Fatal(err)
+				}
+				size = len(tokenStr)
+			}
+			b.ReportMetric(float64(size), "bytes/token")
+		})
+	}
+}
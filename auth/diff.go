@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Equal returns true if the claims carry the same values as other. The order
+// of the roles, scopes, audiences and authentication methods doesn't matter.
+func (c Claims) Equal(other Claims) bool {
+	return len(c.Diff(other)) == 0
+}
+
+// Diff describes how other differs from the claims, one change per entry,
+// such as `roles added: ADMIN` or `sub changed from "a" to "b"`. It returns
+// nil when they are equal. The order of the roles, scopes, audiences and
+// authentication methods doesn't matter.
+func (c Claims) Diff(other Claims) []string {
+	var d []string
+
+	d = diffString(d, "jti", c.Id, other.Id)
+	d = diffString(d, "sub", c.Subject, other.Subject)
+	d = diffString(d, "iss", c.Issuer, other.Issuer)
+	d = diffSet(d, "aud", c.Audience, other.Audience)
+	d = diffTime(d, "exp", c.ExpiresAt, other.ExpiresAt)
+	d = diffTime(d, "iat", c.IssuedAt, other.IssuedAt)
+	d = diffTime(d, "nbf", c.NotBefore, other.NotBefore)
+	d = diffString(d, "name", c.Name, other.Name)
+	d = diffString(d, "username", c.UserName, other.UserName)
+	d = diffSet(d, "roles", c.Roles, other.Roles)
+	d = diffSet(d, "scope", c.Scopes(), other.Scopes())
+	d = diffString(d, "typ", c.TokenType, other.TokenType)
+	d = diffString(d, "fam", c.Family, other.Family)
+	d = diffString(d, "sid", c.SessionID, other.SessionID)
+	d = diffString(d, "tid", c.TenantID, other.TenantID)
+	d = diffString(d, "fpt", c.Fingerprint, other.Fingerprint)
+	d = diffSet(d, "amr", c.AMR, other.AMR)
+	d = diffString(d, "acr", c.ACR, other.ACR)
+	d = diffString(d, "email", c.Email, other.Email)
+	if c.EmailVerified != other.EmailVerified {
+		d = append(d, fmt.Sprintf("email_verified changed from %t to %t", c.EmailVerified, other.EmailVerified))
+	}
+	d = diffString(d, "given_name", c.GivenName, other.GivenName)
+	d = diffString(d, "family_name", c.FamilyName, other.FamilyName)
+	d = diffString(d, "picture", c.Picture, other.Picture)
+
+	switch {
+	case c.Actor == nil && other.Actor != nil:
+		d = append(d, "act added")
+	case c.Actor != nil && other.Actor == nil:
+		d = append(d, "act removed")
+	case c.Actor != nil:
+		for _, change := range c.Actor.Diff(*other.Actor) {
+			d = append(d, "act."+change)
+		}
+	}
+
+	keys := make(map[string]bool)
+	for key := range c.Extra {
+		keys[key] = true
+	}
+	for key := range other.Extra {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		before, had := c.Extra[key]
+		after, has := other.Extra[key]
+		switch {
+		case !had:
+			d = append(d, fmt.Sprintf("%s added", key))
+		case !has:
+			d = append(d, fmt.Sprintf("%s removed", key))
+		case !reflect.DeepEqual(before, after):
+			d = append(d, fmt.Sprintf("%s changed from %v to %v", key, before, after))
+		}
+	}
+
+	return d
+}
+
+// diffString describes a change of a string claim.
+func diffString(d []string, name, before, after string) []string {
+	if before == after {
+		return d
+	}
+	return append(d, fmt.Sprintf("%s changed from %q to %q", name, before, after))
+}
+
+// diffTime describes a change of a numeric date claim.
+func diffTime(d []string, name string, before, after int64) []string {
+	if before == after {
+		return d
+	}
+	return append(d, fmt.Sprintf("%s changed from %d to %d", name, before, after))
+}
+
+// diffSet describes the values added to and removed from a claim holding a
+// set of strings.
+func diffSet(d []string, name string, before, after []string) []string {
+	if added := missing(after, before); len(added) > 0 {
+		d = append(d, fmt.Sprintf("%s added: %s", name, strings.Join(added, ", ")))
+	}
+	if removed := missing(before, after); len(removed) > 0 {
+		d = append(d, fmt.Sprintf("%s removed: %s", name, strings.Join(removed, ", ")))
+	}
+	return d
+}
+
+// missing returns the values of a that aren't in b.
+func missing(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, v := range b {
+		in[v] = true
+	}
+
+	var m []string
+	for _, v := range a {
+		if !in[v] {
+			m = append(m, v)
+			in[v] = true
+		}
+	}
+	return m
+}
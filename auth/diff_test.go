@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClaimsDiff(t *testing.T) {
+	base := func() Claims {
+		c := newClaims("user", RoleUser, RoleAdmin)
+		c.Audience = ClaimStrings{"api", "web"}
+		c.Scope = "read write"
+		c.Extra = map[string]interface{}{"org": "acme", "beta": true}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Claims)
+		diff   []string
+	}{
+		{name: "equal", change: func(c *Claims) {}},
+		{name: "roles reordered", change: func(c *Claims) { c.Roles = []string{RoleAdmin, RoleUser} }},
+		{name: "audience reordered", change: func(c *Claims) { c.Audience = ClaimStrings{"web", "api"} }},
+		{name: "scopes reordered", change: func(c *Claims) { c.Scope = "write read" }},
+		{
+			name:   "subject changed",
+			change: func(c *Claims) { c.Subject = "other" },
+			diff:   []string{`sub changed from "user" to "other"`},
+		},
+		{
+			name:   "expiry changed",
+			change: func(c *Claims) { c.ExpiresAt++ },
+			diff:   []string{"exp changed from 1767369845 to 1767369846"},
+		},
+		{
+			name:   "audience added",
+			change: func(c *Claims) { c.Audience = append(c.Audience, "admin") },
+			diff:   []string{"aud added: admin"},
+		},
+		{
+			name:   "audience replaced",
+			change: func(c *Claims) { c.Audience = ClaimStrings{"api", "admin"} },
+			diff:   []string{"aud added: admin", "aud removed: web"},
+		},
+		{
+			name:   "role removed",
+			change: func(c *Claims) { c.Roles = []string{RoleUser} },
+			diff:   []string{"roles removed: ADMIN"},
+		},
+		{
+			name:   "scope added",
+			change: func(c *Claims) { c.Scope = "read write delete" },
+			diff:   []string{"scope added: delete"},
+		},
+		{
+			name:   "extra added",
+			change: func(c *Claims) { c.Extra["dept"] = "sales" },
+			diff:   []string{"dept added"},
+		},
+		{
+			name:   "extra removed",
+			change: func(c *Claims) { delete(c.Extra, "beta") },
+			diff:   []string{"beta removed"},
+		},
+		{
+			name:   "extra changed",
+			change: func(c *Claims) { c.Extra["org"] = "globex" },
+			diff:   []string{"org changed from acme to globex"},
+		},
+		{
+			name:   "no extra",
+			change: func(c *Claims) { c.Extra = nil },
+			diff:   []string{"beta removed", "org removed"},
+		},
+		{
+			name:   "actor added",
+			change: func(c *Claims) { actor := newClaims("admin"); c.Actor = &actor },
+			diff:   []string{"act added"},
+		},
+		{
+			name: "several changes",
+			change: func(c *Claims) {
+				c.Subject = "other"
+				c.Roles = nil
+				c.Extra["org"] = "globex"
+			},
+			diff: []string{`sub changed from "user" to "other"`, "roles removed: USER, ADMIN", "org changed from acme to globex"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := base(), base()
+			tt.change(&after)
+
+			if d := before.Diff(after); !reflect.DeepEqual(d, tt.diff) {
+				t.Errorf("diff is %q, want %q", d, tt.diff)
+			}
+			if equal := before.Equal(after); equal != (len(tt.diff) == 0) {
+				t.Errorf("equal is %v, want %v", equal, len(tt.diff) == 0)
+			}
+		})
+	}
+}
+
+func TestClaimsDiffActor(t *testing.T) {
+	before := newClaims("user")
+	admin, support := newClaims("admin"), newClaims("support")
+	before.Actor = &admin
+	after := newClaims("user")
+	after.Actor = &support
+
+	want := []string{`act.sub changed from "admin" to "support"`}
+	if d := before.Diff(after); !reflect.DeepEqual(d, want) {
+		t.Errorf("diff is %q, want %q", d, want)
+	}
+
+	after.Actor = nil
+	want = []string{"act removed"}
+	if d := before.Diff(after); !reflect.DeepEqual(d, want) {
+		t.Errorf("diff is %q, want %q", d, want)
+	}
+}
@@ -0,0 +1,101 @@
+// Package echoauth provides Echo middleware that authenticates requests using
+// the auth package. It lives in its own package so users that don't run Echo
+// don't depend on it.
+package echoauth
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mromero1591/web-foundation/auth"
+	"github.com/pkg/errors"
+)
+
+// ClaimsKey is the echo.Context key the Claims are stored under.
+const ClaimsKey = "auth.claims"
+
+// JWT returns middleware that validates the token of the request like
+// auth.Authenticate, reading it with the extractor of the Auth and honoring
+// its FailureLimiter. The Claims are stored in the echo.Context under
+// ClaimsKey and in the request context under auth.Key, so handlers can use
+// either FromContext or auth.FromContext. Requests without a valid token are
+// handed to the unauthorized handler of the Auth when one was set with
+// auth.WithUnauthorizedHandler, and fail with echo.ErrUnauthorized otherwise.
+// Callers blocked by the FailureLimiter fail with echo.ErrTooManyRequests.
+func JWT(a *auth.Auth) echo.MiddlewareFunc {
+	m := func(next echo.HandlerFunc) echo.HandlerFunc {
+		h := func(c echo.Context) error {
+			r := c.Request()
+
+			claims, err := a.AuthenticateRequest(r)
+			if errors.Is(err, auth.ErrTooManyFailures) {
+				return echo.ErrTooManyRequests.WithInternal(err)
+			}
+			if err != nil {
+				return unauthorized(a, c, err)
+			}
+
+			c.Set(ClaimsKey, claims)
+			c.SetRequest(r.WithContext(context.WithValue(r.Context(), auth.Key, claims)))
+
+			return next(c)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// RequireRole returns middleware that only lets requests through when the
+// Claims have at least one of the roles, as checked by Auth.Authorized. It
+// must run after JWT. Requests without Claims are rejected like by JWT.
+// Requests lacking the roles are handed to the forbidden handler of the Auth
+// when one was set with auth.WithForbiddenHandler, and fail with
+// echo.ErrForbidden otherwise.
+func RequireRole(a *auth.Auth, roles ...string) echo.MiddlewareFunc {
+	m := func(next echo.HandlerFunc) echo.HandlerFunc {
+		h := func(c echo.Context) error {
+			claims, ok := FromContext(c)
+			if !ok {
+				return unauthorized(a, c, auth.ErrNoClaims)
+			}
+
+			if !a.Authorized(claims, roles...) {
+				return forbidden(a, c, auth.ErrForbidden)
+			}
+
+			return next(c)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// FromContext returns the Claims stored in the echo.Context by JWT.
+func FromContext(c echo.Context) (auth.Claims, bool) {
+	claims, ok := c.Get(ClaimsKey).(auth.Claims)
+	return claims, ok
+}
+
+// unauthorized responds with the unauthorized handler of the Auth, if any,
+// or returns echo.ErrUnauthorized.
+func unauthorized(a *auth.Auth, c echo.Context, err error) error {
+	if h := a.UnauthorizedHandler(); h != nil {
+		h(c.Response(), c.Request(), err)
+		return nil
+	}
+	return echo.ErrUnauthorized.WithInternal(err)
+}
+
+// forbidden responds with the forbidden handler of the Auth, if any, or
+// returns echo.ErrForbidden.
+func forbidden(a *auth.Auth, c echo.Context, err error) error {
+	if h := a.ForbiddenHandler(); h != nil {
+		h(c.Response(), c.Request(), err)
+		return nil
+	}
+	return echo.ErrForbidden.WithInternal(err)
+}
@@ -0,0 +1,204 @@
+package auth
+
+import "github.com/pkg/errors"
+
+// Reason describes why a token failed validation.
+type Reason int
+
+// These are the reasons a token can fail validation.
+const (
+	ReasonInvalid Reason = iota
+	ReasonExpired
+	ReasonNotYetValid
+	ReasonBadSignature
+	ReasonBadIssuer
+	ReasonBadAudience
+	ReasonRevoked
+	ReasonMalformed
+)
+
+// reasonNames are the names returned by Reason.String.
+var reasonNames = map[Reason]string{
+	ReasonInvalid:      "Invalid",
+	ReasonExpired:      "Expired",
+	ReasonNotYetValid:  "NotYetValid",
+	ReasonBadSignature: "BadSignature",
+	ReasonBadIssuer:    "BadIssuer",
+	ReasonBadAudience:  "BadAudience",
+	ReasonRevoked:      "Revoked",
+	ReasonMalformed:    "Malformed",
+}
+
+// reasonCodes are the stable snake_case codes returned by Reason.Code.
+var reasonCodes = map[Reason]string{
+	ReasonInvalid:      "invalid_token",
+	ReasonExpired:      "token_expired",
+	ReasonNotYetValid:  "token_not_yet_valid",
+	ReasonBadSignature: "bad_signature",
+	ReasonBadIssuer:    "bad_issuer",
+	ReasonBadAudience:  "bad_audience",
+	ReasonRevoked:      "token_revoked",
+	ReasonMalformed:    "malformed_token",
+}
+
+// Code returns a stable snake_case code for the reason that is suitable for
+// metric labels and machine-readable responses.
+func (r Reason) Code() string {
+	if code, exists := reasonCodes[r]; exists {
+		return code
+	}
+	return reasonCodes[ReasonInvalid]
+}
+
+// ReasonOf returns the Reason carried by a *ValidationError in the chain of
+// err, or ReasonInvalid when there is none.
+func ReasonOf(err error) Reason {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ve.Reason
+	}
+	return ReasonInvalid
+}
+
+// String implements the fmt.Stringer interface.
+func (r Reason) String() string {
+	if name, exists := reasonNames[r]; exists {
+		return name
+	}
+	return reasonNames[ReasonInvalid]
+}
+
+// ValidationError is returned by ValidateToken. It carries the Reason the
+// token was rejected so callers can use errors.As to pick a response. Its
+// message is only a safe category, like "token has invalid issuer", since the
+// details can carry values taken from the token, such as its issuer or kid,
+// that must not end up in logs or responses. The underlying error stays
+// reachable with errors.Is, Unwrap and Unredacted.
+type ValidationError struct {
+	Reason Reason
+	Err    error
+}
+
+// newValidationError wraps err in a ValidationError with the matching reason.
+func newValidationError(err error) error {
+	return &ValidationError{
+		Reason: reasonFor(err),
+		Err:    err,
+	}
+}
+
+// Error is the implementation of the error interface. It returns the safe
+// category of the error, never its details.
+func (ve *ValidationError) Error() string {
+	return categoryFor(ve.Err)
+}
+
+// Unwrap returns the underlying error.
+func (ve *ValidationError) Unwrap() error {
+	return ve.Err
+}
+
+// Unredacted returns the detailed cause of the error. Its message may contain
+// values from the token, so don't log it where tokens must not be exposed.
+func (ve *ValidationError) Unredacted() error {
+	return ve.Err
+}
+
+// Redacted returns the error as a RedactedError carrying the same safe
+// category as its message.
+func (ve *ValidationError) Redacted() *RedactedError {
+	return &RedactedError{Category: categoryFor(ve.Err), err: ve.Err}
+}
+
+// redact returns the redacted form of a *ValidationError in the chain of err,
+// or err itself when there is none.
+func redact(err error) error {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ve.Redacted()
+	}
+	return err
+}
+
+// reasonFor maps the sentinel errors to their reason.
+func reasonFor(err error) Reason {
+	switch {
+	case errors.Is(err, ErrTokenExpired):
+		return ReasonExpired
+	case errors.Is(err, ErrTokenNotYetValid):
+		return ReasonNotYetValid
+	case errors.Is(err, ErrInvalidSignature):
+		return ReasonBadSignature
+	case errors.Is(err, ErrInvalidIssuer):
+		return ReasonBadIssuer
+	case errors.Is(err, ErrInvalidAudience):
+		return ReasonBadAudience
+	case errors.Is(err, ErrTokenRevoked), errors.Is(err, ErrTokenInvalidated):
+		return ReasonRevoked
+	case errors.Is(err, ErrTokenMalformed):
+		return ReasonMalformed
+	}
+	return ReasonInvalid
+}
+
+// RedactedError hides the details of an error behind a safe category. Its
+// message is only the category, which never contains values taken from the
+// token, so it can be logged. The detailed cause is still reachable with
+// errors.Is and errors.As, and with Unredacted for a deliberate look at it.
+// Use ValidationError.Redacted to get one.
+type RedactedError struct {
+	Category string
+	err      error
+}
+
+// Error is the implementation of the error interface.
+func (re *RedactedError) Error() string {
+	return re.Category
+}
+
+// Unwrap returns the underlying error.
+func (re *RedactedError) Unwrap() error {
+	return re.err
+}
+
+// Unredacted returns the detailed cause of the error. Its message may contain
+// values from the token, so don't log it where tokens must not be exposed.
+func (re *RedactedError) Unredacted() error {
+	return re.err
+}
+
+// safeErrors are the errors whose fixed messages are safe to use as the
+// category of a RedactedError.
+var safeErrors = []error{
+	ErrTokenExpired,
+	ErrTokenNotYetValid,
+	ErrInvalidSignature,
+	ErrTokenMalformed,
+	ErrInvalidIssuer,
+	ErrInvalidAudience,
+	ErrTokenRevoked,
+	ErrTokenInvalidated,
+	ErrInvalidTokenType,
+	ErrTenantMismatch,
+	ErrFingerprintMismatch,
+	ErrTokenLifetimeTooLong,
+	ErrRefreshTokenReuse,
+	ErrUnknownToken,
+	ErrUnknownAPIKey,
+	ErrUnknownSession,
+	ErrNoEncryptionKey,
+	ErrNoExpiry,
+	ErrServiceTokenID,
+	ErrStaleKeySet,
+}
+
+// categoryFor returns the message of the first safe error in the chain of
+// err, or a generic message when there is none.
+func categoryFor(err error) string {
+	for _, safe := range safeErrors {
+		if errors.Is(err, safe) {
+			return safe.Error()
+		}
+	}
+	return "invalid token"
+}
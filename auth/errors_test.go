@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+func TestValidationErrorRedacted(t *testing.T) {
+	// crafted is placed in the token so we can check it never surfaces in
+	// the message of the error.
+	const crafted = "\x00<script>\u202e"
+
+	a := newTestAuth(t, WithIssuer("issuer"))
+	other := newTestAuth(t)
+
+	forger, err := New("another-secret", "HS256", WithIssuer("issuer"), WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badIssuer := newClaims("user")
+	badIssuer.Issuer = crafted
+
+	tests := []struct {
+		name     string
+		token    string
+		sentinel error
+		reason   Reason
+		detail   string
+	}{
+		{
+			name:     "bad issuer",
+			token:    mustGenerate(t, other, badIssuer),
+			sentinel: ErrInvalidIssuer,
+			reason:   ReasonBadIssuer,
+			detail:   `parsing token: unexpected issuer "\x00<script>\u202e": token has invalid issuer`,
+		},
+		{
+			name:     "malformed",
+			token:    crafted + ".e30.c2ln",
+			sentinel: ErrTokenMalformed,
+			reason:   ReasonMalformed,
+			detail:   "parsing token: decoding header: token is malformed",
+		},
+		{
+			name:     "bad signature",
+			token:    mustGenerate(t, forger, newClaims(crafted)),
+			sentinel: ErrInvalidSignature,
+			reason:   ReasonBadSignature,
+			detail:   "parsing token: token signature is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.ValidateToken(tt.token)
+			if err == nil {
+				t.Fatal("token was accepted")
+			}
+
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("error is %T, want *ValidationError", err)
+			}
+			if ve.Reason != tt.reason {
+				t.Errorf("reason is %v, want %v", ve.Reason, tt.reason)
+			}
+			if !errors.Is(err, tt.sentinel) {
+				t.Errorf("error doesn't match %v", tt.sentinel)
+			}
+
+			msg := err.Error()
+			if msg != tt.sentinel.Error() {
+				t.Errorf("message is %q, want %q", msg, tt.sentinel.Error())
+			}
+			for _, leak := range []string{crafted, "\x00", "\u202e", "<script>", `"issuer"`, "unexpected issuer"} {
+				if strings.Contains(msg, leak) {
+					t.Errorf("message %q contains %q", msg, leak)
+				}
+			}
+			if strings.IndexFunc(msg, unicode.IsControl) >= 0 {
+				t.Errorf("message %q contains control characters", msg)
+			}
+
+			if got := ve.Unredacted().Error(); got != tt.detail {
+				t.Errorf("unredacted message is %q, want %q", got, tt.detail)
+			}
+			if got := ve.Unwrap().Error(); got != tt.detail {
+				t.Errorf("unwrapped message is %q, want %q", got, tt.detail)
+			}
+
+			redacted := ve.Redacted()
+			if redacted.Error() != msg {
+				t.Errorf("redacted message is %q, want %q", redacted.Error(), msg)
+			}
+			if !errors.Is(redacted, tt.sentinel) {
+				t.Errorf("redacted error doesn't match %v", tt.sentinel)
+			}
+			if got := redacted.Unredacted().Error(); got != tt.detail {
+				t.Errorf("unredacted message is %q, want %q", got, tt.detail)
+			}
+		})
+	}
+}
+
+func TestSentinelsAreValidationErrors(t *testing.T) {
+	a := newTestAuth(t)
+
+	claims := newClaims("user")
+	claims.TenantID = "tenant"
+	claims.Fingerprint = "fingerprint"
+	tokenStr := mustGenerate(t, a, claims)
+
+	tests := []struct {
+		name     string
+		validate func() error
+		sentinel error
+	}{
+		{
+			name: "tenant mismatch",
+			validate: func() error {
+				_, err := a.ValidateTokenForTenant(tokenStr, "other")
+				return err
+			},
+			sentinel: ErrTenantMismatch,
+		},
+		{
+			name: "fingerprint mismatch",
+			validate: func() error {
+				_, err := a.ValidateTokenBound(tokenStr, "other")
+				return err
+			},
+			sentinel: ErrFingerprintMismatch,
+		},
+		{
+			name: "refresh with access token",
+			validate: func() error {
+				_, err := a.Refresh(tokenStr)
+				return err
+			},
+			sentinel: ErrInvalidTokenType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validate()
+
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("error is %T, want *ValidationError", err)
+			}
+			if !errors.Is(err, tt.sentinel) {
+				t.Errorf("error %v doesn't match %v", err, tt.sentinel)
+			}
+			if ve.Redacted().Error() != tt.sentinel.Error() {
+				t.Errorf("redacted message is %q, want %q", ve.Redacted().Error(), tt.sentinel.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package auth_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/mromero1591/web-foundation/auth"
+	"github.com/mromero1591/web-foundation/auth/authtest"
+)
+
+func ExampleAuth_Protect() {
+	a, mint := authtest.NewTestAuth()
+
+	// Protect and RequireSubject have the func(http.Handler) http.Handler
+	// signature taken by r.Use and r.With of chi. The standard ServeMux is
+	// used here, with the id taken from the path in place of chi.URLParam.
+	userID := func(r *http.Request) string {
+		return strings.TrimPrefix(r.URL.Path, "/users/")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/users/", a.Protect()(a.RequireSubject(userID)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "profile of ", auth.MustFromContext(r.Context()).Subject)
+	}))))
+	mux.Handle("/admin", a.Protect(auth.RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "admin")
+	})))
+
+	var jane auth.Claims
+	jane.Subject = "jane"
+	jane.Roles = []string{auth.RoleUser}
+	token := mint(jane)
+
+	for _, path := range []string{"/users/jane", "/users/john", "/admin"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		fmt.Println(path, w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/users/jane", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	fmt.Println("/users/jane without token", w.Code)
+
+	// Output:
+	// /users/jane 200
+	// /users/john 403
+	// /admin 403
+	// /users/jane without token 401
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrExchangeBroadens is returned by ExchangeToken when the requested token
+// would carry a scope or role the incoming token doesn't have.
+var ErrExchangeBroadens = errors.New("exchange can't broaden the token")
+
+// ExchangeOptions describes the token requested from ExchangeToken. The
+// fields map to the RFC 8693 token exchange parameters:
+//
+//   - Audience is the audience and resource parameters, the services the new
+//     token is for. When empty the configured audience is used.
+//   - Scopes is the scope parameter. It must be a subset of the scopes of the
+//     incoming token, which are kept when it is empty.
+//   - Roles restricts the roles the same way. It has no RFC counterpart.
+//   - Actor holds the claims of the actor_token parameter, the party acting
+//     on behalf of the subject. It becomes the "act" claim, with the actors
+//     of the incoming token nested inside it.
+//   - TTL is the lifetime of the new token, which never outlives the
+//     incoming token. It defaults to the access token lifetime.
+//
+// The incoming token is the subject_token parameter, its subject is kept.
+type ExchangeOptions struct {
+	Audience []string
+	Scopes   []string
+	Roles    []string
+	Actor    *Claims
+	TTL      time.Duration
+}
+
+// ExchangeToken validates the incoming token and mints a narrower token for
+// the same subject as described by opts, for example to call a downstream
+// service with only the access it needs. It returns ErrExchangeBroadens for
+// requests that would grant more than the incoming token holds.
+func (a *Auth) ExchangeToken(incoming string, opts ExchangeOptions) (string, error) {
+	claims, err := a.ValidateToken(incoming)
+	if err != nil {
+		return "", err
+	}
+
+	next := claims
+	next.Id = ""
+	next.Issuer = ""
+	next.Audience = append(ClaimStrings(nil), opts.Audience...)
+	next.TokenType = TokenTypeAccess
+
+	if len(opts.Scopes) > 0 {
+		if !claims.HasAllScopes(opts.Scopes...) {
+			return "", errors.Wrap(ErrExchangeBroadens, "scopes not held")
+		}
+		next.Scope = strings.Join(opts.Scopes, " ")
+	}
+
+	if len(opts.Roles) > 0 {
+		if !claims.HasAllRoles(opts.Roles...) {
+			return "", errors.Wrap(ErrExchangeBroadens, "roles not held")
+		}
+		next.Roles = append([]string(nil), opts.Roles...)
+	}
+
+	if opts.Actor != nil {
+		actor := cloneClaims(*opts.Actor)
+		actor.Actor = claims.Actor
+		next.Actor = &actor
+	}
+
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = a.accessTTL
+	}
+
+	now := a.clock()
+	exp := now.Add(ttl).Unix()
+	if claims.ExpiresAt != 0 && claims.ExpiresAt < exp {
+		exp = claims.ExpiresAt
+	}
+	next.IssuedAt = now.Unix()
+	next.ExpiresAt = exp
+
+	return a.GenerateToken(next)
+}
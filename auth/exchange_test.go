@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestExchangeToken(t *testing.T) {
+	refresh := newClaims("user", RoleUser, RoleAdmin)
+	refresh.TokenType = TokenTypeRefresh
+
+	tests := []struct {
+		name    string
+		claims  func() Claims
+		opts    ExchangeOptions
+		advance time.Duration
+		err     error
+		scope   string
+		roles   []string
+		exp     time.Time
+	}{
+		{
+			name:  "kept",
+			scope: "read write",
+			roles: []string{RoleUser, RoleAdmin},
+			exp:   testNow.Add(DefaultAccessTTL),
+		},
+		{
+			name:  "narrowed",
+			opts:  ExchangeOptions{Scopes: []string{"read"}, Roles: []string{RoleUser}, TTL: 10 * time.Minute},
+			scope: "read",
+			roles: []string{RoleUser},
+			exp:   testNow.Add(10 * time.Minute),
+		},
+		{
+			name:  "lifetime capped by the subject token",
+			opts:  ExchangeOptions{TTL: 24 * time.Hour},
+			scope: "read write",
+			roles: []string{RoleUser, RoleAdmin},
+			exp:   testNow.Add(time.Hour),
+		},
+		{
+			name: "broadened scopes",
+			opts: ExchangeOptions{Scopes: []string{"read", "delete"}},
+			err:  ErrExchangeBroadens,
+		},
+		{
+			name: "broadened roles",
+			opts: ExchangeOptions{Roles: []string{"OWNER"}},
+			err:  ErrExchangeBroadens,
+		},
+		{
+			name:    "expired subject token",
+			advance: 2 * time.Hour,
+			err:     ErrTokenExpired,
+		},
+		{
+			name:   "refresh subject token",
+			claims: func() Claims { return refresh },
+			err:    ErrInvalidTokenType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, WithClock(clock.Now))
+
+			claims := newClaims("user", RoleUser, RoleAdmin)
+			if tt.claims != nil {
+				claims = tt.claims()
+			}
+			claims.Scope = "read write"
+			incoming := mustGenerate(t, a, claims)
+			clock.Advance(tt.advance)
+
+			str, err := a.ExchangeToken(incoming, tt.opts)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err is %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("exchanging token: %v", err)
+			}
+
+			got, err := a.ValidateToken(str)
+			if err != nil {
+				t.Fatalf("validating exchanged token: %v", err)
+			}
+			if got.Subject != "user" {
+				t.Errorf("subject is %q, want %q", got.Subject, "user")
+			}
+			if got.Scope != tt.scope {
+				t.Errorf("scope is %q, want %q", got.Scope, tt.scope)
+			}
+			if !reflect.DeepEqual(got.Roles, tt.roles) {
+				t.Errorf("roles are %v, want %v", got.Roles, tt.roles)
+			}
+			if got.ExpiresAt != tt.exp.Unix() {
+				t.Errorf("exp is %v, want %v", time.Unix(got.ExpiresAt, 0).UTC(), tt.exp)
+			}
+		})
+	}
+}
+
+func TestExchangeTokenActor(t *testing.T) {
+	a := newTestAuth(t)
+
+	var first Claims
+	first.Subject = "gateway"
+	claims := newClaims("user", RoleUser)
+	claims.Actor = &first
+
+	var second Claims
+	second.Subject = "orders"
+
+	str, err := a.ExchangeToken(mustGenerate(t, a, claims), ExchangeOptions{Audience: []string{"billing"}, Actor: &second})
+	if err != nil {
+		t.Fatalf("exchanging token: %v", err)
+	}
+
+	got, err := a.ValidateToken(str)
+	if err != nil {
+		t.Fatalf("validating exchanged token: %v", err)
+	}
+	if !got.Audience.Contains("billing") {
+		t.Errorf("audience is %v, want billing", got.Audience)
+	}
+	if got.Actor == nil || got.Actor.Subject != "orders" {
+		t.Fatalf("actor is %+v, want orders", got.Actor)
+	}
+	if got.Actor.Actor == nil || got.Actor.Actor.Subject != "gateway" {
+		t.Errorf("nested actor is %+v, want gateway", got.Actor.Actor)
+	}
+}
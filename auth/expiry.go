@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"math"
+	"time"
+)
+
+// IsExpired returns true if the claims have an expiration that is before now.
+// Claims without an expiration never expire.
+func (c Claims) IsExpired(now time.Time) bool {
+	if c.ExpiresAt == 0 {
+		return false
+	}
+	return now.After(time.Unix(c.ExpiresAt, 0))
+}
+
+// TimeUntilExpiry returns how long until the claims expire. The duration is
+// negative once they have expired. Claims without an expiration return the
+// maximum duration.
+func (c Claims) TimeUntilExpiry(now time.Time) time.Duration {
+	if c.ExpiresAt == 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Unix(c.ExpiresAt, 0).Sub(now)
+}
+
+// ValidAt checks the time based claims against now. It returns
+// ErrTokenExpired or ErrTokenNotYetValid when the claims are outside of
+// their validity window.
+func (c Claims) ValidAt(now time.Time) error {
+	if c.IsExpired(now) {
+		return ErrTokenExpired
+	}
+
+	if c.NotBefore != 0 && now.Before(time.Unix(c.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+
+	if c.IssuedAt != 0 && now.Before(time.Unix(c.IssuedAt, 0)) {
+		return ErrTokenNotYetValid
+	}
+
+	return nil
+}
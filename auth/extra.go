@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// registeredClaims are the JSON names of the fields of Claims. Extra can't
+// set them so they can't be overwritten.
+var registeredClaims = claimNames(reflect.TypeOf(Claims{}))
+
+// claimNames returns the JSON names of the fields of the struct type,
+// including the fields of embedded structs.
+func claimNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for name := range claimNames(f.Type) {
+				names[name] = true
+			}
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// plainClaims has the fields of Claims without its methods, so it is encoded
+// by the standard rules.
+type plainClaims Claims
+
+// MarshalJSON implements the json.Marshaler interface. The Extra claims are
+// encoded at the top level next to the other claims. Extra claims named like
+// one of the fields of Claims are dropped.
+func (c Claims) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(plainClaims(c))
+	if err != nil || len(c.Extra) == 0 {
+		return data, err
+	}
+
+	keys := make([]string, 0, len(c.Extra))
+	for key := range c.Extra {
+		if !registeredClaims[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, key := range keys {
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(c.Extra[key])
+		if err != nil {
+			return nil, err
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Claims that don't
+// match a field of Claims are decoded into Extra.
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*plainClaims)(c)); err != nil {
+		return err
+	}
+
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+
+	for key := range all {
+		if registeredClaims[key] {
+			delete(all, key)
+		}
+	}
+	if len(all) > 0 {
+		c.Extra = all
+	}
+
+	return nil
+}
+
+// GetString returns the Extra claim as a string. The bool is false when the
+// claim is missing or isn't a string.
+func (c Claims) GetString(key string) (string, bool) {
+	s, ok := c.Extra[key].(string)
+	return s, ok
+}
+
+// GetBool returns the Extra claim as a bool. The bool is false when the claim
+// is missing or isn't a bool.
+func (c Claims) GetBool(key string) (bool, bool) {
+	v, exists := c.Extra[key]
+	if !exists {
+		return false, false
+	}
+	b, ok := v.(bool)
+	return b, ok
+}
+
+// compactClaims encodes like Claims but omits the name, username and roles
+// claims when they are empty, see WithCompactClaims.
+type compactClaims Claims
+
+// MarshalJSON implements the json.Marshaler interface.
+func (c compactClaims) MarshalJSON() ([]byte, error) {
+	claims := Claims(c)
+
+	data, err := claims.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var omit []string
+	if claims.Name == "" {
+		omit = append(omit, "name")
+	}
+	if claims.UserName == "" {
+		omit = append(omit, "username")
+	}
+	if len(claims.Roles) == 0 {
+		omit = append(omit, "roles")
+	}
+	if len(omit) == 0 {
+		return data, nil
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	for _, key := range omit {
+		delete(m, key)
+	}
+
+	return json.Marshal(m)
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// payloadKeys returns the names of the claims in the payload of the token.
+func payloadKeys(t testing.TB, tokenStr string) map[string]bool {
+	t.Helper()
+
+	parts := strings.Split(tokenStr, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts", len(parts))
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+
+	keys := make(map[string]bool, len(m))
+	for key := range m {
+		keys[key] = true
+	}
+	return keys
+}
+
+func TestCompactClaims(t *testing.T) {
+	tests := []struct {
+		name    string
+		compact bool
+		claims  func() Claims
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "empty claims written",
+			claims:  func() Claims { return newClaims("user") },
+			present: []string{"name", "username", "roles"},
+		},
+		{
+			name:    "empty claims omitted",
+			compact: true,
+			claims:  func() Claims { return newClaims("user") },
+			present: []string{"sub", "exp", "iat"},
+			absent:  []string{"name", "username", "roles"},
+		},
+		{
+			name:    "set claims kept",
+			compact: true,
+			claims: func() Claims {
+				c := newClaims("user", RoleUser)
+				c.Name = "Jane"
+				c.UserName = "jane"
+				return c
+			},
+			present: []string{"name", "username", "roles"},
+		},
+		{
+			name:    "extra claims kept",
+			compact: true,
+			claims: func() Claims {
+				c := newClaims("user")
+				c.Extra = map[string]interface{}{"tenant": "acme"}
+				return c
+			},
+			present: []string{"tenant"},
+			absent:  []string{"roles"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.compact {
+				opts = append(opts, WithCompactClaims())
+			}
+			a := newTestAuth(t, opts...)
+
+			claims := tt.claims()
+			tokenStr := mustGenerate(t, a, claims)
+
+			keys := payloadKeys(t, tokenStr)
+			for _, key := range tt.present {
+				if !keys[key] {
+					t.Errorf("%s claim is missing", key)
+				}
+			}
+			for _, key := range tt.absent {
+				if keys[key] {
+					t.Errorf("%s claim is present", key)
+				}
+			}
+
+			got, err := a.ValidateToken(tokenStr)
+			if err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+			if got.Name != claims.Name || got.UserName != claims.UserName || len(got.Roles) != len(claims.Roles) {
+				t.Errorf("claims are %+v, want %+v", got, claims)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRefreshTokenReuse is returned by Refresh when a refresh token that was
+// already exchanged is presented again. This is a sign the token was stolen,
+// so the entire token family is revoked.
+var ErrRefreshTokenReuse = errors.New("refresh token reuse detected")
+
+// FamilyStore records the latest refresh token id (jti) issued for each
+// token family. A family starts with GenerateTokenPair and is carried over
+// to every token pair minted by Refresh.
+type FamilyStore interface {
+	// Start begins a new family whose current refresh token is jti. The exp
+	// value is when the family can be forgotten.
+	Start(family, jti string, exp time.Time) error
+
+	// Rotate replaces the current refresh token of the family with nextJTI.
+	// It must return ErrRefreshTokenReuse when prevJTI isn't the current
+	// refresh token or the family was revoked. The check and replace must be
+	// atomic so a token can't be exchanged twice.
+	Rotate(family, prevJTI, nextJTI string, exp time.Time) error
+
+	// RevokeFamily revokes the family so none of its tokens can be refreshed.
+	RevokeFamily(family string) error
+}
+
+// WithFamilyStore enables refresh token rotation with reuse detection.
+func WithFamilyStore(fs FamilyStore) Option {
+	return func(a *Auth) {
+		a.families = fs
+	}
+}
+
+// family is the state the MemoryFamilyStore tracks per family.
+type family struct {
+	current string
+	revoked bool
+	exp     time.Time
+}
+
+// MemoryFamilyStore is an in-memory FamilyStore. Families are removed once
+// their latest refresh token has expired, according to the clock of the Auth
+// it is attached to. It is safe for concurrent use.
+type MemoryFamilyStore struct {
+	mu          sync.Mutex
+	families    map[string]family
+	clock       func() time.Time
+	lastCleanup time.Time
+}
+
+// NewMemoryFamilyStore constructs an empty MemoryFamilyStore.
+func NewMemoryFamilyStore() *MemoryFamilyStore {
+	return &MemoryFamilyStore{
+		families:    make(map[string]family),
+		clock:       time.Now,
+		lastCleanup: time.Now(),
+	}
+}
+
+// useClock implements the clockUser interface.
+func (m *MemoryFamilyStore) useClock(clock func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = clock
+	m.lastCleanup = clock()
+}
+
+// Start implements the FamilyStore interface.
+func (m *MemoryFamilyStore) Start(fam, jti string, exp time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.families[fam] = family{current: jti, exp: exp}
+	m.cleanup()
+
+	return nil
+}
+
+// Rotate implements the FamilyStore interface.
+func (m *MemoryFamilyStore) Rotate(fam, prevJTI, nextJTI string, exp time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, exists := m.families[fam]
+	if !exists || f.revoked || f.current != prevJTI {
+		return ErrRefreshTokenReuse
+	}
+
+	m.families[fam] = family{current: nextJTI, exp: exp}
+
+	return nil
+}
+
+// RevokeFamily implements the FamilyStore interface.
+func (m *MemoryFamilyStore) RevokeFamily(fam string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, exists := m.families[fam]
+	if !exists {
+		return nil
+	}
+
+	f.revoked = true
+	m.families[fam] = f
+
+	return nil
+}
+
+// cleanup removes the expired families. It runs at most once per
+// cleanupInterval and must be called with the mutex held.
+func (m *MemoryFamilyStore) cleanup() {
+	now := m.clock()
+	if now.Sub(m.lastCleanup) < cleanupInterval {
+		return
+	}
+
+	for fam, f := range m.families {
+		if now.After(f.exp) {
+			delete(m.families, fam)
+		}
+	}
+	m.lastCleanup = now
+}
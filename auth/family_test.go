@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestRefreshReuseDetection(t *testing.T) {
+	tests := []struct {
+		name string
+		// refresh exchanges the tokens of the first pair and returns the
+		// error of the last exchange.
+		refresh func(t *testing.T, a *Auth, first TokenPair) error
+		err     error
+	}{
+		{
+			name: "rotation",
+			refresh: func(t *testing.T, a *Auth, first TokenPair) error {
+				second, err := a.Refresh(first.RefreshToken)
+				if err != nil {
+					return err
+				}
+				_, err = a.Refresh(second.RefreshToken)
+				return err
+			},
+		},
+		{
+			name: "replayed token",
+			refresh: func(t *testing.T, a *Auth, first TokenPair) error {
+				if _, err := a.Refresh(first.RefreshToken); err != nil {
+					return err
+				}
+				_, err := a.Refresh(first.RefreshToken)
+				return err
+			},
+			err: ErrRefreshTokenReuse,
+		},
+		{
+			// The legitimate client refreshing after the thief replayed its
+			// token finds the family revoked.
+			name: "family revoked after replay",
+			refresh: func(t *testing.T, a *Auth, first TokenPair) error {
+				second, err := a.Refresh(first.RefreshToken)
+				if err != nil {
+					return err
+				}
+				if _, err := a.Refresh(first.RefreshToken); !errors.Is(err, ErrRefreshTokenReuse) {
+					return errors.Errorf("replay returned %v", err)
+				}
+				_, err = a.Refresh(second.RefreshToken)
+				return err
+			},
+			err: ErrRefreshTokenReuse,
+		},
+		{
+			name: "expired token",
+			refresh: func(t *testing.T, a *Auth, first TokenPair) error {
+				stale := newTestAuth(t, WithClock(func() time.Time { return testNow.Add(48 * time.Hour) }))
+				stale.families = a.families
+				_, err := stale.Refresh(first.RefreshToken)
+				return err
+			},
+			err: ErrTokenExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t, WithFamilyStore(NewMemoryFamilyStore()))
+
+			first, err := a.GenerateTokenPair(newClaims("user"), time.Minute, 24*time.Hour)
+			if err != nil {
+				t.Fatalf("generating token pair: %v", err)
+			}
+
+			err = tt.refresh(t, a, first)
+			if tt.err == nil && err != nil {
+				t.Errorf("refresh failed: %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("err is %v, want %v", err, tt.err)
+			}
+			// A replay must be told apart from a token that merely expired.
+			if errors.Is(tt.err, ErrRefreshTokenReuse) && errors.Is(err, ErrTokenExpired) {
+				t.Errorf("reuse reported as expiry: %v", err)
+			}
+		})
+	}
+}
+
+func TestMemoryFamilyStoreUsesAuthClock(t *testing.T) {
+	clock := newTestClock()
+	fs := NewMemoryFamilyStore()
+	newTestAuth(t, WithClock(clock.Now), WithFamilyStore(fs))
+
+	if err := fs.Start("expired", "jti", testNow.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Starting a family after the cleanup interval purges the expired ones,
+	// judged by the clock of the Auth.
+	clock.Advance(cleanupInterval + time.Minute)
+	if err := fs.Start("current", "jti", clock.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, exists := fs.families["expired"]; exists {
+		t.Error("expired family was kept")
+	}
+	if _, exists := fs.families["current"]; !exists {
+		t.Error("current family was purged")
+	}
+}
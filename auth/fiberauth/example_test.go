@@ -0,0 +1,40 @@
+package fiberauth_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mromero1591/web-foundation/auth"
+	"github.com/mromero1591/web-foundation/auth/authtest"
+	"github.com/mromero1591/web-foundation/auth/fiberauth"
+)
+
+func Example() {
+	a, mint := authtest.NewTestAuth()
+
+	app := fiber.New()
+	app.Use(fiberauth.New(a))
+	app.Get("/admin", fiberauth.RequireRole(a, auth.RoleAdmin), func(c *fiber.Ctx) error {
+		claims, _ := fiberauth.FromContext(c)
+		return c.SendString("hello " + claims.Subject)
+	})
+
+	var admin auth.Claims
+	admin.Subject = "admin"
+	admin.Roles = []string{auth.RoleAdmin}
+
+	r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	r.Header.Set(fiber.HeaderAuthorization, "Bearer "+mint(admin))
+
+	resp, err := app.Test(r)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	fmt.Println(resp.StatusCode)
+	// Output: 200
+}
@@ -0,0 +1,105 @@
+// Package fiberauth provides Fiber middleware that authenticates requests
+// using the auth package. It lives in its own package so users that don't run
+// Fiber don't depend on it.
+//
+//	app := fiber.New()
+//	app.Use(fiberauth.New(a))
+//	app.Get("/admin", fiberauth.RequireRole(a, auth.RoleAdmin), handler)
+package fiberauth
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mromero1591/web-foundation/auth"
+	"github.com/pkg/errors"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// ClaimsKey is the c.Locals key the Claims are stored under.
+const ClaimsKey = "auth.claims"
+
+// New returns middleware that validates the token of the request like
+// auth.Authenticate, reading it with the extractor of the Auth and honoring
+// its FailureLimiter. The Claims are stored in c.Locals under ClaimsKey.
+// Requests without a valid token are handed to the unauthorized handler of
+// the Auth when one was set with auth.WithUnauthorizedHandler, and fail with
+// fiber.ErrUnauthorized otherwise. Callers blocked by the FailureLimiter fail
+// with fiber.ErrTooManyRequests.
+func New(a *auth.Auth) fiber.Handler {
+	h := func(c *fiber.Ctx) error {
+		var r http.Request
+		if err := fasthttpadaptor.ConvertRequest(c.Context(), &r, true); err != nil {
+			return fiber.ErrBadRequest
+		}
+
+		claims, err := a.AuthenticateRequest(r.WithContext(c.UserContext()))
+		if errors.Is(err, auth.ErrTooManyFailures) {
+			return fiber.ErrTooManyRequests
+		}
+		if err != nil {
+			return unauthorized(a, c, err)
+		}
+
+		c.Locals(ClaimsKey, claims)
+
+		return c.Next()
+	}
+
+	return h
+}
+
+// RequireRole returns a handler that only lets requests through when the
+// Claims have at least one of the roles, as checked by Auth.Authorized. It
+// must run after New. Requests without Claims are rejected like by New.
+// Requests lacking the roles are handed to the forbidden handler of the Auth
+// when one was set with auth.WithForbiddenHandler, and fail with
+// fiber.ErrForbidden otherwise.
+func RequireRole(a *auth.Auth, roles ...string) fiber.Handler {
+	h := func(c *fiber.Ctx) error {
+		claims, ok := FromContext(c)
+		if !ok {
+			return unauthorized(a, c, auth.ErrNoClaims)
+		}
+
+		if !a.Authorized(claims, roles...) {
+			return forbidden(a, c, auth.ErrForbidden)
+		}
+
+		return c.Next()
+	}
+
+	return h
+}
+
+// FromContext returns the Claims stored in c.Locals by New.
+func FromContext(c *fiber.Ctx) (auth.Claims, bool) {
+	claims, ok := c.Locals(ClaimsKey).(auth.Claims)
+	return claims, ok
+}
+
+// unauthorized responds with the unauthorized handler of the Auth, if any,
+// or returns fiber.ErrUnauthorized.
+func unauthorized(a *auth.Auth, c *fiber.Ctx, err error) error {
+	if h := a.UnauthorizedHandler(); h != nil {
+		return respond(c, h, err)
+	}
+	return fiber.ErrUnauthorized
+}
+
+// forbidden responds with the forbidden handler of the Auth, if any, or
+// returns fiber.ErrForbidden.
+func forbidden(a *auth.Auth, c *fiber.Ctx, err error) error {
+	if h := a.ForbiddenHandler(); h != nil {
+		return respond(c, h, err)
+	}
+	return fiber.ErrForbidden
+}
+
+// respond runs the net/http ErrorHandler h against the fasthttp request of c.
+func respond(c *fiber.Ctx, h auth.ErrorHandler, err error) error {
+	fasthttpadaptor.NewFastHTTPHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h(w, r, err)
+	})(c.Context())
+	return nil
+}
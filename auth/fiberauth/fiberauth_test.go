@@ -0,0 +1,139 @@
+package fiberauth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mromero1591/web-foundation/auth"
+	"github.com/mromero1591/web-foundation/auth/authtest"
+	"github.com/mromero1591/web-foundation/auth/fiberauth"
+)
+
+// teapot is an auth.ErrorHandler that can be told apart from the default
+// responses.
+func teapot(w http.ResponseWriter, r *http.Request, err error) {
+	w.WriteHeader(http.StatusTeapot)
+}
+
+func TestNew(t *testing.T) {
+	var user auth.Claims
+	user.Subject = "user"
+	user.Roles = []string{auth.RoleUser}
+
+	tests := []struct {
+		name   string
+		opts   []auth.Option
+		roles  []string
+		header string
+		scheme string
+		token  bool
+		status int
+	}{
+		{name: "valid", header: "Authorization", scheme: "Bearer ", token: true, status: http.StatusOK},
+		{name: "missing", status: http.StatusUnauthorized},
+		{name: "invalid", header: "Authorization", scheme: "Bearer ", status: http.StatusUnauthorized},
+		{
+			name:   "custom header",
+			opts:   []auth.Option{auth.WithHeaderName("X-Auth-Token"), auth.WithAuthScheme("")},
+			header: "X-Auth-Token",
+			token:  true,
+			status: http.StatusOK,
+		},
+		{
+			name:   "custom header ignores authorization",
+			opts:   []auth.Option{auth.WithHeaderName("X-Auth-Token"), auth.WithAuthScheme("")},
+			header: "Authorization",
+			scheme: "Bearer ",
+			token:  true,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "custom scheme",
+			opts:   []auth.Option{auth.WithAuthScheme("Token")},
+			header: "Authorization",
+			scheme: "Token ",
+			token:  true,
+			status: http.StatusOK,
+		},
+		{
+			name:   "unauthorized handler",
+			opts:   []auth.Option{auth.WithUnauthorizedHandler(teapot)},
+			status: http.StatusTeapot,
+		},
+		{
+			name:   "blocked",
+			opts:   []auth.Option{auth.WithFailureLimiter(auth.NewMemoryFailureLimiter(1, time.Hour, time.Hour), nil)},
+			header: "Authorization",
+			scheme: "Bearer ",
+			status: http.StatusTooManyRequests,
+		},
+		{name: "role", roles: []string{auth.RoleUser}, header: "Authorization", scheme: "Bearer ", token: true, status: http.StatusOK},
+		{name: "forbidden", roles: []string{auth.RoleAdmin}, header: "Authorization", scheme: "Bearer ", token: true, status: http.StatusForbidden},
+		{
+			name:   "forbidden handler",
+			opts:   []auth.Option{auth.WithForbiddenHandler(teapot)},
+			roles:  []string{auth.RoleAdmin},
+			header: "Authorization",
+			scheme: "Bearer ",
+			token:  true,
+			status: http.StatusTeapot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, mint := authtest.NewTestAuth(tt.opts...)
+
+			var got auth.Claims
+			handler := func(c *fiber.Ctx) error {
+				claims, ok := fiberauth.FromContext(c)
+				if !ok {
+					t.Error("no claims in c.Locals")
+				}
+				got = claims
+				return c.SendStatus(http.StatusOK)
+			}
+
+			handlers := []fiber.Handler{fiberauth.New(a)}
+			if len(tt.roles) > 0 {
+				handlers = append(handlers, fiberauth.RequireRole(a, tt.roles...))
+			}
+
+			app := fiber.New()
+			app.Get("/", append(handlers, handler)...)
+
+			// The failing request of the blocked case uses up the limiter.
+			tokenStr := "invalid"
+			if tt.token {
+				tokenStr = mint(user)
+			}
+			codes := []int{tt.status}
+			if tt.status == http.StatusTooManyRequests {
+				codes = []int{http.StatusUnauthorized, http.StatusTooManyRequests}
+			}
+
+			for _, code := range codes {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				if tt.header != "" {
+					r.Header.Set(tt.header, tt.scheme+tokenStr)
+				}
+				resp, err := app.Test(r)
+				if err != nil {
+					t.Fatalf("app.Test: %v", err)
+				}
+				resp.Body.Close()
+
+				if resp.StatusCode != code {
+					t.Fatalf("status is %d, want %d", resp.StatusCode, code)
+				}
+			}
+
+			if tt.status == http.StatusOK && got.Subject != user.Subject {
+				t.Errorf("subject is %q, want %q", got.Subject, user.Subject)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"crypto/subtle"
+
+	"github.com/pkg/errors"
+)
+
+// ErrFingerprintMismatch is returned by ValidateTokenBound, wrapped in a
+// *ValidationError, when the token is presented by a client other than the one
+// it was bound to.
+var ErrFingerprintMismatch = errors.New("token fingerprint mismatch")
+
+// ValidateTokenBound validates the token like ValidateToken and also requires
+// its fingerprint (fpt) to match the fingerprint presented by the client,
+// such as a hash of its IP address and User-Agent or a value sent both in a
+// cookie and a header. Tokens that aren't bound to a fingerprint are
+// rejected. The values are compared in constant time.
+//
+// Binding makes a stolen token harder to replay from another client. It is
+// defense in depth and no replacement for TLS, since a fingerprint can be
+// observed and forged by whoever can steal the token.
+func (a *Auth) ValidateTokenBound(tokenStr string, fingerprint string) (Claims, error) {
+	claims, err := a.ValidateToken(tokenStr)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if claims.Fingerprint == "" || subtle.ConstantTimeCompare([]byte(claims.Fingerprint), []byte(fingerprint)) != 1 {
+		return Claims{}, newValidationError(ErrFingerprintMismatch)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,209 @@
+// Package grpcauth provides gRPC server interceptors that authenticate
+// requests using the auth package. It lives in its own package so users
+// that don't run gRPC don't depend on it.
+package grpcauth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mromero1591/web-foundation/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Option configures the interceptors.
+type Option func(*config)
+
+// config holds where the interceptors read the token from.
+type config struct {
+	key    string
+	scheme string
+}
+
+// newConfig applies the options over the defaults.
+func newConfig(opts []Option) config {
+	cfg := config{
+		key:    "authorization",
+		scheme: "bearer",
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithMetadataKey sets the metadata entry the token is read from, the
+// default is "authorization". Metadata keys are lowercase.
+func WithMetadataKey(key string) Option {
+	return func(c *config) {
+		c.key = strings.ToLower(key)
+	}
+}
+
+// WithScheme sets the scheme preceding the token in the metadata entry, the
+// default is bearer. An empty scheme means the whole value is the token, as
+// sent by clients using an entry like "x-access-token".
+func WithScheme(scheme string) Option {
+	return func(c *config) {
+		c.scheme = scheme
+	}
+}
+
+// UnaryServerInterceptor returns an interceptor that validates the bearer
+// token in the "authorization" metadata entry, or wherever the options say,
+// and stores the Claims in the context under auth.Key before invoking the
+// handler.
+func UnaryServerInterceptor(a *auth.Auth, opts ...Option) grpc.UnaryServerInterceptor {
+	cfg := newConfig(opts)
+
+	f := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, a, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+
+	return f
+}
+
+// StreamServerInterceptor returns an interceptor that validates the bearer
+// token in the "authorization" metadata entry, or wherever the options say,
+// and stores the Claims in the stream context under auth.Key before invoking
+// the handler.
+func StreamServerInterceptor(a *auth.Auth, opts ...Option) grpc.StreamServerInterceptor {
+	cfg := newConfig(opts)
+
+	f := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a, cfg)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+
+	return f
+}
+
+// MethodAuthConfig maps a full gRPC method name, as in "/pkg.Service/Method",
+// to the roles required to invoke it. The caller needs at least one of the
+// roles, as checked by auth.Auth.Authorized. Methods that aren't in the map
+// only require a valid token.
+type MethodAuthConfig map[string][]string
+
+// UnaryServerInterceptorWithRoles is UnaryServerInterceptor but also requires
+// the roles configured for the invoked method. Callers lacking them fail with
+// codes.PermissionDenied.
+func UnaryServerInterceptorWithRoles(a *auth.Auth, methods MethodAuthConfig, opts ...Option) grpc.UnaryServerInterceptor {
+	cfg := newConfig(opts)
+
+	f := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, a, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := authorize(ctx, a, methods[info.FullMethod]); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+
+	return f
+}
+
+// StreamServerInterceptorWithRoles is StreamServerInterceptor but also
+// requires the roles configured for the invoked method. Callers lacking them
+// fail with codes.PermissionDenied.
+func StreamServerInterceptorWithRoles(a *auth.Auth, methods MethodAuthConfig, opts ...Option) grpc.StreamServerInterceptor {
+	cfg := newConfig(opts)
+
+	f := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a, cfg)
+		if err != nil {
+			return err
+		}
+
+		if err := authorize(ctx, a, methods[info.FullMethod]); err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+
+	return f
+}
+
+// authorize checks the Claims in the context have one of the roles. No roles
+// means any authenticated caller is allowed.
+func authorize(ctx context.Context, a *auth.Auth, roles []string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+
+	claims, ok := auth.FromContext(ctx)
+	if !ok || !a.Authorized(claims, roles...) {
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+
+	return nil
+}
+
+// authenticate validates the token from the incoming metadata and returns
+// a context holding the Claims.
+func authenticate(ctx context.Context, a *auth.Auth, cfg config) (context.Context, error) {
+	tokenStr, err := tokenFromMetadata(ctx, cfg)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	claims, err := a.ValidateTokenContext(ctx, tokenStr)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	return context.WithValue(ctx, auth.Key, claims), nil
+}
+
+// tokenFromMetadata extracts the token from the configured entry of the
+// incoming metadata.
+func tokenFromMetadata(ctx context.Context, cfg config) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", auth.ErrMissingToken
+	}
+
+	values := md.Get(cfg.key)
+	if len(values) == 0 || values[0] == "" {
+		return "", auth.ErrMissingToken
+	}
+
+	if cfg.scheme == "" {
+		return strings.TrimSpace(values[0]), nil
+	}
+
+	// Expecting: <scheme> <token>
+	parts := strings.Split(values[0], " ")
+	if len(parts) != 2 || !strings.EqualFold(parts[0], cfg.scheme) || parts[1] == "" {
+		return "", auth.ErrInvalidAuthScheme
+	}
+
+	return parts[1], nil
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context holding the Claims.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
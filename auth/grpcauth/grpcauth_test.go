@@ -0,0 +1,256 @@
+package grpcauth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mromero1591/web-foundation/auth"
+	"github.com/mromero1591/web-foundation/auth/authtest"
+	"github.com/mromero1591/web-foundation/auth/grpcauth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeServerStream is a grpc.ServerStream that only has a context. Any other
+// method panics, as the interceptors must not use them.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the stream.
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// incoming returns a context with the incoming metadata entry, or without
+// metadata when key is empty.
+func incoming(key, value string) context.Context {
+	if key == "" {
+		return context.Background()
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(key, value))
+}
+
+func TestStreamServerInterceptorWithRoles(t *testing.T) {
+	methods := grpcauth.MethodAuthConfig{
+		"/svc.Admin/Watch": {auth.RoleAdmin},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		roles  []string
+		key    string
+		token  bool
+		code   codes.Code
+	}{
+		{name: "open method", method: "/svc.Public/Watch", roles: []string{auth.RoleUser}, key: "authorization", token: true, code: codes.OK},
+		{name: "has role", method: "/svc.Admin/Watch", roles: []string{auth.RoleAdmin}, key: "authorization", token: true, code: codes.OK},
+		{name: "lacks role", method: "/svc.Admin/Watch", roles: []string{auth.RoleUser}, key: "authorization", token: true, code: codes.PermissionDenied},
+		{name: "no metadata", method: "/svc.Public/Watch", code: codes.Unauthenticated},
+		{name: "no token", method: "/svc.Public/Watch", key: "other", token: true, code: codes.Unauthenticated},
+		{name: "invalid token", method: "/svc.Admin/Watch", roles: []string{auth.RoleAdmin}, key: "authorization", code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, mint := authtest.NewTestAuth()
+
+			var user auth.Claims
+			user.Subject = "user"
+			user.Roles = tt.roles
+
+			tokenStr := "invalid"
+			if tt.token {
+				tokenStr = mint(user)
+			}
+			ss := &fakeServerStream{ctx: incoming(tt.key, "Bearer "+tokenStr)}
+
+			var called bool
+			handler := func(srv interface{}, stream grpc.ServerStream) error {
+				called = true
+				claims, ok := auth.FromContext(stream.Context())
+				if !ok {
+					t.Error("no claims in the stream context")
+				}
+				if claims.Subject != user.Subject {
+					t.Errorf("subject is %q, want %q", claims.Subject, user.Subject)
+				}
+				return nil
+			}
+
+			info := &grpc.StreamServerInfo{FullMethod: tt.method, IsServerStream: true}
+			err := grpcauth.StreamServerInterceptorWithRoles(a, methods)(nil, ss, info, handler)
+
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("code is %v, want %v", code, tt.code)
+			}
+			if called != (tt.code == codes.OK) {
+				t.Errorf("handler called is %v, want %v", called, tt.code == codes.OK)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptorWithRoles(t *testing.T) {
+	methods := grpcauth.MethodAuthConfig{
+		"/svc.Admin/Delete": {auth.RoleAdmin},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		roles  []string
+		code   codes.Code
+	}{
+		{name: "open method", method: "/svc.Public/Get", roles: []string{auth.RoleUser}, code: codes.OK},
+		{name: "has role", method: "/svc.Admin/Delete", roles: []string{auth.RoleAdmin}, code: codes.OK},
+		{name: "lacks role", method: "/svc.Admin/Delete", roles: []string{auth.RoleUser}, code: codes.PermissionDenied},
+		{name: "no roles", method: "/svc.Admin/Delete", code: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, mint := authtest.NewTestAuth()
+
+			var user auth.Claims
+			user.Subject = "user"
+			user.Roles = tt.roles
+
+			var called bool
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return req, nil
+			}
+
+			ctx := incoming("authorization", "Bearer "+mint(user))
+			info := &grpc.UnaryServerInfo{FullMethod: tt.method}
+			_, err := grpcauth.UnaryServerInterceptorWithRoles(a, methods)(ctx, nil, info, handler)
+
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("code is %v, want %v", code, tt.code)
+			}
+			if called != (tt.code == codes.OK) {
+				t.Errorf("handler called is %v, want %v", called, tt.code == codes.OK)
+			}
+		})
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	a, mint := authtest.NewTestAuth()
+
+	var user auth.Claims
+	user.Subject = "user"
+
+	tests := []struct {
+		name  string
+		value string
+		code  codes.Code
+	}{
+		{name: "valid", value: "Bearer " + mint(user), code: codes.OK},
+		{name: "wrong scheme", value: "Basic " + mint(user), code: codes.Unauthenticated},
+		{name: "invalid", value: "Bearer invalid", code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := &fakeServerStream{ctx: incoming("authorization", tt.value)}
+
+			var got auth.Claims
+			handler := func(srv interface{}, stream grpc.ServerStream) error {
+				got, _ = auth.FromContext(stream.Context())
+				return nil
+			}
+
+			info := &grpc.StreamServerInfo{FullMethod: "/svc.Public/Watch"}
+			err := grpcauth.StreamServerInterceptor(a)(nil, ss, info, handler)
+
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("code is %v, want %v", code, tt.code)
+			}
+			if tt.code == codes.OK && got.Subject != user.Subject {
+				t.Errorf("subject is %q, want %q", got.Subject, user.Subject)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptorMetadata(t *testing.T) {
+	a, mint := authtest.NewTestAuth()
+
+	var user auth.Claims
+	user.Subject = "user"
+	tokenStr := mint(user)
+
+	tests := []struct {
+		name  string
+		opts  []grpcauth.Option
+		key   string
+		value string
+		code  codes.Code
+	}{
+		{name: "default", key: "authorization", value: "Bearer " + tokenStr, code: codes.OK},
+		{name: "default scheme case", key: "authorization", value: "bearer " + tokenStr, code: codes.OK},
+		{name: "default wrong scheme", key: "authorization", value: "Token " + tokenStr, code: codes.Unauthenticated},
+		{name: "default no scheme", key: "authorization", value: tokenStr, code: codes.Unauthenticated},
+		{
+			name:  "key",
+			opts:  []grpcauth.Option{grpcauth.WithMetadataKey("X-Access-Token")},
+			key:   "x-access-token",
+			value: "Bearer " + tokenStr,
+			code:  codes.OK,
+		},
+		{
+			name:  "key ignores authorization",
+			opts:  []grpcauth.Option{grpcauth.WithMetadataKey("x-access-token")},
+			key:   "authorization",
+			value: "Bearer " + tokenStr,
+			code:  codes.Unauthenticated,
+		},
+		{
+			name:  "scheme",
+			opts:  []grpcauth.Option{grpcauth.WithScheme("Token")},
+			key:   "authorization",
+			value: "Token " + tokenStr,
+			code:  codes.OK,
+		},
+		{
+			name:  "key without scheme",
+			opts:  []grpcauth.Option{grpcauth.WithMetadataKey("x-access-token"), grpcauth.WithScheme("")},
+			key:   "x-access-token",
+			value: " " + tokenStr + " ",
+			code:  codes.OK,
+		},
+		{
+			name:  "empty value",
+			opts:  []grpcauth.Option{grpcauth.WithMetadataKey("x-access-token"), grpcauth.WithScheme("")},
+			key:   "x-access-token",
+			value: "",
+			code:  codes.Unauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got auth.Claims
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				got, _ = auth.FromContext(ctx)
+				return req, nil
+			}
+
+			info := &grpc.UnaryServerInfo{FullMethod: "/svc.Public/Get"}
+			_, err := grpcauth.UnaryServerInterceptor(a, tt.opts...)(incoming(tt.key, tt.value), nil, info, handler)
+
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("code is %v, want %v", code, tt.code)
+			}
+			if tt.code == codes.OK && got.Subject != user.Subject {
+				t.Errorf("subject is %q, want %q", got.Subject, user.Subject)
+			}
+		})
+	}
+}
@@ -0,0 +1,442 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// These are the errors returned when a token can't be found on a request.
+var (
+	ErrMissingToken      = errors.New("missing authorization header")
+	ErrInvalidAuthScheme = errors.New("expected authorization header format: <scheme> <token>")
+)
+
+// These are the errors given to the error handlers by the authorization
+// middleware.
+var (
+	ErrNoClaims  = errors.New("no claims in context")
+	ErrForbidden = errors.New("claims not authorized")
+)
+
+// ErrorHandler writes the response for a request that failed authentication
+// or authorization. The err value describes why the request was rejected.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// WithUnauthorizedHandler replaces the handler used by the middleware to
+// respond to requests that fail authentication. This is the place to log
+// the reason the request was rejected.
+func WithUnauthorizedHandler(h ErrorHandler) Option {
+	return func(a *Auth) {
+		a.unauthorized = h
+		a.unauthorizedSet = true
+	}
+}
+
+// WithForbiddenHandler replaces the handler used by the middleware to
+// respond to authenticated requests that fail authorization.
+func WithForbiddenHandler(h ErrorHandler) Option {
+	return func(a *Auth) {
+		a.forbidden = h
+		a.forbiddenSet = true
+	}
+}
+
+// UnauthorizedHandler returns the handler set with WithUnauthorizedHandler,
+// or nil when none was set. Middleware for other frameworks, like echoauth,
+// respond with it when it is set and with an error of their framework
+// otherwise.
+func (a *Auth) UnauthorizedHandler() ErrorHandler {
+	if !a.unauthorizedSet {
+		return nil
+	}
+	return a.unauthorized
+}
+
+// ForbiddenHandler returns the handler set with WithForbiddenHandler, or nil
+// when none was set, like UnauthorizedHandler.
+func (a *Auth) ForbiddenHandler() ErrorHandler {
+	if !a.forbiddenSet {
+		return nil
+	}
+	return a.forbidden
+}
+
+// ErrorResponse is the JSON body written by the default error handlers. The
+// Reason is a stable snake_case code clients can branch on, see ErrorCode.
+type ErrorResponse struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+// httpErrorCodes are the codes for the errors the middleware hands to the
+// error handlers.
+var httpErrorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrMissingToken, "missing_token"},
+	{ErrInvalidAuthScheme, "invalid_auth_scheme"},
+	{ErrNoClaims, "no_claims"},
+	{ErrForbidden, "insufficient_role"},
+	{ErrMFARequired, "mfa_required"},
+	{ErrInsufficientACR, "insufficient_acr"},
+	{ErrInvalidAudience, "bad_audience"},
+	{ErrTooManyFailures, "too_many_failures"},
+}
+
+// ErrorCode returns the stable snake_case code describing err. Validation
+// errors use the code of their Reason, such as "token_expired".
+func ErrorCode(err error) string {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ve.Reason.Code()
+	}
+
+	for _, c := range httpErrorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+
+	return ReasonInvalid.Code()
+}
+
+// WriteErrorResponse writes the ErrorResponse for err with the status code.
+// It is what the default error handlers use, for custom handlers that only
+// want to add to them, like logging.
+func WriteErrorResponse(w http.ResponseWriter, status int, err error) {
+	resp := ErrorResponse{
+		Error:  strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+		Reason: ErrorCode(err),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// defaultUnauthorized responds with a 401 and the code of the reason.
+func defaultUnauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	WriteErrorResponse(w, http.StatusUnauthorized, err)
+}
+
+// defaultForbidden responds with a 403 and the code of the reason.
+func defaultForbidden(w http.ResponseWriter, r *http.Request, err error) {
+	WriteErrorResponse(w, http.StatusForbidden, err)
+}
+
+// TokenExtractor retrieves the token from a request. It returns
+// ErrMissingToken when the request doesn't carry one.
+type TokenExtractor func(r *http.Request) (string, error)
+
+// WithTokenExtractor sets where the middleware looks for the token. The
+// default is FromHeader.
+func WithTokenExtractor(e TokenExtractor) Option {
+	return func(a *Auth) {
+		a.extractor = e
+	}
+}
+
+// TokenFromRequest extracts the bearer token from the Authorization header
+// of the request. It works with any request, including WebSocket upgrades.
+func TokenFromRequest(r *http.Request) (string, error) {
+	return FromHeader(r)
+}
+
+// FromHeader is a TokenExtractor that reads the bearer token from the
+// Authorization header.
+func FromHeader(r *http.Request) (string, error) {
+	return fromHeader(r, "Authorization", "Bearer")
+}
+
+// FromHeaderWithScheme returns a TokenExtractor that reads the token from the
+// named header, where it must follow the scheme, as in "Token <token>". An
+// empty scheme means the whole header value is the token.
+func FromHeaderWithScheme(name, scheme string) TokenExtractor {
+	f := func(r *http.Request) (string, error) {
+		return fromHeader(r, name, scheme)
+	}
+
+	return f
+}
+
+// fromHeader reads the token following the scheme from the named header.
+func fromHeader(r *http.Request, name, scheme string) (string, error) {
+	header := r.Header.Get(name)
+	if header == "" {
+		return "", ErrMissingToken
+	}
+
+	if scheme == "" {
+		return strings.TrimSpace(header), nil
+	}
+
+	// Expecting: <scheme> <token>
+	parts := strings.Split(header, " ")
+	if len(parts) != 2 || !strings.EqualFold(parts[0], scheme) || parts[1] == "" {
+		return "", ErrInvalidAuthScheme
+	}
+
+	return parts[1], nil
+}
+
+// WithHeaderName sets the header the middleware reads the token from, the
+// default is Authorization. It replaces any extractor set with
+// WithTokenExtractor.
+func WithHeaderName(name string) Option {
+	return func(a *Auth) {
+		a.headerName = name
+		a.extractor = FromHeaderWithScheme(a.headerName, a.authScheme)
+	}
+}
+
+// WithAuthScheme sets the scheme preceding the token in the header, the
+// default is Bearer. An empty scheme means the whole header value is the
+// token, as sent by clients using a header like X-Auth-Token. It replaces any
+// extractor set with WithTokenExtractor.
+func WithAuthScheme(scheme string) Option {
+	return func(a *Auth) {
+		a.authScheme = scheme
+		a.extractor = FromHeaderWithScheme(a.headerName, a.authScheme)
+	}
+}
+
+// FromCookie returns a TokenExtractor that reads the token from the named
+// cookie.
+func FromCookie(name string) TokenExtractor {
+	f := func(r *http.Request) (string, error) {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return "", ErrMissingToken
+		}
+		return cookie.Value, nil
+	}
+
+	return f
+}
+
+// FirstOf returns a TokenExtractor that tries each extractor in order and
+// uses the first token found. Extractors reporting ErrMissingToken are
+// skipped, while any other error stops the search so a malformed credential
+// never falls through to the next location.
+func FirstOf(extractors ...TokenExtractor) TokenExtractor {
+	f := func(r *http.Request) (string, error) {
+		for _, extract := range extractors {
+			tokenStr, err := extract(r)
+			if err == nil {
+				return tokenStr, nil
+			}
+			if !errors.Is(err, ErrMissingToken) {
+				return "", err
+			}
+		}
+		return "", ErrMissingToken
+	}
+
+	return f
+}
+
+// Authenticate is middleware that validates the bearer token on the request
+// and stores the resulting Claims in the request context under Key. Requests
+// without a valid token are handed to the unauthorized handler, which writes
+// a 401 by default. The error given to that handler is the error from the
+// TokenExtractor or the error returned by ValidateToken, annotated with the
+// TokenHash of the token so it can be logged.
+func (a *Auth) Authenticate(next http.Handler) http.Handler {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		claims, err := a.AuthenticateRequest(r)
+		switch {
+		case errors.Is(err, ErrTooManyFailures):
+			WriteErrorResponse(w, http.StatusTooManyRequests, err)
+			return
+		case err != nil:
+			a.unauthorized(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), Key, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(h)
+}
+
+// AuthenticateRequest does the work of Authenticate for middleware of other
+// frameworks. It extracts the token with the configured TokenExtractor, so
+// WithHeaderName and WithAuthScheme are honored, and validates it. Callers
+// blocked by the FailureLimiter get ErrTooManyFailures without the token
+// being validated. Validation errors are annotated with the TokenHash of the
+// token.
+func (a *Auth) AuthenticateRequest(r *http.Request) (Claims, error) {
+	tokenStr, err := a.extractor(r)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if !a.limitAllowed(r) {
+		return Claims{}, ErrTooManyFailures
+	}
+
+	claims, err := a.ValidateTokenContext(r.Context(), tokenStr)
+	a.limitRecord(r, err)
+	if err != nil {
+		return Claims{}, errors.Wrapf(err, "token %s", a.TokenHash(tokenStr))
+	}
+
+	return claims, nil
+}
+
+// OptionalAuth is middleware for endpoints that serve anonymous and
+// authenticated users alike. When the request carries a valid token the
+// Claims are stored in the request context like Authenticate does. When the
+// token is missing or invalid the request proceeds without Claims instead of
+// being rejected, so FromContext tells if the user is authenticated.
+func (a *Auth) OptionalAuth(next http.Handler) http.Handler {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		tokenStr, err := a.extractor(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := a.ValidateTokenContext(r.Context(), tokenStr)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), Key, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(h)
+}
+
+// RequireRole returns middleware that only lets requests through when the
+// Claims in the request context have at least one of the roles, as checked
+// by Auth.Authorized. It must run
+// after Authenticate:
+//
+//	mux.Handle("/admin", a.Authenticate(a.RequireRole(auth.RoleAdmin)(h)))
+//
+// Requests without Claims are handed to the unauthorized handler with
+// ErrNoClaims. Requests lacking the roles are handed to the forbidden
+// handler with ErrForbidden.
+func (a *Auth) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := FromContext(r.Context())
+			if !ok {
+				a.unauthorized(w, r, ErrNoClaims)
+				return
+			}
+
+			if !a.Authorized(claims, roles...) {
+				a.forbidden(w, r, ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(h)
+	}
+
+	return m
+}
+
+// Protect returns middleware that authenticates the request and, when roles
+// are provided, requires at least one of them. It has the standard
+// func(http.Handler) http.Handler signature so it mounts directly on routers
+// such as chi:
+//
+//	r := chi.NewRouter()
+//	r.Group(func(r chi.Router) {
+//		r.Use(a.Protect())
+//		r.Get("/profile", profile)
+//	})
+//	r.Group(func(r chi.Router) {
+//		r.Use(a.Protect(auth.RoleAdmin))
+//		r.Delete("/users/{id}", deleteUser)
+//	})
+//
+// It is the same as chaining Authenticate and RequireRole.
+func (a *Auth) Protect(roles ...string) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		if len(roles) > 0 {
+			next = a.RequireRole(roles...)(next)
+		}
+		return a.Authenticate(next)
+	}
+
+	return m
+}
+
+// RequireSubject returns middleware that only lets requests through when the
+// subject (sub) of the Claims matches the value returned by param, such as an
+// id taken from the URL. This restricts users to their own resources. The
+// param function keeps it independent of the router, for chi:
+//
+//	r.With(a.RequireSubject(func(r *http.Request) string {
+//		return chi.URLParam(r, "id")
+//	})).Get("/users/{id}", getUser)
+//
+// It must run after Authenticate. Requests without Claims are handed to the
+// unauthorized handler with ErrNoClaims. Requests for another subject are
+// handed to the forbidden handler with ErrForbidden.
+func (a *Auth) RequireSubject(param func(r *http.Request) string) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := FromContext(r.Context())
+			if !ok {
+				a.unauthorized(w, r, ErrNoClaims)
+				return
+			}
+
+			if sub := param(r); sub == "" || claims.Subject != sub {
+				a.forbidden(w, r, ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(h)
+	}
+
+	return m
+}
+
+// RequireAudience returns middleware that only lets requests through when
+// the audience (aud) of the Claims includes aud, so one gateway can route
+// tokens for different audiences to their own handlers. Tokens carrying
+// several audiences pass when any of them matches. It must run after
+// Authenticate. Requests without Claims are handed to the unauthorized
+// handler with ErrNoClaims. Requests for another audience are handed to the
+// forbidden handler with ErrInvalidAudience.
+func (a *Auth) RequireAudience(aud string) func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := FromContext(r.Context())
+			if !ok {
+				a.unauthorized(w, r, ErrNoClaims)
+				return
+			}
+
+			if !claims.Audience.Contains(aud) {
+				a.forbidden(w, r, ErrInvalidAudience)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(h)
+	}
+
+	return m
+}
@@ -0,0 +1,359 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// okHandler responds with a 200 and records the Claims it was given.
+type okHandler struct {
+	claims Claims
+	ok     bool
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *okHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.claims, h.ok = FromContext(r.Context())
+}
+
+// serveToken serves a request carrying the bearer token, or no Authorization
+// header when it is empty, and returns the status code.
+func serveToken(t testing.TB, h http.Handler, tokenStr string) int {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if tokenStr != "" {
+		r.Header.Set("Authorization", "Bearer "+tokenStr)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w.Code
+}
+
+func TestOptionalAuth(t *testing.T) {
+	a := newTestAuth(t)
+
+	past := newTestAuth(t, WithClock(func() time.Time { return testNow.Add(-48 * time.Hour) }))
+	expired, err := past.GenerateTokenWithTTL(newClaims("user"), time.Hour)
+	if err != nil {
+		t.Fatalf("generating token: %v", err)
+	}
+
+	forged, err := New("another-secret-with-enough-entropy-for-hs256", "HS256", WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tokenStr string
+		subject  string
+	}{
+		{name: "valid", tokenStr: mustGenerate(t, a, newClaims("user")), subject: "user"},
+		{name: "missing"},
+		{name: "malformed", tokenStr: "not-a-token"},
+		{name: "expired", tokenStr: expired},
+		{name: "wrong key", tokenStr: mustGenerate(t, forged, newClaims("user"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &okHandler{}
+			if status := serveToken(t, a.OptionalAuth(next), tt.tokenStr); status != http.StatusOK {
+				t.Fatalf("status is %d, want %d", status, http.StatusOK)
+			}
+
+			if next.ok != (tt.subject != "") {
+				t.Fatalf("claims present is %v, want %v", next.ok, tt.subject != "")
+			}
+			if next.claims.Subject != tt.subject {
+				t.Errorf("subject is %q, want %q", next.claims.Subject, tt.subject)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		has    []string
+		roles  []string
+		token  bool
+		status int
+	}{
+		{name: "has role", has: []string{RoleUser}, roles: []string{RoleUser}, token: true, status: http.StatusOK},
+		{name: "one of", has: []string{RoleUser}, roles: []string{RoleAdmin, RoleUser}, token: true, status: http.StatusOK},
+		{name: "lacks role", has: []string{RoleUser}, roles: []string{RoleAdmin}, token: true, status: http.StatusForbidden},
+		{name: "no roles held", roles: []string{RoleUser}, token: true, status: http.StatusForbidden},
+		{name: "no claims", roles: []string{RoleUser}, status: http.StatusUnauthorized},
+		{
+			name:   "case insensitive",
+			opts:   []Option{WithCaseInsensitiveRoles()},
+			has:    []string{"user"},
+			roles:  []string{RoleUser},
+			token:  true,
+			status: http.StatusOK,
+		},
+		{
+			name:   "hierarchy",
+			opts:   []Option{WithRoleHierarchy(map[string][]string{RoleAdmin: {RoleUser}})},
+			has:    []string{RoleAdmin},
+			roles:  []string{RoleUser},
+			token:  true,
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t, tt.opts...)
+
+			next := &okHandler{}
+			h := a.RequireRole(tt.roles...)(next)
+
+			tokenStr := ""
+			if tt.token {
+				tokenStr = mustGenerate(t, a, newClaims("user", tt.has...))
+				h = a.Authenticate(h)
+			}
+
+			if status := serveToken(t, h, tokenStr); status != tt.status {
+				t.Fatalf("status is %d, want %d", status, tt.status)
+			}
+			if next.ok != (tt.status == http.StatusOK) {
+				t.Errorf("next handler reached is %v, want %v", next.ok, tt.status == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestProtect(t *testing.T) {
+	tests := []struct {
+		name   string
+		has    []string
+		roles  []string
+		token  string
+		status int
+	}{
+		{name: "authenticated", has: []string{RoleUser}, token: "valid", status: http.StatusOK},
+		{name: "has role", has: []string{RoleAdmin}, roles: []string{RoleAdmin}, token: "valid", status: http.StatusOK},
+		{name: "lacks role", has: []string{RoleUser}, roles: []string{RoleAdmin}, token: "valid", status: http.StatusForbidden},
+		{name: "no token", status: http.StatusUnauthorized},
+		{name: "invalid token", token: "not-a-token", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t)
+
+			next := &okHandler{}
+			h := a.Protect(tt.roles...)(next)
+
+			tokenStr := tt.token
+			if tokenStr == "valid" {
+				tokenStr = mustGenerate(t, a, newClaims("user", tt.has...))
+			}
+
+			if status := serveToken(t, h, tokenStr); status != tt.status {
+				t.Fatalf("status is %d, want %d", status, tt.status)
+			}
+			if next.ok != (tt.status == http.StatusOK) {
+				t.Errorf("next handler reached is %v, want %v", next.ok, tt.status == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestRequireSubject(t *testing.T) {
+	tests := []struct {
+		name   string
+		param  string
+		token  bool
+		status int
+	}{
+		{name: "own resource", param: "user", token: true, status: http.StatusOK},
+		{name: "other resource", param: "other", token: true, status: http.StatusForbidden},
+		{name: "no param", token: true, status: http.StatusForbidden},
+		{name: "no claims", param: "user", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t)
+
+			next := &okHandler{}
+			h := a.RequireSubject(func(r *http.Request) string { return tt.param })(next)
+
+			tokenStr := ""
+			if tt.token {
+				tokenStr = mustGenerate(t, a, newClaims("user"))
+				h = a.Authenticate(h)
+			}
+
+			if status := serveToken(t, h, tokenStr); status != tt.status {
+				t.Fatalf("status is %d, want %d", status, tt.status)
+			}
+			if next.ok != (tt.status == http.StatusOK) {
+				t.Errorf("next handler reached is %v, want %v", next.ok, tt.status == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestRequireAudience(t *testing.T) {
+	tests := []struct {
+		name   string
+		aud    ClaimStrings
+		token  bool
+		status int
+	}{
+		{name: "matching", aud: ClaimStrings{"orders"}, token: true, status: http.StatusOK},
+		{name: "not matching", aud: ClaimStrings{"billing"}, token: true, status: http.StatusForbidden},
+		{name: "one of several", aud: ClaimStrings{"billing", "orders"}, token: true, status: http.StatusOK},
+		{name: "none of several", aud: ClaimStrings{"billing", "users"}, token: true, status: http.StatusForbidden},
+		{name: "no audience", token: true, status: http.StatusForbidden},
+		{name: "no claims", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t)
+
+			next := &okHandler{}
+			h := a.RequireAudience("orders")(next)
+
+			tokenStr := ""
+			if tt.token {
+				claims := newClaims("user")
+				claims.Audience = tt.aud
+				tokenStr = mustGenerate(t, a, claims)
+				h = a.Authenticate(h)
+			}
+
+			if status := serveToken(t, h, tokenStr); status != tt.status {
+				t.Fatalf("status is %d, want %d", status, tt.status)
+			}
+			if next.ok != (tt.status == http.StatusOK) {
+				t.Errorf("next handler reached is %v, want %v", next.ok, tt.status == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestHeaderNameAndScheme(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		header string
+		value  string
+		status int
+		reason string
+	}{
+		{name: "default", header: "Authorization", value: "Bearer %s", status: http.StatusOK},
+		{name: "default scheme case", header: "Authorization", value: "bearer %s", status: http.StatusOK},
+		{name: "default missing", status: http.StatusUnauthorized, reason: "missing_token"},
+		{name: "default wrong scheme", header: "Authorization", value: "Token %s", status: http.StatusUnauthorized, reason: "invalid_auth_scheme"},
+		{name: "default no scheme", header: "Authorization", value: "%s", status: http.StatusUnauthorized, reason: "invalid_auth_scheme"},
+		{name: "scheme", opts: []Option{WithAuthScheme("Token")}, header: "Authorization", value: "Token %s", status: http.StatusOK},
+		{
+			name:   "scheme rejects bearer",
+			opts:   []Option{WithAuthScheme("Token")},
+			header: "Authorization",
+			value:  "Bearer %s",
+			status: http.StatusUnauthorized,
+			reason: "invalid_auth_scheme",
+		},
+		{name: "header", opts: []Option{WithHeaderName("X-Auth")}, header: "X-Auth", value: "Bearer %s", status: http.StatusOK},
+		{
+			name:   "header ignores authorization",
+			opts:   []Option{WithHeaderName("X-Auth")},
+			header: "Authorization",
+			value:  "Bearer %s",
+			status: http.StatusUnauthorized,
+			reason: "missing_token",
+		},
+		{
+			name:   "header without scheme",
+			opts:   []Option{WithHeaderName("X-Auth-Token"), WithAuthScheme("")},
+			header: "X-Auth-Token",
+			value:  "%s",
+			status: http.StatusOK,
+		},
+		{
+			name:   "options in either order",
+			opts:   []Option{WithAuthScheme(""), WithHeaderName("X-Auth-Token")},
+			header: "X-Auth-Token",
+			value:  " %s ",
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t, tt.opts...)
+			tokenStr := mustGenerate(t, a, newClaims("user"))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, fmt.Sprintf(tt.value, tokenStr))
+			}
+			w := httptest.NewRecorder()
+			a.Authenticate(&okHandler{}).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status is %d, want %d", w.Code, tt.status)
+			}
+			if tt.reason == "" {
+				return
+			}
+
+			var resp ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.Reason != tt.reason {
+				t.Errorf("reason is %q, want %q", resp.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestAuthenticateLogsTokenHash(t *testing.T) {
+	var got error
+	record := func(w http.ResponseWriter, r *http.Request, err error) {
+		got = err
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	a := newTestAuth(t, WithUnauthorizedHandler(record))
+
+	past := newTestAuth(t, WithClock(func() time.Time { return testNow.Add(-48 * time.Hour) }))
+	tokenStr, err := past.GenerateTokenWithTTL(newClaims("user"), time.Hour)
+	if err != nil {
+		t.Fatalf("generating token: %v", err)
+	}
+
+	if status := serveToken(t, a.Authenticate(&okHandler{}), tokenStr); status != http.StatusUnauthorized {
+		t.Fatalf("status is %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	msg := got.Error()
+	if !strings.Contains(msg, a.TokenHash(tokenStr)) {
+		t.Errorf("error %q lacks the token hash", msg)
+	}
+	if strings.Contains(msg, tokenStr) {
+		t.Errorf("error %q holds the token", msg)
+	}
+	if !errors.Is(got, ErrTokenExpired) {
+		t.Errorf("error is %v, want %v", got, ErrTokenExpired)
+	}
+}
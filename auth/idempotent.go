@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultIdempotencyWindow is how long GenerateTokenIdempotent returns the
+// same token for a key unless configured with WithIdempotencyStore.
+const DefaultIdempotencyWindow = time.Minute
+
+// ErrUnknownIdempotencyKey is returned by an IdempotencyStore for a key that
+// holds no token.
+var ErrUnknownIdempotencyKey = errors.New("unknown idempotency key")
+
+// IdempotencyStore remembers the tokens issued by GenerateTokenIdempotent.
+// The keys are hashes, never the idempotency keys themselves, and the tokens
+// are stored encrypted with a key derived from the idempotency key. Whoever
+// reads the store can't replay the tokens without also knowing the
+// idempotency keys, so use random keys, such as UUIDs, for them.
+type IdempotencyStore interface {
+	// Get returns the token stored under the key, or
+	// ErrUnknownIdempotencyKey once it has expired.
+	Get(key string) (string, error)
+
+	// PutIfAbsent stores the token under the key until exp, unless the key
+	// already holds a token, which is returned instead. It must be atomic
+	// so concurrent retries all get the same token.
+	PutIfAbsent(key string, token string, exp time.Time) (string, error)
+}
+
+// WithIdempotencyStore configures the store backing GenerateTokenIdempotent
+// and how long a token is returned again for retries of the same request. A
+// window of 0 uses DefaultIdempotencyWindow.
+func WithIdempotencyStore(s IdempotencyStore, window time.Duration) Option {
+	return func(a *Auth) {
+		a.idempotency = s
+		a.idempotencyWindow = window
+	}
+}
+
+// GenerateTokenIdempotent is GenerateTokenWithTTL for requests that may be
+// retried. Within the idempotency window, calls with the same key and claims
+// return the token issued first, with the same jti and iat, instead of a new
+// one. Once the window passes, or the first token expires, a new token is
+// issued. Claims differing from the first call, apart from the times and
+// jti, are treated as a different request so a reused key can never hand
+// out the token of someone else.
+func (a *Auth) GenerateTokenIdempotent(key string, claims Claims, ttl time.Duration) (string, error) {
+	if a.idempotency == nil {
+		return "", errors.New("idempotency store not configured")
+	}
+	if key == "" {
+		return "", errors.New("idempotency key is empty")
+	}
+
+	storeKey, sealKey, err := idempotencyKeys(key, claims)
+	if err != nil {
+		return "", errors.Wrap(err, "hashing idempotency key")
+	}
+
+	sealed, err := a.idempotency.Get(storeKey)
+	switch {
+	case err == nil:
+		return openToken(sealKey, sealed)
+	case !errors.Is(err, ErrUnknownIdempotencyKey):
+		return "", errors.Wrap(err, "looking up idempotency key")
+	}
+
+	token, err := a.GenerateTokenWithTTL(claims, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err = sealToken(sealKey, token)
+	if err != nil {
+		return "", errors.Wrap(err, "encrypting token")
+	}
+
+	window := a.idempotencyWindow
+	if window == 0 {
+		window = DefaultIdempotencyWindow
+	}
+	if ttl < window {
+		window = ttl
+	}
+
+	sealed, err = a.idempotency.PutIfAbsent(storeKey, sealed, a.clock().Add(window))
+	if err != nil {
+		return "", errors.Wrap(err, "storing token")
+	}
+
+	return openToken(sealKey, sealed)
+}
+
+// idempotencyKeys returns the store key for the idempotency key and the
+// claims requested with it, ignoring the claims set on issue, and the key
+// encrypting the token stored under it.
+func idempotencyKeys(key string, claims Claims) (string, []byte, error) {
+	claims.Id = ""
+	claims.IssuedAt = 0
+	claims.ExpiresAt = 0
+	claims.NotBefore = 0
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	input := key + "\x00" + string(data)
+	sealKey := sha256.Sum256([]byte("seal\x00" + input))
+
+	return hashToken(input), sealKey[:], nil
+}
+
+// sealToken encrypts the token with AES-GCM for the IdempotencyStore.
+func sealToken(key []byte, token string) (string, error) {
+	aead, err := idempotencyCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(token), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openToken decrypts a token sealed by sealToken.
+func openToken(key []byte, sealed string) (string, error) {
+	aead, err := idempotencyCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errors.New("decrypting stored token: malformed")
+	}
+
+	token, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.Wrap(err, "decrypting stored token")
+	}
+
+	return string(token), nil
+}
+
+// idempotencyCipher returns the AES-GCM cipher for the key.
+func idempotencyCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// idempotencyEntry is the value the MemoryIdempotencyStore tracks per key.
+type idempotencyEntry struct {
+	token string
+	exp   time.Time
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore. Tokens are
+// removed once they have expired, according to the clock of the Auth it is
+// attached to. It is safe for concurrent use.
+type MemoryIdempotencyStore struct {
+	mu          sync.Mutex
+	tokens      map[string]idempotencyEntry
+	clock       func() time.Time
+	lastCleanup time.Time
+}
+
+// NewMemoryIdempotencyStore constructs an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		tokens:      make(map[string]idempotencyEntry),
+		clock:       time.Now,
+		lastCleanup: time.Now(),
+	}
+}
+
+// useClock implements the clockUser interface.
+func (m *MemoryIdempotencyStore) useClock(clock func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = clock
+	m.lastCleanup = clock()
+}
+
+// Get implements the IdempotencyStore interface.
+func (m *MemoryIdempotencyStore) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, exists := m.tokens[key]
+	if !exists || m.clock().After(entry.exp) {
+		return "", ErrUnknownIdempotencyKey
+	}
+
+	return entry.token, nil
+}
+
+// PutIfAbsent implements the IdempotencyStore interface.
+func (m *MemoryIdempotencyStore) PutIfAbsent(key string, token string, exp time.Time) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, exists := m.tokens[key]; exists && !m.clock().After(entry.exp) {
+		return entry.token, nil
+	}
+
+	m.tokens[key] = idempotencyEntry{token: token, exp: exp}
+	m.cleanup()
+
+	return token, nil
+}
+
+// cleanup removes the expired tokens. It runs at most once per
+// cleanupInterval and must be called with the mutex held.
+func (m *MemoryIdempotencyStore) cleanup() {
+	now := m.clock()
+	if now.Sub(m.lastCleanup) < cleanupInterval {
+		return
+	}
+
+	for key, entry := range m.tokens {
+		if now.After(entry.exp) {
+			delete(m.tokens, key)
+		}
+	}
+	m.lastCleanup = now
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateTokenIdempotent(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		claims  Claims
+		advance time.Duration
+		same    bool
+	}{
+		{name: "retry", key: "key", claims: newClaims("user"), same: true},
+		{name: "retry within window", key: "key", claims: newClaims("user"), advance: 59 * time.Second, same: true},
+		{name: "retry after window", key: "key", claims: newClaims("user"), advance: time.Minute + time.Second},
+		{name: "other key", key: "other", claims: newClaims("user")},
+		{name: "other claims", key: "key", claims: newClaims("other")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			store := NewMemoryIdempotencyStore()
+			a := newTestAuth(t, WithClock(clock.Now), WithIdempotencyStore(store, time.Minute))
+
+			first, err := a.GenerateTokenIdempotent("key", newClaims("user"), time.Hour)
+			if err != nil {
+				t.Fatalf("generating token: %v", err)
+			}
+
+			// The store never holds a token that can be replayed.
+			for _, entry := range store.tokens {
+				if strings.Contains(entry.token, first) || strings.Count(entry.token, ".") == 2 {
+					t.Fatalf("store holds the token in plaintext")
+				}
+			}
+
+			clock.Advance(tt.advance)
+			second, err := a.GenerateTokenIdempotent(tt.key, tt.claims, time.Hour)
+			if err != nil {
+				t.Fatalf("generating token: %v", err)
+			}
+
+			if same := first == second; same != tt.same {
+				t.Errorf("same token is %v, want %v", same, tt.same)
+			}
+			if _, err := a.ValidateToken(second); err != nil {
+				t.Errorf("token was rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateTokenIdempotentRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		key  string
+	}{
+		{name: "no store", key: "key"},
+		{name: "empty key", opts: []Option{WithIdempotencyStore(NewMemoryIdempotencyStore(), 0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t, tt.opts...)
+
+			if _, err := a.GenerateTokenIdempotent(tt.key, newClaims("user"), time.Hour); err == nil {
+				t.Error("token was generated")
+			}
+		})
+	}
+}
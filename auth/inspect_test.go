@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	forger, err := New("another-secret-of-at-least-32-bytes", "HS256", WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	refresh := newClaims("user")
+	refresh.TokenType = TokenTypeRefresh
+
+	tests := []struct {
+		name    string
+		signer  *Auth
+		claims  Claims
+		kid     string
+		advance time.Duration
+		valid   bool
+		reason  string
+	}{
+		{name: "valid", claims: newClaims("user", RoleUser), valid: true},
+		{name: "valid with kid", claims: newClaims("user", RoleUser), kid: "next", valid: true},
+		{name: "refresh token", claims: refresh, valid: true},
+		{name: "expired", claims: newClaims("user", RoleUser), advance: 2 * time.Hour, reason: "token_expired"},
+		{name: "bad signature", signer: forger, claims: newClaims("user", RoleUser), reason: "bad_signature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, WithClock(clock.Now), WithIssuer("issuer"), WithAudience("audience"))
+			if tt.kid != "" {
+				if err := a.SetActiveKey(tt.kid, "next-secret-with-enough-entropy-for-hs256"); err != nil {
+					t.Fatalf("rotating key: %v", err)
+				}
+			}
+
+			signer := tt.signer
+			if signer == nil {
+				signer = a
+			}
+			claims := tt.claims
+			claims.UserName = "jdoe"
+			claims.Scope = "read"
+			claims.Issuer = "issuer"
+			claims.Audience = ClaimStrings{"audience"}
+			tokenStr := mustGenerate(t, signer, claims)
+			parsed, err := ParseUnverified(tokenStr)
+			if err != nil {
+				t.Fatalf("parsing token: %v", err)
+			}
+			clock.Advance(tt.advance)
+
+			info, err := a.Inspect(tokenStr)
+			if err != nil {
+				t.Fatalf("inspecting token: %v", err)
+			}
+
+			want := TokenInfo{
+				Header:        TokenHeader{Alg: "HS256", Kid: tt.kid, Typ: "JWT"},
+				ID:            parsed.Id,
+				Subject:       "user",
+				Username:      "jdoe",
+				Roles:         claims.Roles,
+				Scope:         "read",
+				Issuer:        "issuer",
+				Audience:      []string{"audience"},
+				IssuedAt:      time.Unix(claims.IssuedAt, 0),
+				ExpiresAt:     time.Unix(claims.ExpiresAt, 0),
+				Valid:         tt.valid,
+				InvalidReason: tt.reason,
+			}
+			if !reflect.DeepEqual(info, want) {
+				t.Errorf("info is %+v, want %+v", info, want)
+			}
+		})
+	}
+}
+
+func TestInspectMalformed(t *testing.T) {
+	a := newTestAuth(t)
+
+	for _, tokenStr := range []string{"", "not-a-token", "e30.bm90LWpzb24.c2ln"} {
+		if _, err := a.Inspect(tokenStr); err == nil {
+			t.Errorf("inspecting %q succeeded", tokenStr)
+		}
+	}
+}
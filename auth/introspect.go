@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// IntrospectionResponse is the RFC 7662 response written by the handler
+// from IntrospectionHandler. Only Active is set for inactive tokens.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+}
+
+// IntrospectionOption configures the handler from IntrospectionHandler.
+type IntrospectionOption func(*introspection)
+
+// WithIntrospectionBasicAuth protects the introspection endpoint with HTTP
+// basic authentication. The check function reports whether the credentials
+// of the caller are valid. RFC 7662 requires protecting the endpoint.
+func WithIntrospectionBasicAuth(check func(username, password string) bool) IntrospectionOption {
+	return func(i *introspection) {
+		i.check = check
+	}
+}
+
+// introspection is the handler from IntrospectionHandler.
+type introspection struct {
+	auth  *Auth
+	check func(username, password string) bool
+}
+
+// IntrospectionHandler returns an RFC 7662 token introspection endpoint. It
+// accepts the token in the "token" field of a POSTed form and responds with
+// an IntrospectionResponse. Tokens that fail validation for any reason are
+// reported as {"active":false} so the reason is never revealed. Opaque tokens
+// are supported when a TokenStore is configured.
+func IntrospectionHandler(a *Auth, opts ...IntrospectionOption) http.Handler {
+	i := introspection{
+		auth: a,
+	}
+
+	for _, opt := range opts {
+		opt(&i)
+	}
+
+	return &i
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (i *introspection) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if i.check != nil {
+		username, password, ok := r.BasicAuth()
+		if !ok || !i.check(username, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var resp IntrospectionResponse
+	if claims, err := i.auth.Validate(token); err == nil {
+		resp = IntrospectionResponse{
+			Active:    true,
+			Scope:     claims.Scope,
+			Username:  claims.UserName,
+			TokenType: claims.TokenType,
+			Exp:       claims.ExpiresAt,
+			Iat:       claims.IssuedAt,
+			Nbf:       claims.NotBefore,
+			Sub:       claims.Subject,
+			Aud:       claims.Audience,
+			Iss:       claims.Issuer,
+			Jti:       claims.Id,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestIntrospectionHandler(t *testing.T) {
+	a := newTestAuth(t)
+
+	claims := newClaims("user")
+	claims.UserName = "jdoe"
+	claims.Scope = "read"
+	active := mustGenerate(t, a, claims)
+	form := func(tokenStr string) string {
+		return url.Values{"token": {tokenStr}}.Encode()
+	}
+
+	tests := []struct {
+		name        string
+		opts        []IntrospectionOption
+		method      string
+		contentType string
+		body        string
+		user        string
+		status      int
+		want        *IntrospectionResponse
+	}{
+		{
+			name:   "active",
+			body:   form(active),
+			status: http.StatusOK,
+			want: &IntrospectionResponse{
+				Active:   true,
+				Scope:    "read",
+				Username: "jdoe",
+				Exp:      claims.ExpiresAt,
+				Iat:      claims.IssuedAt,
+				Sub:      "user",
+			},
+		},
+		{name: "inactive", body: form("not-a-token"), status: http.StatusOK, want: &IntrospectionResponse{}},
+		{name: "wrong method", method: http.MethodGet, body: form(active), status: http.StatusMethodNotAllowed},
+		{name: "wrong content type", contentType: "application/json", body: `{"token":"` + active + `"}`, status: http.StatusBadRequest},
+		{name: "no token", body: "", status: http.StatusBadRequest},
+		{
+			name:   "authenticated caller",
+			opts:   []IntrospectionOption{WithIntrospectionBasicAuth(func(u, p string) bool { return u == "rs" && p == "secret" })},
+			body:   form(active),
+			user:   "rs",
+			status: http.StatusOK,
+		},
+		{
+			name:   "unauthenticated caller",
+			opts:   []IntrospectionOption{WithIntrospectionBasicAuth(func(u, p string) bool { return u == "rs" && p == "secret" })},
+			body:   form(active),
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/x-www-form-urlencoded"
+			}
+
+			r := httptest.NewRequest(method, "/introspect", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", contentType)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, "secret")
+			}
+
+			w := httptest.NewRecorder()
+			IntrospectionHandler(a, tt.opts...).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status is %d, want %d", w.Code, tt.status)
+			}
+			if tt.want == nil {
+				return
+			}
+
+			// Decoding into a map catches fields an inactive token must not
+			// carry, even empty ones.
+			var fields map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if !tt.want.Active && len(fields) != 1 {
+				t.Errorf("inactive response is %s, want only active", w.Body.String())
+			}
+
+			var got IntrospectionResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if got.Jti == "" && tt.want.Active {
+				t.Error("response has no jti")
+			}
+			got.Jti = ""
+			if got.Active != tt.want.Active || got.Scope != tt.want.Scope || got.Username != tt.want.Username ||
+				got.Exp != tt.want.Exp || got.Iat != tt.want.Iat || got.Sub != tt.want.Sub {
+				t.Errorf("response is %+v, want %+v", got, *tt.want)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("Cache-Control is %q, want no-store", cc)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Encrypted tokens are compact JWEs using direct encryption (alg "dir") with
+// AES-256-GCM (enc "A256GCM"). The plaintext is the signed JWT produced by
+// GenerateToken, so an encrypted token is both confidential and signed.
+//
+// Key management: the encryption key is a 32 byte secret shared by every
+// service that issues or reads encrypted tokens. Set it with WithEncryptionKey
+// and generate it with a CSPRNG; don't reuse a password. When no key is set,
+// one is derived from the HMAC signing secret the Auth was constructed with,
+// which means anyone able to verify tokens can also decrypt them. The derived
+// key doesn't follow SetActiveKey, so rotating the signing key leaves the
+// outstanding encrypted tokens readable. Asymmetric Auths have no secret to
+// derive from and must set a key. Rotating the encryption key invalidates
+// every outstanding encrypted token.
+const (
+	jweAlg = "dir"
+	jweEnc = "A256GCM"
+)
+
+// encryptionKeyInfo separates the derived encryption key from the signing
+// secret it is derived from.
+const encryptionKeyInfo = "web-foundation/auth jwe A256GCM"
+
+// ErrNoEncryptionKey is returned when encrypted tokens are used without an
+// encryption key and none can be derived from the signing key.
+var ErrNoEncryptionKey = errors.New("encryption key not configured")
+
+// jweHeader is the protected header of an encrypted token.
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Cty string `json:"cty"`
+}
+
+// WithEncryptionKey sets the AES-256 key used by GenerateEncryptedToken and
+// ValidateEncryptedToken. The key must be 32 bytes long.
+func WithEncryptionKey(key []byte) Option {
+	return func(a *Auth) {
+		a.encryptionKey = key
+	}
+}
+
+// GenerateEncryptedToken generates a signed JWT for the claims, like
+// GenerateToken, and encrypts it so the claims can't be read by the client.
+func (a *Auth) GenerateEncryptedToken(claims Claims) (string, error) {
+	aead, err := a.encryptionCipher()
+	if err != nil {
+		return "", err
+	}
+
+	signed, err := a.GenerateToken(claims)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(jweHeader{Alg: jweAlg, Enc: jweEnc, Cty: "JWT"})
+	if err != nil {
+		return "", errors.Wrap(err, "encoding header")
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	iv := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", errors.Wrap(err, "generating iv")
+	}
+
+	sealed := aead.Seal(nil, iv, []byte(signed), []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-aead.Overhead()], sealed[len(sealed)-aead.Overhead():]
+
+	enc := base64.RawURLEncoding
+	return strings.Join([]string{
+		protected,
+		"",
+		enc.EncodeToString(iv),
+		enc.EncodeToString(ciphertext),
+		enc.EncodeToString(tag),
+	}, "."), nil
+}
+
+// ValidateEncryptedToken decrypts a token made by GenerateEncryptedToken and
+// validates the signed token inside it exactly like ValidateToken, including
+// the exp and nbf checks. Any error returned is a *ValidationError.
+func (a *Auth) ValidateEncryptedToken(tokenStr string) (Claims, error) {
+	signed, err := a.decrypt(tokenStr)
+	if err != nil {
+		return Claims{}, newValidationError(err)
+	}
+
+	return a.ValidateToken(signed)
+}
+
+// decrypt returns the plaintext of an encrypted token.
+func (a *Auth) decrypt(tokenStr string) (string, error) {
+	aead, err := a.encryptionCipher()
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.Split(tokenStr, ".")
+	if len(parts) != 5 || parts[1] != "" {
+		return "", errors.Wrap(ErrTokenMalformed, "token is not an encrypted token")
+	}
+
+	enc := base64.RawURLEncoding
+	header, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return "", errors.Wrap(ErrTokenMalformed, "decoding header")
+	}
+
+	var h jweHeader
+	if err := json.Unmarshal(header, &h); err != nil {
+		return "", errors.Wrap(ErrTokenMalformed, "decoding header")
+	}
+	if h.Alg != jweAlg || h.Enc != jweEnc {
+		return "", errors.Wrapf(ErrTokenMalformed, "unsupported encryption %s/%s", h.Alg, h.Enc)
+	}
+
+	iv, err := enc.DecodeString(parts[2])
+	if err != nil || len(iv) != aead.NonceSize() {
+		return "", errors.Wrap(ErrTokenMalformed, "decoding iv")
+	}
+	ciphertext, err := enc.DecodeString(parts[3])
+	if err != nil {
+		return "", errors.Wrap(ErrTokenMalformed, "decoding ciphertext")
+	}
+	tag, err := enc.DecodeString(parts[4])
+	if err != nil || len(tag) != aead.Overhead() {
+		return "", errors.Wrap(ErrTokenMalformed, "decoding tag")
+	}
+
+	plaintext, err := aead.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		return "", errors.Wrap(ErrInvalidSignature, "decrypting token")
+	}
+
+	return string(plaintext), nil
+}
+
+// deriveEncryptionKey derives the encryption key from an HMAC signing secret.
+// It returns nil for the keys of asymmetric methods.
+func deriveEncryptionKey(signingKey interface{}) []byte {
+	secret, ok := signingKey.([]byte)
+	if !ok {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encryptionKeyInfo))
+	return mac.Sum(nil)
+}
+
+// encryptionCipher returns the AES-GCM cipher for the configured or derived
+// encryption key.
+func (a *Auth) encryptionCipher() (cipher.AEAD, error) {
+	key := a.encryptionKey
+	if key == nil {
+		return nil, ErrNoEncryptionKey
+	}
+
+	if len(key) != 32 {
+		return nil, errors.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating cipher")
+	}
+
+	return cipher.NewGCM(block)
+}
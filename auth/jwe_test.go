@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// tamper replaces the first character of the part of the encrypted token.
+func tamper(tokenStr string, part int) string {
+	parts := strings.Split(tokenStr, ".")
+	c := "A"
+	if strings.HasPrefix(parts[part], "A") {
+		c = "B"
+	}
+	parts[part] = c + parts[part][1:]
+	return strings.Join(parts, ".")
+}
+
+func TestEncryptedToken(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	notYetValid := newClaims("user")
+	notYetValid.NotBefore = testNow.Add(10 * time.Minute).Unix()
+
+	tests := []struct {
+		name    string
+		issuer  []Option
+		reader  []Option
+		claims  Claims
+		modify  func(tokenStr string) string
+		advance time.Duration
+		err     error
+	}{
+		{name: "derived key", claims: newClaims("user", RoleUser)},
+		{name: "configured key", issuer: []Option{WithEncryptionKey(key)}, reader: []Option{WithEncryptionKey(key)}, claims: newClaims("user", RoleUser)},
+		{name: "derived and configured key", reader: []Option{WithEncryptionKey(key)}, claims: newClaims("user"), err: ErrInvalidSignature},
+		{name: "wrong key", issuer: []Option{WithEncryptionKey(key)}, reader: []Option{WithEncryptionKey(bytes.Repeat([]byte{2}, 32))}, claims: newClaims("user"), err: ErrInvalidSignature},
+		{name: "tampered ciphertext", claims: newClaims("user"), modify: func(s string) string { return tamper(s, 3) }, err: ErrInvalidSignature},
+		{name: "tampered tag", claims: newClaims("user"), modify: func(s string) string { return tamper(s, 4) }, err: ErrInvalidSignature},
+		{name: "tampered header", claims: newClaims("user"), modify: func(s string) string { return tamper(s, 0) }, err: ErrTokenMalformed},
+		{name: "not encrypted", claims: newClaims("user"), modify: func(string) string { return "e30.e30.c2ln" }, err: ErrTokenMalformed},
+		{name: "expired", claims: newClaims("user"), advance: 2 * time.Hour, err: ErrTokenExpired},
+		{name: "not yet valid", claims: notYetValid, err: ErrTokenNotYetValid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			issuer := newTestAuth(t, append([]Option{WithClock(clock.Now)}, tt.issuer...)...)
+			reader := newTestAuth(t, append([]Option{WithClock(clock.Now)}, tt.reader...)...)
+
+			tokenStr, err := issuer.GenerateEncryptedToken(tt.claims)
+			if err != nil {
+				t.Fatalf("generating token: %v", err)
+			}
+			if _, err := ParseUnverified(tokenStr); err == nil {
+				t.Error("claims of the encrypted token are readable")
+			}
+			if tt.modify != nil {
+				tokenStr = tt.modify(tokenStr)
+			}
+			clock.Advance(tt.advance)
+
+			got, err := reader.ValidateEncryptedToken(tokenStr)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err is %v, want %v", err, tt.err)
+				}
+				var ve *ValidationError
+				if !errors.As(err, &ve) {
+					t.Errorf("error is %T, want *ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+			if got.Subject != tt.claims.Subject {
+				t.Errorf("subject is %q, want %q", got.Subject, tt.claims.Subject)
+			}
+		})
+	}
+}
+
+func TestEncryptedTokenSurvivesKeyRotation(t *testing.T) {
+	a := newTestAuth(t)
+
+	tokenStr, err := a.GenerateEncryptedToken(newClaims("user"))
+	if err != nil {
+		t.Fatalf("generating token: %v", err)
+	}
+
+	if err := a.SetActiveKey("next", "next-secret-with-enough-entropy-for-hs256"); err != nil {
+		t.Fatalf("rotating key: %v", err)
+	}
+
+	if _, err := a.ValidateEncryptedToken(tokenStr); err != nil {
+		t.Errorf("token signed before the rotation was rejected: %v", err)
+	}
+
+	next, err := a.GenerateEncryptedToken(newClaims("user"))
+	if err != nil {
+		t.Fatalf("generating token: %v", err)
+	}
+	if _, err := a.ValidateEncryptedToken(next); err != nil {
+		t.Errorf("token signed after the rotation was rejected: %v", err)
+	}
+}
+
+func TestEncryptedTokenKeyErrors(t *testing.T) {
+	signer, _ := newRSASigner(t, "kid")
+	if _, err := signer.GenerateEncryptedToken(newClaims("user")); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("err is %v, want %v", err, ErrNoEncryptionKey)
+	}
+
+	a := newTestAuth(t, WithEncryptionKey([]byte("short")))
+	if _, err := a.GenerateEncryptedToken(newClaims("user")); err == nil {
+		t.Error("short encryption key was accepted")
+	}
+}
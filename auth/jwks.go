@@ -0,0 +1,454 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// DefaultJWKSRefreshInterval is how often the key set is refreshed in the
+// background unless configured with WithJWKSRefreshInterval.
+const DefaultJWKSRefreshInterval = 15 * time.Minute
+
+// jwksMinRefreshInterval limits how often a token with an unknown kid can
+// trigger a fetch, so a flood of bogus tokens can't hammer the provider.
+const jwksMinRefreshInterval = 10 * time.Second
+
+// jwksFetchTimeout bounds a fetch of the key set. The fetch is shared by
+// every validation waiting on it, so it doesn't run on the context of any
+// one of them.
+const jwksFetchTimeout = 10 * time.Second
+
+// jwksMaxBytes caps the size of the key set document we are willing to read.
+const jwksMaxBytes = 1 << 20
+
+// jwksMethods are the signing methods accepted for keys from a key set.
+var jwksMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// WithJWKSRefreshInterval sets how often a JWKS backed Auth refreshes the
+// key set in the background. The interval must be positive.
+func WithJWKSRefreshInterval(d time.Duration) Option {
+	return func(a *Auth) {
+		a.jwksInterval = d
+	}
+}
+
+// WithJWKSMaxAge sets how long the keys of a JWKS backed Auth may be used
+// after the key set was last fetched successfully. Once they are older every
+// validation first tries to refresh the key set, and tokens are rejected with
+// ErrStaleKeySet while it can't be. By default the cached keys are used for
+// as long as refreshing fails.
+func WithJWKSMaxAge(d time.Duration) Option {
+	return func(a *Auth) {
+		a.jwksMaxAge = d
+	}
+}
+
+// ErrStaleKeySet is returned for tokens validated by a JWKS backed Auth when
+// the cached key set is older than the WithJWKSMaxAge and can't be
+// refreshed.
+var ErrStaleKeySet = errors.New("key set is stale")
+
+// JWKSRecorder is implemented by a MetricsCollector that also counts the key
+// lookups and refreshes of a JWKS backed Auth.
+type JWKSRecorder interface {
+	// JWKSCacheHit is called when the key for a token is in the cache.
+	JWKSCacheHit()
+
+	// JWKSCacheMiss is called when the kid of a token isn't in the cache.
+	JWKSCacheMiss()
+
+	// JWKSRefreshFailed is called each time fetching the key set fails.
+	JWKSRefreshFailed()
+}
+
+// WithHTTPClient sets the client used to fetch the key set of a JWKS backed
+// Auth.
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *Auth) {
+		a.httpClient = client
+	}
+}
+
+// NewFromJWKS creates an Auth that validates tokens using the public keys
+// published at jwksURL. The key for a token is selected by the kid in its
+// header. The key set is refreshed in the background and immediately when a
+// token arrives with an unknown kid, with concurrent refreshes coalesced
+// into a single request. If a refresh fails the cached keys keep being used,
+// up to the WithJWKSMaxAge. Call Close to stop the background refresh.
+func NewFromJWKS(jwksURL string, opts ...Option) (*Auth, error) {
+	a := newAuth(jwt.SigningMethodRS256, nil, "", nil, opts)
+
+	if a.jwksInterval <= 0 {
+		return nil, errors.Errorf("refresh interval %v is not positive", a.jwksInterval)
+	}
+
+	ks := jwks{
+		url:      jwksURL,
+		client:   a.httpClient,
+		maxAge:   a.jwksMaxAge,
+		clock:    a.clock,
+		done:     make(chan struct{}),
+		onChange: a.clearCache,
+	}
+	ks.recorder, _ = a.metrics.(JWKSRecorder)
+
+	if err := ks.fetch(context.Background()); err != nil {
+		return nil, errors.Wrap(err, "fetching key set")
+	}
+
+	a.keyFunc = ks.keyFuncContext(context.Background())
+	a.parser.ValidMethods = jwksMethods
+	a.jwks = &ks
+
+	go ks.run(a.jwksInterval)
+
+	return a, nil
+}
+
+// Close stops any background work started by the Auth.
+func (a *Auth) Close() error {
+	if a.jwks != nil {
+		a.jwks.close()
+	}
+	return nil
+}
+
+// jwk is a single JSON Web Key as defined by RFC 7517.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwksKey is a parsed public key from the key set.
+type jwksKey struct {
+	key interface{}
+	alg string
+}
+
+// jwks maintains the cache of keys fetched from a JWKS endpoint. The keys
+// and the time they were fetched are replaced together under mu, so every
+// lookup sees a consistent snapshot.
+type jwks struct {
+	url      string
+	client   *http.Client
+	maxAge   time.Duration
+	clock    func() time.Time
+	recorder JWKSRecorder
+	done     chan struct{}
+	once     sync.Once
+
+	// onChange is called after a fetch removes or replaces a key.
+	onChange func()
+
+	// fetchMu guards the fetch in flight, which concurrent callers wait on
+	// instead of fetching themselves, and the time of the last attempt.
+	fetchMu     sync.Mutex
+	inflight    *jwksFetch
+	lastAttempt time.Time
+
+	mu      sync.RWMutex
+	keys    map[string]jwksKey
+	fetched time.Time
+}
+
+// jwksFetch is a fetch of the key set in flight. Its err is set before done
+// is closed.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// keyFuncContext returns a key func that fetches with the context.
+func (ks *jwks) keyFuncContext(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		return ks.keyFunc(ctx, t)
+	}
+}
+
+// keyFunc selects the key for the token by its kid. An unknown kid triggers
+// a refresh of the key set before failing.
+func (ks *jwks) keyFunc(ctx context.Context, t *jwt.Token) (interface{}, error) {
+	kid, ok := t.Header["kid"].(string)
+	if !ok {
+		return nil, errors.New("missing key id (kid) in token header")
+	}
+
+	k, exists, stale := ks.lookup(kid)
+	if stale {
+		ks.refreshUnknown(ctx)
+		if k, exists, stale = ks.lookup(kid); stale {
+			return nil, ErrStaleKeySet
+		}
+	}
+
+	if !exists {
+		ks.record(JWKSRecorder.JWKSCacheMiss)
+		if err := ks.refreshUnknown(ctx); err != nil {
+			return nil, errors.Wrap(err, "refreshing key set")
+		}
+		if k, exists, _ = ks.lookup(kid); !exists {
+			return nil, errors.Errorf("unknown key id (kid) %q", kid)
+		}
+	} else {
+		ks.record(JWKSRecorder.JWKSCacheHit)
+	}
+
+	if k.alg != "" && k.alg != t.Method.Alg() {
+		return nil, errors.Errorf("key %q doesn't support algorithm %s", kid, t.Method.Alg())
+	}
+
+	// Make sure the type of the key matches the signing method so a key
+	// can never be used with an algorithm of a different family.
+	switch k.key.(type) {
+	case *rsa.PublicKey:
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return k.key, nil
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := t.Method.(*jwt.SigningMethodECDSA); ok {
+			return k.key, nil
+		}
+	}
+
+	return nil, errors.Errorf("key %q doesn't support algorithm %s", kid, t.Method.Alg())
+}
+
+// lookup returns the cached key for the kid. It reports whether the cache is
+// older than the max age.
+func (ks *jwks) lookup(kid string) (k jwksKey, exists bool, stale bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	k, exists = ks.keys[kid]
+	return k, exists, ks.isStale()
+}
+
+// stale reports whether the cached keys are older than the max age.
+func (ks *jwks) stale() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.isStale()
+}
+
+// isStale is stale for callers holding mu.
+func (ks *jwks) isStale() bool {
+	return ks.maxAge > 0 && ks.clock().Sub(ks.fetched) > ks.maxAge
+}
+
+// record calls the method of the JWKSRecorder, if there is one.
+func (ks *jwks) record(f func(JWKSRecorder)) {
+	if ks.recorder != nil {
+		f(ks.recorder)
+	}
+}
+
+// refreshUnknown fetches the key set, for an unknown kid or a stale cache,
+// unless a fetch was attempted very recently. A fetch already in flight is
+// joined.
+func (ks *jwks) refreshUnknown(ctx context.Context) error {
+	ks.fetchMu.Lock()
+	recent := ks.inflight == nil && ks.clock().Sub(ks.lastAttempt) < jwksMinRefreshInterval
+	ks.fetchMu.Unlock()
+
+	if recent {
+		return nil
+	}
+	return ks.fetch(ctx)
+}
+
+// run refreshes the key set on the interval until the jwks is closed. A
+// failed refresh leaves the cached keys in place.
+func (ks *jwks) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ks.fetch(context.Background())
+		case <-ks.done:
+			return
+		}
+	}
+}
+
+// close stops the background refresh.
+func (ks *jwks) close() {
+	ks.once.Do(func() {
+		close(ks.done)
+	})
+}
+
+// fetch retrieves the key set and replaces the cached keys. When a fetch is
+// already in flight it waits for that one instead, so concurrent callers
+// share a single request and its result. The request runs detached from ctx
+// with its own timeout, so a caller giving up doesn't fail the fetch for the
+// others; cancelling ctx only stops this caller waiting.
+func (ks *jwks) fetch(ctx context.Context) error {
+	ks.fetchMu.Lock()
+	f := ks.inflight
+	if f == nil {
+		f = &jwksFetch{done: make(chan struct{})}
+		ks.inflight = f
+		ks.lastAttempt = ks.clock()
+		go ks.runFetch(f)
+	}
+	ks.fetchMu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runFetch loads the key set for the fetch in flight and releases its
+// waiters.
+func (ks *jwks) runFetch(f *jwksFetch) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+
+	f.err = ks.load(ctx)
+	if f.err != nil {
+		ks.record(JWKSRecorder.JWKSRefreshFailed)
+	}
+
+	ks.fetchMu.Lock()
+	ks.inflight = nil
+	ks.fetchMu.Unlock()
+	close(f.done)
+}
+
+// load requests the key set and replaces the cached keys. Keys that can't be
+// parsed or are of an unsupported type, like OKP or oct keys, are skipped.
+// It fails when no usable key remains, leaving the cached keys in place.
+func (ks *jwks) load(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, jwksMaxBytes)).Decode(&set); err != nil {
+		return errors.Wrap(err, "decoding key set")
+	}
+
+	keys := make(map[string]jwksKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = jwksKey{key: key, alg: k.Alg}
+	}
+
+	if len(keys) == 0 {
+		return errors.New("key set has no usable keys")
+	}
+
+	ks.mu.Lock()
+	changed := false
+	for kid, old := range ks.keys {
+		if k, exists := keys[kid]; !exists || k.alg != old.alg || !sameKey(old.key, k.key) {
+			changed = true
+			break
+		}
+	}
+	ks.keys = keys
+	ks.fetched = ks.clock()
+	ks.mu.Unlock()
+
+	if changed && ks.onChange != nil {
+		ks.onChange()
+	}
+
+	return nil
+}
+
+// publicKey converts the JSON Web Key into an RSA or ECDSA public key.
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding modulus")
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding x coordinate")
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding y coordinate")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, errors.Errorf("unsupported key type %q", k.Kty)
+}
+
+// decodeBigInt decodes a base64url encoded big-endian integer.
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
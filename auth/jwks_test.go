@@ -0,0 +1,353 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// jwksServer serves a key set and counts the requests for it.
+type jwksServer struct {
+	*httptest.Server
+	requests int32
+
+	mu     sync.Mutex
+	keys   []jwk
+	status int
+	before func()
+}
+
+// newJWKSServer starts a server publishing the keys. It is closed when the
+// test ends.
+func newJWKSServer(t testing.TB, keys ...jwk) *jwksServer {
+	t.Helper()
+
+	s := jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+
+		s.mu.Lock()
+		set := struct {
+			Keys []jwk `json:"keys"`
+		}{s.keys}
+		status, before := s.status, s.before
+		s.mu.Unlock()
+
+		if before != nil {
+			before()
+		}
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+
+	return &s
+}
+
+// setKeys replaces the published keys.
+func (s *jwksServer) setKeys(keys ...jwk) {
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+}
+
+// setStatus makes the server fail requests with the status, or serve the
+// key set again when it is 0.
+func (s *jwksServer) setStatus(status int) {
+	s.mu.Lock()
+	s.status = status
+	s.mu.Unlock()
+}
+
+// setBefore sets a function called at the start of every request.
+func (s *jwksServer) setBefore(fn func()) {
+	s.mu.Lock()
+	s.before = fn
+	s.mu.Unlock()
+}
+
+// fetches returns the number of requests for the key set.
+func (s *jwksServer) fetches() int {
+	return int(atomic.LoadInt32(&s.requests))
+}
+
+// newRSASigner returns an RS256 Auth signing with a new key under the kid,
+// and the JSON Web Key publishing its public key.
+func newRSASigner(t testing.TB, kid string) (*Auth, jwk) {
+	t.Helper()
+
+	priv, pub := newRSAKeyPEM(t)
+	a, err := NewAsymmetric(priv, pub, "RS256", WithKeyID(kid), WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	block, _ := pem.Decode(pub)
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("parsing public key: %v", err)
+	}
+
+	return a, rsaJWK(kid, key.(*rsa.PublicKey))
+}
+
+// newRSAKeyPEM generates an RSA key pair and returns it PEM encoded.
+func newRSAKeyPEM(t testing.TB) (priv []byte, pub []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("encoding public key: %v", err)
+	}
+
+	priv = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	pub = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return priv, pub
+}
+
+// rsaJWK returns the JSON Web Key for the RSA public key.
+func rsaJWK(kid string, key *rsa.PublicKey) jwk {
+	return jwk{
+		Kid: kid,
+		Kty: "RSA",
+		Alg: "RS256",
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// newJWKSAuth constructs a JWKS backed Auth for the server whose clock is
+// stopped at testNow. It is closed when the test ends.
+func newJWKSAuth(t testing.TB, s *jwksServer, opts ...Option) *Auth {
+	t.Helper()
+
+	opts = append([]Option{WithClock(func() time.Time { return testNow })}, opts...)
+	a, err := NewFromJWKS(s.URL, opts...)
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+
+	return a
+}
+
+func TestJWKSSkipsUnusableKeys(t *testing.T) {
+	signer, key := newRSASigner(t, "k1")
+	tokenStr := mustGenerate(t, signer, newClaims("user"))
+
+	oct := jwk{Kid: "oct", Kty: "oct"}
+	okp := jwk{Kid: "okp", Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	broken := jwk{Kid: "broken", Kty: "RSA", N: "!!", E: "AQAB"}
+	curve := jwk{Kid: "curve", Kty: "EC", Crv: "P-192"}
+
+	tests := []struct {
+		name string
+		keys []jwk
+		ok   bool
+	}{
+		{name: "usable key only", keys: []jwk{key}, ok: true},
+		{name: "usable key among unusable", keys: []jwk{oct, okp, broken, key, curve}, ok: true},
+		{name: "no usable key", keys: []jwk{oct, okp, broken, curve}, ok: false},
+		{name: "empty", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newJWKSServer(t, tt.keys...)
+
+			if !tt.ok {
+				if a, err := NewFromJWKS(s.URL); err == nil {
+					a.Close()
+					t.Fatal("key set was accepted")
+				}
+				return
+			}
+
+			a := newJWKSAuth(t, s)
+			if _, err := a.ValidateToken(tokenStr); err != nil {
+				t.Errorf("token was rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewFromJWKSRefreshInterval(t *testing.T) {
+	_, key := newRSASigner(t, "k1")
+	s := newJWKSServer(t, key)
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		ok       bool
+	}{
+		{name: "positive", interval: time.Minute, ok: true},
+		{name: "zero", interval: 0, ok: false},
+		{name: "negative", interval: -time.Second, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewFromJWKS(s.URL, WithJWKSRefreshInterval(tt.interval))
+			if err == nil {
+				a.Close()
+			}
+			if ok := err == nil; ok != tt.ok {
+				t.Errorf("err is %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestJWKSCoalescesFetches(t *testing.T) {
+	_, old := newRSASigner(t, "old")
+	signer, key := newRSASigner(t, "new")
+	tokenStr := mustGenerate(t, signer, newClaims("user"))
+
+	s := newJWKSServer(t, old)
+	clock := newTestClock()
+	a := newJWKSAuth(t, s, WithClock(clock.Now))
+
+	// Publish the new key and let the unknown kid trigger a refresh. The
+	// server is slow so the validations pile up on the fetch in flight.
+	s.setKeys(old, key)
+	s.setBefore(func() { time.Sleep(50 * time.Millisecond) })
+	clock.Advance(jwksMinRefreshInterval)
+
+	const validations = 50
+	errs := make(chan error, validations)
+	var wg sync.WaitGroup
+	for i := 0; i < validations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := a.ValidateToken(tokenStr)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("token was rejected: %v", err)
+		}
+	}
+	if got := s.fetches(); got != 2 {
+		t.Errorf("key set was fetched %d times, want 2", got)
+	}
+}
+
+func TestJWKSFetchDetachedFromContext(t *testing.T) {
+	_, old := newRSASigner(t, "old")
+	signer, key := newRSASigner(t, "new")
+	tokenStr := mustGenerate(t, signer, newClaims("user"))
+
+	s := newJWKSServer(t, old)
+	clock := newTestClock()
+	a := newJWKSAuth(t, s, WithClock(clock.Now))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s.setKeys(old, key)
+	s.setBefore(func() {
+		close(started)
+		<-release
+	})
+	clock.Advance(jwksMinRefreshInterval)
+
+	// The first validation starts the fetch and gives up on it.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := a.ValidateTokenContext(ctx, tokenStr)
+		first <- err
+	}()
+
+	<-started
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("first validation returned %v, want %v", err, context.Canceled)
+	}
+
+	// The second validation joins the same fetch, which carries on.
+	second := make(chan error, 1)
+	go func() {
+		_, err := a.ValidateToken(tokenStr)
+		second <- err
+	}()
+
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("second validation returned %v", err)
+	}
+	if got := s.fetches(); got != 2 {
+		t.Errorf("key set was fetched %d times, want 2", got)
+	}
+}
+
+func TestJWKSCachedClaimsStale(t *testing.T) {
+	tests := []struct {
+		name    string
+		advance time.Duration
+		status  int
+		err     error
+		fetches int
+	}{
+		{name: "fresh", advance: 30 * time.Second, fetches: 1},
+		{name: "stale refreshed", advance: 2 * time.Minute, fetches: 2},
+		{name: "stale refresh failing", advance: 2 * time.Minute, status: http.StatusInternalServerError, err: ErrStaleKeySet, fetches: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, key := newRSASigner(t, "k1")
+			claims := newClaims("user")
+			claims.ExpiresAt = testNow.Add(24 * time.Hour).Unix()
+			tokenStr := mustGenerate(t, signer, claims)
+
+			s := newJWKSServer(t, key)
+			clock := newTestClock()
+			a := newJWKSAuth(t, s, WithClock(clock.Now), WithValidationCache(10), WithJWKSMaxAge(time.Minute))
+
+			if _, err := a.ValidateToken(tokenStr); err != nil {
+				t.Fatalf("token was rejected: %v", err)
+			}
+
+			s.setStatus(tt.status)
+			clock.Advance(tt.advance)
+
+			_, err := a.ValidateToken(tokenStr)
+			if tt.err == nil && err != nil {
+				t.Errorf("token was rejected: %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("err is %v, want %v", err, tt.err)
+			}
+			if got := s.fetches(); got != tt.fetches {
+				t.Errorf("key set was fetched %d times, want %d", got, tt.fetches)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package auth
+
+// KeycloakAccess holds the roles Keycloak grants for the realm or a client.
+type KeycloakAccess struct {
+	Roles []string `json:"roles"`
+}
+
+// KeycloakClaims are the claims Keycloak puts its roles in: the realm roles
+// under realm_access.roles and the roles of each client under
+// resource_access.<client>.roles.
+type KeycloakClaims struct {
+	RealmAccess    KeycloakAccess            `json:"realm_access"`
+	ResourceAccess map[string]KeycloakAccess `json:"resource_access"`
+}
+
+// FlattenRoles returns the realm roles followed by the roles of the clients,
+// without duplicates. Roles of clients not listed are left out.
+func (k KeycloakClaims) FlattenRoles(clients ...string) []string {
+	var roles []string
+	seen := make(map[string]bool)
+	add := func(rs []string) {
+		for _, r := range rs {
+			if !seen[r] {
+				seen[r] = true
+				roles = append(roles, r)
+			}
+		}
+	}
+
+	add(k.RealmAccess.Roles)
+	for _, client := range clients {
+		add(k.ResourceAccess[client].Roles)
+	}
+
+	return roles
+}
+
+// keycloakAccessType is the typ claim of Keycloak access tokens.
+const keycloakAccessType = "Bearer"
+
+// WithKeycloakRoles fills Claims.Roles from the roles of Keycloak tokens,
+// flattening the realm roles and the roles of the listed clients, usually
+// the client id of the service itself. Any roles read from the token
+// otherwise, like with WithRoleClaim, are kept. Since Keycloak types its
+// access tokens "Bearer" those are accepted as access tokens too.
+func WithKeycloakRoles(clients ...string) Option {
+	return func(a *Auth) {
+		a.keycloak = true
+		a.keycloakClients = clients
+	}
+}
+
+// isKeycloakAccess returns true if the claims are of a Keycloak access token
+// accepted by WithKeycloakRoles.
+func (a *Auth) isKeycloakAccess(claims Claims) bool {
+	return a.keycloak && claims.TokenType == keycloakAccessType
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// keycloakPayload is an access token payload as issued by Keycloak for the
+// orders-api client, with its exp and iat left as verbs.
+const keycloakPayload = `{
+  "exp": %d,
+  "iat": %d,
+  "auth_time": 1767366245,
+  "jti": "0b7a7d0e-6a1f-4a67-9d3c-1f0c4e7f2a11",
+  "iss": "https://sso.example.com/realms/acme",
+  "aud": ["orders-api", "account"],
+  "sub": "f3c1d2a4-5b6e-4f70-8a9b-0c1d2e3f4a5b",
+  "typ": "Bearer",
+  "azp": "orders-web",
+  "nonce": "n-0S6_WzA2Mj",
+  "session_state": "5d2f6c1e-9b8a-4c3d-a2e1-f0e9d8c7b6a5",
+  "acr": "1",
+  "allowed-origins": ["https://orders.example.com"],
+  "realm_access": {
+    "roles": ["offline_access", "default-roles-acme", "uma_authorization", "USER"]
+  },
+  "resource_access": {
+    "orders-api": {"roles": ["order-admin", "USER"]},
+    "account": {"roles": ["manage-account", "manage-account-links", "view-profile"]}
+  },
+  "scope": "openid profile email",
+  "sid": "5d2f6c1e-9b8a-4c3d-a2e1-f0e9d8c7b6a5",
+  "email_verified": true,
+  "name": "Jane Doe",
+  "preferred_username": "jane",
+  "given_name": "Jane",
+  "family_name": "Doe",
+  "email": "jane@example.com"
+}`
+
+func TestKeycloakRoles(t *testing.T) {
+	tokenStr := signPayload(t, fmt.Sprintf(keycloakPayload, testNow.Add(5*time.Minute).Unix(), testNow.Unix()))
+
+	realm := []string{"offline_access", "default-roles-acme", "uma_authorization", "USER"}
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		roles    []string
+		username string
+		valid    bool
+	}{
+		{name: "without option", valid: false},
+		{name: "realm roles", opts: []Option{WithKeycloakRoles()}, roles: realm, valid: true},
+		{
+			name:  "client roles",
+			opts:  []Option{WithKeycloakRoles("orders-api")},
+			roles: append(append([]string(nil), realm...), "order-admin"),
+			valid: true,
+		},
+		{
+			name:  "several clients",
+			opts:  []Option{WithKeycloakRoles("orders-api", "account")},
+			roles: append(append([]string(nil), realm...), "order-admin", "manage-account", "manage-account-links", "view-profile"),
+			valid: true,
+		},
+		{name: "unknown client", opts: []Option{WithKeycloakRoles("billing-api")}, roles: realm, valid: true},
+		{
+			name:     "with strict claims",
+			opts:     []Option{WithKeycloakRoles("orders-api"), WithStrictClaims(), WithUsernameClaim("preferred_username")},
+			roles:    append(append([]string(nil), realm...), "order-admin"),
+			username: "jane",
+			valid:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t, tt.opts...)
+
+			claims, err := a.ValidateToken(tokenStr)
+			if !tt.valid {
+				// Keycloak types access tokens "Bearer", which is only
+				// accepted with WithKeycloakRoles.
+				if err == nil {
+					t.Error("Keycloak access token was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+
+			if !reflect.DeepEqual(claims.Roles, tt.roles) {
+				t.Errorf("roles are %v, want %v", claims.Roles, tt.roles)
+			}
+			if claims.Subject != "f3c1d2a4-5b6e-4f70-8a9b-0c1d2e3f4a5b" || claims.Email != "jane@example.com" || !claims.EmailVerified {
+				t.Errorf("standard claims are %+v", claims)
+			}
+			if claims.UserName != tt.username {
+				t.Errorf("username is %q, want %q", claims.UserName, tt.username)
+			}
+			if !a.Authorized(claims, RoleUser) {
+				t.Error("claims aren't authorized for USER")
+			}
+		})
+	}
+}
+
+func TestFlattenRoles(t *testing.T) {
+	kc := KeycloakClaims{
+		RealmAccess: KeycloakAccess{Roles: []string{"a", "b"}},
+		ResourceAccess: map[string]KeycloakAccess{
+			"one": {Roles: []string{"b", "c"}},
+			"two": {Roles: []string{"d"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		clients []string
+		want    []string
+	}{
+		{name: "realm only", want: []string{"a", "b"}},
+		{name: "deduplicated", clients: []string{"one"}, want: []string{"a", "b", "c"}},
+		{name: "client order", clients: []string{"two", "one"}, want: []string{"a", "b", "d", "c"}},
+		{name: "missing client", clients: []string{"three"}, want: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kc.FlattenRoles(tt.clients...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("roles are %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
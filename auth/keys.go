@@ -0,0 +1,478 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/pem"
+	"os"
+	"strings"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// NewAsymmetric creates an Auth that signs tokens with a PEM encoded private
+// key and validates them with the matching PEM encoded public key. The alg
+// must be one of the RSA (RS*, PS*), ECDSA (ES*) or EdDSA signing methods.
+// EdDSA keys may also be given in their raw form: a 32 byte seed or 64 byte
+// private key, and a 32 byte public key.
+func NewAsymmetric(privateKey, publicKey []byte, alg string, opts ...Option) (*Auth, error) {
+	method, err := signingMethod(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	signingKey, err := parsePrivateKey(method, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyKey, err := parsePublicKey(method, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAsymmetricAuth(method, signingKey, verifyKey, opts), nil
+}
+
+// NewValidator creates an Auth that can only validate tokens using a PEM
+// encoded public key. It is intended for resource servers that must never
+// be able to mint tokens. Calls to GenerateToken will fail.
+func NewValidator(publicKey []byte, alg string, opts ...Option) (*Auth, error) {
+	method, err := signingMethod(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyKey, err := parsePublicKey(method, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAsymmetricAuth(method, nil, verifyKey, opts), nil
+}
+
+// NewWithKeys creates an Auth that supports rotating signing secrets. The keys
+// map a key id (kid) to its secret. Tokens are signed with the secret for the
+// active kid and carry that kid in their header. Tokens are validated with the
+// secret matching the kid in their header, so tokens signed by any key in the
+// map remain valid until they expire.
+func NewWithKeys(keys map[string]string, activeKID string, alg string, opts ...Option) (*Auth, error) {
+	method, err := hmacMethod(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, exists := keys[activeKID]; !exists {
+		return nil, errors.Errorf("active key id %q not found in keys", activeKID)
+	}
+
+	// Copy the keys so the caller can't change them after construction.
+	secrets := make(map[string][]byte, len(keys))
+	for kid, secret := range keys {
+		if secret == "" {
+			return nil, errors.Errorf("signing key for key id %q is empty", kid)
+		}
+		secrets[kid] = []byte(secret)
+	}
+
+	return newHMACAuth(method, secrets, activeKID, opts), nil
+}
+
+// MaxTrialSecrets is the most secrets NewWithSecrets accepts.
+const MaxTrialSecrets = 4
+
+// NewWithSecrets creates an Auth for rotating HMAC secrets without kid
+// headers, as legacy tokens lack them. The secrets are ordered newest
+// first. Tokens are signed with the first secret and validated by trying
+// each secret in turn until one verifies the signature. Every secret tried
+// costs a signature verification, so a token signed with the last secret,
+// or a forged one, costs as many verifications as there are secrets, plus
+// one for the parser. Each secret also gives a forger another key to guess,
+// so the list is capped at MaxTrialSecrets. Drop old secrets once their
+// tokens have expired, and prefer NewWithKeys wherever tokens carry a kid.
+func NewWithSecrets(secrets []string, alg string, opts ...Option) (*Auth, error) {
+	method, err := hmacMethod(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secrets) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	if len(secrets) > MaxTrialSecrets {
+		return nil, errors.Errorf("at most %d signing keys are supported", MaxTrialSecrets)
+	}
+
+	// Copy the secrets so the caller can't change them after construction.
+	keys := make([][]byte, len(secrets))
+	for i, secret := range secrets {
+		if secret == "" {
+			return nil, errors.Errorf("signing key %d is empty", i)
+		}
+		keys[i] = []byte(secret)
+	}
+
+	return newAuth(method, keys[0], "", trialKeyFunc(keys), opts), nil
+}
+
+// trialKeyFunc returns a key func providing the first of the keys that
+// verifies the signature of the token. When none does the first key is
+// returned so the parser rejects the signature.
+func trialKeyFunc(keys [][]byte) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		parts := strings.Split(t.Raw, ".")
+		if len(parts) != 3 {
+			return nil, ErrTokenMalformed
+		}
+
+		input := parts[0] + "." + parts[1]
+		for _, key := range keys {
+			if err := t.Method.Verify(input, parts[2], key); err == nil {
+				return key, nil
+			}
+		}
+
+		return keys[0], nil
+	}
+}
+
+// newHMACAuth constructs an Auth that signs with the secret for activeKID and
+// verifies with the secret matching the kid in the token header. Tokens
+// without a kid are verified with the secret for the empty kid, if any.
+func newHMACAuth(method jwt.SigningMethod, secrets map[string][]byte, activeKID string, opts []Option) *Auth {
+	a := newAuth(method, secrets[activeKID], activeKID, nil, opts)
+	a.secrets = secrets
+	a.keyFunc = a.secretKeyFunc
+
+	return a
+}
+
+// secretKeyFunc looks up the HMAC secret for the kid in the token header.
+func (a *Auth) secretKeyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	a.keyMu.RLock()
+	defer a.keyMu.RUnlock()
+
+	secret, exists := a.secrets[kid]
+	if !exists {
+		// An Auth from New verifies every token with its secret, whatever
+		// the kid.
+		if secret, exists := a.secrets[""]; exists {
+			return secret, nil
+		}
+		if kid == "" {
+			return nil, errors.New("missing key id (kid) in token header")
+		}
+		return nil, errors.Errorf("unknown key id (kid) %q", kid)
+	}
+
+	return secret, nil
+}
+
+// activeKey returns the key used to sign tokens and its kid.
+func (a *Auth) activeKey() (interface{}, string) {
+	a.keyMu.RLock()
+	defer a.keyMu.RUnlock()
+
+	return a.signingKey, a.kid
+}
+
+// SetActiveKey makes secret, identified by kid, the key that signs new
+// tokens. It is safe to call while tokens are generated and validated. The
+// previous key remains registered so the tokens it signed stay valid. Only
+// Auths using HMAC secrets, from New or NewWithKeys, support rotation.
+func (a *Auth) SetActiveKey(kid, secret string) error {
+	a.keyMu.Lock()
+	defer a.keyMu.Unlock()
+
+	if err := a.addSecret(kid, secret); err != nil {
+		return err
+	}
+
+	a.signingKey = a.secrets[kid]
+	a.kid = kid
+
+	return nil
+}
+
+// AddVerificationKey registers secret, identified by kid, for validating
+// tokens without signing with it. Register a key on every service before
+// making it active anywhere so its tokens are accepted everywhere.
+func (a *Auth) AddVerificationKey(kid, secret string) error {
+	a.keyMu.Lock()
+	defer a.keyMu.Unlock()
+
+	return a.addSecret(kid, secret)
+}
+
+// addSecret registers a secret. A kid can't be changed to another secret
+// once registered, since tokens already validated, and possibly cached,
+// with the old secret would be ambiguous. It must be called with keyMu held.
+func (a *Auth) addSecret(kid, secret string) error {
+	if a.secrets == nil {
+		if _, ok := a.method.(*jwt.SigningMethodHMAC); ok {
+			return errors.New("secrets without key ids can't be added, construct the Auth with NewWithKeys")
+		}
+		return errors.Errorf("signing method %s doesn't use secrets, use AddPublicKey", a.method.Alg())
+	}
+
+	if secret == "" {
+		return errors.Errorf("signing key for key id %q is empty", kid)
+	}
+
+	if existing, exists := a.secrets[kid]; exists {
+		if string(existing) != secret {
+			return errors.Errorf("key id %q is already registered", kid)
+		}
+		return nil
+	}
+
+	a.secrets[kid] = []byte(secret)
+
+	return nil
+}
+
+// NewFromKeyFile creates an Auth using the HMAC secret stored in the file at
+// path. A trailing newline, as is common for mounted secrets, is removed.
+func NewFromKeyFile(path string, alg string, opts ...Option) (*Auth, error) {
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading key file")
+	}
+
+	secret = bytes.TrimRight(secret, "\r\n")
+	if len(secret) == 0 {
+		return nil, errors.Errorf("key file %s is empty", path)
+	}
+
+	return New(string(secret), alg, opts...)
+}
+
+// NewAsymmetricFromFiles creates an Auth using the private and public keys
+// stored in the files at privPath and pubPath. The files may hold PEM or
+// DER encoded keys.
+func NewAsymmetricFromFiles(privPath, pubPath string, alg string, opts ...Option) (*Auth, error) {
+	privateKey, err := readKeyFile(privPath, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := readKeyFile(pubPath, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAsymmetric(privateKey, publicKey, alg, opts...)
+}
+
+// readKeyFile reads a PEM or DER encoded key from the file at path. DER
+// encoded keys are converted to PEM using blockType so they can be parsed
+// like any other PEM key.
+func readKeyFile(path string, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading key file")
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		return data, nil
+	}
+
+	// DER encoded keys are ASN.1 sequences, which always start with 0x30.
+	if len(data) == 0 || data[0] != 0x30 {
+		return nil, errors.Errorf("key file %s doesn't contain a PEM block or DER key", path)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), nil
+}
+
+// WithKeyID sets the key id (kid) of the key an asymmetric Auth is
+// constructed with. Generated tokens carry it in their header, so they keep
+// validating after the key is rotated and registered with AddPublicKey.
+func WithKeyID(kid string) Option {
+	return func(a *Auth) {
+		a.kid = kid
+	}
+}
+
+// newAsymmetricAuth constructs an Auth that signs with signingKey and
+// verifies with the public keys registered by kid, starting with verifyKey.
+func newAsymmetricAuth(method jwt.SigningMethod, signingKey interface{}, verifyKey interface{}, opts []Option) *Auth {
+	a := newAuth(method, signingKey, "", nil, opts)
+	a.publicKeys = map[string]interface{}{a.kid: verifyKey}
+	a.keyFunc = a.publicKeyFunc
+
+	return a
+}
+
+// publicKeyFunc looks up the public key for the kid in the token header.
+// Until keys are added with AddPublicKey the key the Auth was constructed
+// with verifies every token, whatever the kid.
+func (a *Auth) publicKeyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	a.keyMu.RLock()
+	defer a.keyMu.RUnlock()
+
+	if key, exists := a.publicKeys[kid]; exists {
+		return key, nil
+	}
+
+	if len(a.publicKeys) == 1 {
+		for _, key := range a.publicKeys {
+			return key, nil
+		}
+	}
+
+	if kid == "" {
+		return nil, errors.New("missing key id (kid) in token header")
+	}
+	return nil, errors.Errorf("unknown key id (kid) %q", kid)
+}
+
+// AddPublicKey registers the PEM encoded public key, identified by kid, for
+// validating tokens signed by its private key, like the previous key during
+// a rotation. Tokens are verified with the key matching the kid in their
+// header only, so once a key is added tokens with an unknown kid fail. New
+// tokens are still signed with the current private key alone. It is safe to
+// call while tokens are generated and validated. Only Auths using public
+// keys, from NewAsymmetric, NewValidator or NewWithSigner, support it.
+func (a *Auth) AddPublicKey(kid string, publicKey []byte) error {
+	if kid == "" {
+		return errors.New("key id is empty")
+	}
+
+	key, err := parsePublicKey(a.method, publicKey)
+	if err != nil {
+		return err
+	}
+
+	a.keyMu.Lock()
+	defer a.keyMu.Unlock()
+
+	if a.publicKeys == nil {
+		return errors.New("adding public keys requires an asymmetric key")
+	}
+
+	if existing, exists := a.publicKeys[kid]; exists {
+		if !sameKey(existing, key) {
+			return errors.Errorf("key id %q is already registered", kid)
+		}
+		return nil
+	}
+
+	a.publicKeys[kid] = key
+
+	return nil
+}
+
+// RemoveKey unregisters the HMAC secret or public key identified by kid, once
+// the tokens it signed should no longer be accepted, like after a rotation or
+// a compromise. Tokens signed with it are rejected from now on, including the
+// ones in the validation cache. The key signing new tokens can't be removed.
+// It is safe to call while tokens are generated and validated.
+func (a *Auth) RemoveKey(kid string) error {
+	a.keyMu.Lock()
+	defer a.keyMu.Unlock()
+
+	if kid == a.kid && (a.signingKey != nil || a.signer != nil) {
+		return errors.Errorf("key id %q is the active key", kid)
+	}
+
+	switch {
+	case a.secrets != nil:
+		if _, exists := a.secrets[kid]; !exists {
+			return errors.Errorf("unknown key id (kid) %q", kid)
+		}
+		delete(a.secrets, kid)
+	case a.publicKeys != nil:
+		if _, exists := a.publicKeys[kid]; !exists {
+			return errors.Errorf("unknown key id (kid) %q", kid)
+		}
+		if len(a.publicKeys) == 1 {
+			return errors.New("the last public key can't be removed")
+		}
+		delete(a.publicKeys, kid)
+	default:
+		return errors.New("removing keys requires HMAC secrets or public keys")
+	}
+
+	a.clearCache()
+
+	return nil
+}
+
+// parsePrivateKey parses a PEM encoded private key for the signing method.
+func parsePrivateKey(method jwt.SigningMethod, key []byte) (interface{}, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		pk, err := jwt.ParseRSAPrivateKeyFromPEM(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing rsa private key")
+		}
+		return pk, nil
+
+	case *jwt.SigningMethodECDSA:
+		pk, err := jwt.ParseECPrivateKeyFromPEM(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing ecdsa private key")
+		}
+		return pk, nil
+
+	case *jwt.SigningMethodEd25519:
+		if block, _ := pem.Decode(key); block != nil {
+			pk, err := jwt.ParseEdPrivateKeyFromPEM(key)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing ed25519 private key")
+			}
+			return pk, nil
+		}
+
+		switch len(key) {
+		case ed25519.SeedSize:
+			return ed25519.NewKeyFromSeed(key), nil
+		case ed25519.PrivateKeySize:
+			return ed25519.PrivateKey(key), nil
+		}
+		return nil, errors.Errorf("ed25519 private key must be %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(key))
+	}
+
+	return nil, errors.Errorf("algorithm %s can't be used with an asymmetric key", method.Alg())
+}
+
+// parsePublicKey parses a PEM encoded public key for the signing method.
+func parsePublicKey(method jwt.SigningMethod, key []byte) (interface{}, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		pk, err := jwt.ParseRSAPublicKeyFromPEM(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing rsa public key")
+		}
+		return pk, nil
+
+	case *jwt.SigningMethodECDSA:
+		pk, err := jwt.ParseECPublicKeyFromPEM(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing ecdsa public key")
+		}
+		return pk, nil
+
+	case *jwt.SigningMethodEd25519:
+		if block, _ := pem.Decode(key); block != nil {
+			pk, err := jwt.ParseEdPublicKeyFromPEM(key)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing ed25519 public key")
+			}
+			return pk, nil
+		}
+
+		if len(key) != ed25519.PublicKeySize {
+			return nil, errors.Errorf("ed25519 public key must be %d bytes, got %d", ed25519.PublicKeySize, len(key))
+		}
+		return ed25519.PublicKey(key), nil
+	}
+
+	return nil, errors.Errorf("algorithm %s can't be used with an asymmetric key", method.Alg())
+}
@@ -0,0 +1,465 @@
+package auth
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestKeyRotationConcurrent(t *testing.T) {
+	a, err := NewWithKeys(map[string]string{"k0": "secret-0"}, "k0", "HS256", WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	const (
+		rotations = 50
+		workers   = 8
+	)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	// Rotate the keys while the workers generate and validate tokens.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+
+		for i := 1; i <= rotations; i++ {
+			kid := fmt.Sprintf("k%d", i)
+			secret := fmt.Sprintf("secret-%d", i)
+			if err := a.AddVerificationKey(kid, secret); err != nil {
+				t.Errorf("adding key %s: %v", kid, err)
+				return
+			}
+			if err := a.SetActiveKey(kid, secret); err != nil {
+				t.Errorf("activating key %s: %v", kid, err)
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var tokens []string
+			for {
+				select {
+				case <-done:
+					// Tokens signed by every generation stay valid.
+					for _, tokenStr := range tokens {
+						if _, err := a.ValidateToken(tokenStr); err != nil {
+							t.Errorf("token was rejected after rotation: %v", err)
+						}
+					}
+					return
+				default:
+				}
+
+				tokenStr, err := a.GenerateToken(newClaims("user"))
+				if err != nil {
+					t.Errorf("generating token: %v", err)
+					return
+				}
+				if _, err := a.ValidateToken(tokenStr); err != nil {
+					t.Errorf("token was rejected: %v", err)
+					return
+				}
+				tokens = append(tokens, tokenStr)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestKeyRotation(t *testing.T) {
+	tests := []struct {
+		name   string
+		rotate func(a *Auth) error
+		err    bool
+	}{
+		{
+			name:   "activate new key",
+			rotate: func(a *Auth) error { return a.SetActiveKey("k1", "secret-1") },
+		},
+		{
+			name:   "add verification key",
+			rotate: func(a *Auth) error { return a.AddVerificationKey("k1", "secret-1") },
+		},
+		{
+			name:   "change registered key",
+			rotate: func(a *Auth) error { return a.SetActiveKey("k0", "other") },
+			err:    true,
+		},
+		{
+			name:   "empty secret",
+			rotate: func(a *Auth) error { return a.AddVerificationKey("k1", "") },
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewWithKeys(map[string]string{"k0": "secret-0"}, "k0", "HS256", WithClock(func() time.Time { return testNow }))
+			if err != nil {
+				t.Fatalf("constructing auth: %v", err)
+			}
+			old := mustGenerate(t, a, newClaims("user"))
+
+			err = tt.rotate(a)
+			if (err != nil) != tt.err {
+				t.Fatalf("err is %v, want error %v", err, tt.err)
+			}
+
+			// The tokens signed before the rotation stay valid.
+			if _, err := a.ValidateToken(old); err != nil {
+				t.Errorf("old token was rejected: %v", err)
+			}
+			if _, err := a.ValidateToken(mustGenerate(t, a, newClaims("user"))); err != nil {
+				t.Errorf("new token was rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyRotationUnsupported(t *testing.T) {
+	trial, err := NewWithSecrets([]string{testSecret}, "HS256")
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+	asymmetric, _ := newRSASigner(t, "")
+
+	tests := []struct {
+		name  string
+		a     *Auth
+		fixes string
+	}{
+		{name: "secrets without key ids", a: trial, fixes: "NewWithKeys"},
+		{name: "public keys", a: asymmetric, fixes: "AddPublicKey"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, err := range []error{tt.a.SetActiveKey("k1", "secret-1"), tt.a.AddVerificationKey("k1", "secret-1")} {
+				if err == nil {
+					t.Fatal("secret was registered")
+				}
+				if !strings.Contains(err.Error(), tt.fixes) {
+					t.Errorf("error %q doesn't point to %s", err, tt.fixes)
+				}
+			}
+		})
+	}
+}
+
+func TestAddPublicKey(t *testing.T) {
+	clock := WithClock(func() time.Time { return testNow })
+
+	priv1, pub1 := newRSAKeyPEM(t)
+	priv2, pub2 := newRSAKeyPEM(t)
+
+	old, err := NewAsymmetric(priv1, pub1, "RS256", WithKeyID("k1"), clock)
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+	current, err := NewAsymmetric(priv2, pub2, "RS256", WithKeyID("k2"), clock)
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+	unknown, err := NewAsymmetric(priv2, pub2, "RS256", WithKeyID("k3"), clock)
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+	noKID, err := NewAsymmetric(priv1, pub1, "RS256", clock)
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	v, err := NewValidator(pub1, "RS256", WithKeyID("k1"), clock)
+	if err != nil {
+		t.Fatalf("constructing validator: %v", err)
+	}
+
+	// Until a second key is added the only key verifies any kid, so the new
+	// key's tokens fail on their signature.
+	if _, err := v.ValidateToken(mustGenerate(t, current, newClaims("user"))); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("error is %v, want %v", err, ErrInvalidSignature)
+	}
+
+	if err := v.AddPublicKey("k2", pub2); err != nil {
+		t.Fatalf("adding k2: %v", err)
+	}
+	if err := v.AddPublicKey("k2", pub2); err != nil {
+		t.Errorf("adding k2 again: %v", err)
+	}
+	if err := v.AddPublicKey("k2", pub1); err == nil {
+		t.Error("changing the key of k2 succeeded")
+	}
+	if err := v.AddPublicKey("", pub2); err == nil {
+		t.Error("adding a key without a kid succeeded")
+	}
+	if err := v.AddPublicKey("k4", []byte("not a key")); err == nil {
+		t.Error("adding a malformed key succeeded")
+	}
+	if err := newTestAuth(t).AddPublicKey("k2", pub2); err == nil {
+		t.Error("adding a public key to an HMAC auth succeeded")
+	}
+
+	tests := []struct {
+		name   string
+		signer *Auth
+		valid  bool
+	}{
+		{name: "previous key", signer: old, valid: true},
+		{name: "new key", signer: current, valid: true},
+		{name: "unknown kid", signer: unknown},
+		{name: "no kid", signer: noKID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateToken(mustGenerate(t, tt.signer, newClaims("user")))
+			if tt.valid && err != nil {
+				t.Errorf("validating token: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("token was accepted")
+			}
+		})
+	}
+}
+
+func TestNewWithSecrets(t *testing.T) {
+	clock := WithClock(func() time.Time { return testNow })
+	secrets := []string{"secret-new", "secret-mid", "secret-old"}
+
+	a, err := NewWithSecrets(secrets, "HS256", clock)
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	// signer returns an Auth signing with the secret, like a service that
+	// hasn't rotated yet.
+	signer := func(secret string) *Auth {
+		s, err := New(secret, "HS256", clock)
+		if err != nil {
+			t.Fatalf("constructing auth: %v", err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		tokenStr string
+		valid    bool
+	}{
+		{name: "own token", tokenStr: mustGenerate(t, a, newClaims("user")), valid: true},
+		{name: "newest secret", tokenStr: mustGenerate(t, signer("secret-new"), newClaims("user")), valid: true},
+		{name: "middle secret", tokenStr: mustGenerate(t, signer("secret-mid"), newClaims("user")), valid: true},
+		{name: "oldest secret", tokenStr: mustGenerate(t, signer("secret-old"), newClaims("user")), valid: true},
+		{name: "unknown secret", tokenStr: mustGenerate(t, signer("secret-other"), newClaims("user"))},
+		{name: "malformed", tokenStr: "not.a.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.ValidateToken(tt.tokenStr)
+			if tt.valid && err != nil {
+				t.Errorf("validating token: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("token was accepted")
+			}
+		})
+	}
+
+	// New tokens are signed with the newest secret only.
+	own := mustGenerate(t, a, newClaims("user"))
+	if _, err := signer("secret-new").ValidateToken(own); err != nil {
+		t.Errorf("token isn't signed with the newest secret: %v", err)
+	}
+	if _, err := signer("secret-mid").ValidateToken(own); err == nil {
+		t.Error("token is signed with an older secret")
+	}
+}
+
+func TestNewWithSecretsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []string
+		alg     string
+	}{
+		{name: "no secrets", alg: "HS256"},
+		{name: "empty secret", secrets: []string{"secret-new", ""}, alg: "HS256"},
+		{name: "too many secrets", secrets: []string{"s1", "s2", "s3", "s4", "s5"}, alg: "HS256"},
+		{name: "asymmetric algorithm", secrets: []string{"secret-new"}, alg: "RS256"},
+		{name: "none", secrets: []string{"secret-new"}, alg: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWithSecrets(tt.secrets, tt.alg); err == nil {
+				t.Error("auth was constructed")
+			}
+		})
+	}
+
+	secrets := make([]string, MaxTrialSecrets)
+	for i := range secrets {
+		secrets[i] = fmt.Sprintf("secret-%d", i)
+	}
+	if _, err := NewWithSecrets(secrets, "HS256"); err != nil {
+		t.Errorf("constructing auth with %d secrets: %v", MaxTrialSecrets, err)
+	}
+}
+
+func TestRemoveKey(t *testing.T) {
+	clock := WithClock(func() time.Time { return testNow })
+
+	hmac, err := NewWithKeys(map[string]string{"k1": testSecret, "k2": testSecret + "-2"}, "k2", "HS256", clock)
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+	trial, err := NewWithSecrets([]string{testSecret}, "HS256", clock)
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+	signer, _ := newRSASigner(t, "k1")
+	_, pub := newRSAKeyPEM(t)
+	if err := signer.AddPublicKey("k2", pub); err != nil {
+		t.Fatalf("adding public key: %v", err)
+	}
+	validator, err := NewValidator(pub, "RS256", WithKeyID("k1"), clock)
+	if err != nil {
+		t.Fatalf("constructing validator: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		auth *Auth
+		kid  string
+		ok   bool
+	}{
+		{name: "hmac secret", auth: hmac, kid: "k1", ok: true},
+		{name: "removed hmac secret", auth: hmac, kid: "k1"},
+		{name: "active hmac secret", auth: hmac, kid: "k2"},
+		{name: "trial secrets", auth: trial, kid: ""},
+		{name: "active public key", auth: signer, kid: "k1"},
+		{name: "public key", auth: signer, kid: "k2", ok: true},
+		{name: "unknown public key", auth: signer, kid: "k3"},
+		{name: "last public key", auth: validator, kid: "k1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.auth.RemoveKey(tt.kid)
+			if tt.ok && err != nil {
+				t.Errorf("removing key: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("key was removed")
+			}
+		})
+	}
+}
+
+// writeKeyFile writes the data to a file in a temporary directory and
+// returns its path.
+func writeKeyFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("writing key file: %v", err)
+	}
+
+	return path
+}
+
+func TestNewFromKeyFile(t *testing.T) {
+	tests := []struct {
+		name string
+		path func(t *testing.T) string
+		ok   bool
+	}{
+		{name: "secret", path: func(t *testing.T) string { return writeKeyFile(t, "secret", []byte(testSecret)) }, ok: true},
+		{name: "trailing newline", path: func(t *testing.T) string { return writeKeyFile(t, "secret", []byte(testSecret+"\r\n")) }, ok: true},
+		{name: "missing file", path: func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing") }},
+		{name: "empty file", path: func(t *testing.T) string { return writeKeyFile(t, "secret", []byte("\n")) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewFromKeyFile(tt.path(t), "HS256", WithClock(func() time.Time { return testNow }))
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("auth was constructed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("constructing auth: %v", err)
+			}
+
+			// The secret is the content of the file without the newline.
+			if _, err := a.ValidateToken(mustGenerate(t, newTestAuth(t), newClaims("user"))); err != nil {
+				t.Errorf("token signed with the secret was rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewAsymmetricFromFiles(t *testing.T) {
+	priv, pub := newRSAKeyPEM(t)
+	block, _ := pem.Decode(pub)
+
+	tests := []struct {
+		name string
+		priv []byte
+		pub  []byte
+		ok   bool
+	}{
+		{name: "pem", priv: priv, pub: pub, ok: true},
+		{name: "der public key", priv: priv, pub: block.Bytes, ok: true},
+		{name: "bad pem", priv: priv, pub: []byte("-----BEGIN PUBLIC KEY-----\nnot base64\n-----END PUBLIC KEY-----\n")},
+		{name: "not a key", priv: []byte("not a key"), pub: pub},
+		{name: "missing file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privPath := filepath.Join(t.TempDir(), "missing")
+			pubPath := privPath
+			if tt.priv != nil {
+				privPath = writeKeyFile(t, "private.pem", tt.priv)
+				pubPath = writeKeyFile(t, "public.pem", tt.pub)
+			}
+
+			a, err := NewAsymmetricFromFiles(privPath, pubPath, "RS256", WithClock(func() time.Time { return testNow }))
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("auth was constructed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("constructing auth: %v", err)
+			}
+
+			if _, err := a.ValidateToken(mustGenerate(t, a, newClaims("user"))); err != nil {
+				t.Errorf("validating token: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxUnixSeconds is the largest exp accepted in any case. Larger values are
+// past the year 5000 and almost certainly milliseconds mistaken for seconds.
+const maxUnixSeconds = 100_000_000_000
+
+// ErrTokenLifetimeTooLong is returned when a token expires further in the
+// future than allowed.
+var ErrTokenLifetimeTooLong = errors.New("token lifetime too long")
+
+// WithMaxTokenLifetime caps how far in the future the exp of a token may be.
+// GenerateToken refuses to sign longer lived tokens and ValidateToken rejects
+// them, which also catches an exp given in milliseconds.
+func WithMaxTokenLifetime(d time.Duration) Option {
+	return func(a *Auth) {
+		a.maxLifetime = d
+	}
+}
+
+// checkLifetime rejects an exp that is implausibly far in the future or past
+// the configured maximum lifetime from now.
+func (a *Auth) checkLifetime(exp int64, now time.Time) error {
+	if exp > maxUnixSeconds {
+		return errors.Wrap(ErrTokenLifetimeTooLong, "exp isn't in seconds")
+	}
+
+	if a.maxLifetime > 0 && time.Unix(exp, 0).After(now.Add(a.maxLifetime+a.leeway)) {
+		return ErrTokenLifetimeTooLong
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTooManyFailures is given to the error responses of Authenticate when the
+// caller is blocked by the FailureLimiter.
+var ErrTooManyFailures = errors.New("too many failed authentication attempts")
+
+// FailureLimiter slows down callers presenting invalid tokens, such as
+// scrapers probing for valid ones, so they don't cost a signature check each.
+// The key identifies the caller, for example by IP address.
+//
+// To back a FailureLimiter with Redis, count failures with INCR on a key
+// expiring after the window, block with a key expiring after the cooldown
+// once the count is reached, and DEL the count on success.
+type FailureLimiter interface {
+	Allow(key string) (bool, error)
+	Failure(key string) error
+	Success(key string) error
+}
+
+// WithFailureLimiter makes Authenticate consult the limiter before validating
+// a token and respond with a 429 to blocked callers. The key function
+// identifies the caller of a request, it defaults to RemoteIP when nil.
+// Errors from the limiter let the request through, so an unavailable limiter
+// doesn't take authentication down with it.
+func WithFailureLimiter(l FailureLimiter, key func(r *http.Request) string) Option {
+	return func(a *Auth) {
+		if key == nil {
+			key = RemoteIP
+		}
+		a.limiter = l
+		a.limiterKey = key
+	}
+}
+
+// RemoteIP returns the IP address of the client that sent the request. It
+// doesn't trust forwarding headers, use a key function that parses them
+// when running behind a proxy.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitAllowed reports whether the caller of the request may try to
+// authenticate.
+func (a *Auth) limitAllowed(r *http.Request) bool {
+	if a.limiter == nil {
+		return true
+	}
+
+	ok, err := a.limiter.Allow(a.limiterKey(r))
+	return ok || err != nil
+}
+
+// limitRecord records the outcome of the authentication of the request.
+func (a *Auth) limitRecord(r *http.Request, err error) {
+	if a.limiter == nil {
+		return
+	}
+
+	if err != nil {
+		a.limiter.Failure(a.limiterKey(r))
+		return
+	}
+	a.limiter.Success(a.limiterKey(r))
+}
+
+// limitEntry is the state the MemoryFailureLimiter tracks per key.
+type limitEntry struct {
+	tokens       float64
+	updated      time.Time
+	blockedUntil time.Time
+}
+
+// MemoryFailureLimiter is an in-memory FailureLimiter using a token bucket
+// per key. Each failure takes a token and the bucket refills over the window.
+// A key that runs out of tokens is blocked for the cooldown. Time is measured
+// with the clock of the Auth it is attached to. It is safe for concurrent
+// use.
+type MemoryFailureLimiter struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	cooldown    time.Duration
+	entries     map[string]*limitEntry
+	clock       func() time.Time
+	lastCleanup time.Time
+}
+
+// NewMemoryFailureLimiter constructs a MemoryFailureLimiter that blocks a key
+// for the cooldown after maxFailures failures within the window.
+func NewMemoryFailureLimiter(maxFailures int, window, cooldown time.Duration) *MemoryFailureLimiter {
+	return &MemoryFailureLimiter{
+		maxFailures: maxFailures,
+		window:      window,
+		cooldown:    cooldown,
+		entries:     make(map[string]*limitEntry),
+		clock:       time.Now,
+		lastCleanup: time.Now(),
+	}
+}
+
+// useClock implements the clockUser interface.
+func (m *MemoryFailureLimiter) useClock(clock func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = clock
+	m.lastCleanup = clock()
+}
+
+// Allow implements the FailureLimiter interface.
+func (m *MemoryFailureLimiter) Allow(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, exists := m.entries[key]
+	if !exists {
+		return true, nil
+	}
+
+	return !m.clock().Before(e.blockedUntil), nil
+}
+
+// Failure implements the FailureLimiter interface.
+func (m *MemoryFailureLimiter) Failure(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock()
+
+	e, exists := m.entries[key]
+	if !exists {
+		e = &limitEntry{tokens: float64(m.maxFailures), updated: now}
+		m.entries[key] = e
+	}
+
+	m.refill(e, now)
+	e.tokens--
+	if e.tokens < 1 {
+		e.blockedUntil = now.Add(m.cooldown)
+		e.tokens = float64(m.maxFailures)
+	}
+
+	m.cleanup(now)
+
+	return nil
+}
+
+// Success implements the FailureLimiter interface.
+func (m *MemoryFailureLimiter) Success(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+
+	return nil
+}
+
+// refill adds the tokens earned since the last update of the entry.
+func (m *MemoryFailureLimiter) refill(e *limitEntry, now time.Time) {
+	if m.window > 0 {
+		e.tokens += float64(m.maxFailures) * float64(now.Sub(e.updated)) / float64(m.window)
+	}
+	if e.tokens > float64(m.maxFailures) {
+		e.tokens = float64(m.maxFailures)
+	}
+	e.updated = now
+}
+
+// cleanup removes the entries of keys that are no longer blocked and have a
+// full bucket again. It runs at most once per cleanupInterval and must be
+// called with the mutex held.
+func (m *MemoryFailureLimiter) cleanup(now time.Time) {
+	if now.Sub(m.lastCleanup) < cleanupInterval {
+		return
+	}
+
+	for key, e := range m.entries {
+		if now.Before(e.blockedUntil) {
+			continue
+		}
+		if now.Sub(e.updated) >= m.window {
+			delete(m.entries, key)
+		}
+	}
+	m.lastCleanup = now
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryFailureLimiter(t *testing.T) {
+	clock := newTestClock()
+	a := newTestAuth(t, WithClock(clock.Now), WithFailureLimiter(NewMemoryFailureLimiter(2, time.Minute, time.Minute), nil))
+	h := a.Authenticate(&okHandler{})
+	valid := mustGenerate(t, a, newClaims("user"))
+
+	// The steps run one after the other against the same limiter, so the
+	// clock of the Auth decides when the caller is unblocked.
+	tests := []struct {
+		name    string
+		advance time.Duration
+		token   string
+		status  int
+	}{
+		{name: "first failure", token: "invalid", status: http.StatusUnauthorized},
+		{name: "second failure", token: "invalid", status: http.StatusUnauthorized},
+		{name: "blocked", token: valid, status: http.StatusTooManyRequests},
+		{name: "still blocked", advance: 59 * time.Second, token: valid, status: http.StatusTooManyRequests},
+		{name: "cooled down", advance: time.Second, token: valid, status: http.StatusOK},
+		{name: "success resets", token: "invalid", status: http.StatusUnauthorized},
+		{name: "allowed after reset", token: valid, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+
+		if status := serveToken(t, h, tt.token); status != tt.status {
+			t.Errorf("%s: status is %d, want %d", tt.name, status, tt.status)
+		}
+	}
+}
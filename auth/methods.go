@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// AcceptMethod makes ValidateToken also accept tokens signed with alg and
+// verified with key, as when migrating from HS256 to RS256. Tokens are still
+// only generated with the signing method of the Auth. The key is a secret for
+// the HMAC methods and a PEM encoded public key otherwise.
+//
+// Each accepted algorithm has its own keys, parsed for that algorithm, and
+// the key is picked by the alg and kid headers of the token. That prevents
+// algorithm confusion: a token claiming HS256 is only ever verified with an
+// HS256 secret, never with an RSA public key used as a secret. The key given
+// here verifies the tokens of alg whose kid has no key of its own from
+// AddSigningKey.
+func (a *Auth) AcceptMethod(alg string, key []byte) error {
+	method, err := signingMethod(alg)
+	if err != nil {
+		return err
+	}
+
+	var verifyKey interface{}
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(key) == 0 {
+			return errors.New("signing key is empty")
+		}
+		verifyKey = append([]byte(nil), key...)
+	default:
+		verifyKey, err = parsePublicKey(method, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.keyMu.Lock()
+	defer a.keyMu.Unlock()
+
+	for _, valid := range a.parser.ValidMethods {
+		if valid == alg {
+			return errors.Errorf("algorithm %s is already accepted", alg)
+		}
+	}
+
+	a.acceptMethod(alg, "", verifyKey)
+
+	return nil
+}
+
+// acceptMethod registers the key verifying tokens signed with alg by the key
+// of kid, and makes alg accepted. It must be called with keyMu held.
+func (a *Auth) acceptMethod(alg string, kid string, verifyKey interface{}) {
+	a.addMethodKey(alg, kid, verifyKey)
+
+	// Replace the slice rather than append so parsers copied earlier by
+	// verifyParser keep seeing their own.
+	methods := make([]string, 0, len(a.parser.ValidMethods)+1)
+	methods = append(methods, a.parser.ValidMethods...)
+	a.parser.ValidMethods = append(methods, alg)
+}
+
+// AddSigningKey registers key, identified by kid, for GenerateTokenWithMethod
+// to sign tokens with alg. The key is a secret for the HMAC methods and a PEM
+// encoded private key otherwise. Its tokens are accepted by ValidateToken
+// too, verified with the key (or its public key) registered for alg and kid,
+// and alg is accepted as with AcceptMethod when it isn't yet. Several kids
+// can be added for one alg, but a kid can't be changed to another key. For
+// the signing method of the Auth itself the key is registered by kid, like
+// with AddVerificationKey or AddPublicKey.
+func (a *Auth) AddSigningKey(alg string, kid string, key []byte) error {
+	method, err := signingMethod(alg)
+	if err != nil {
+		return err
+	}
+
+	var signingKey, verifyKey interface{}
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(key) == 0 {
+			return errors.New("signing key is empty")
+		}
+		signingKey = append([]byte(nil), key...)
+		verifyKey = signingKey
+	default:
+		signingKey, err = parsePrivateKey(method, key)
+		if err != nil {
+			return err
+		}
+		signer, ok := signingKey.(crypto.Signer)
+		if !ok {
+			return errors.Errorf("private key for %s has no public key", alg)
+		}
+		verifyKey = signer.Public()
+	}
+
+	a.keyMu.Lock()
+	defer a.keyMu.Unlock()
+
+	if err := a.addMethodVerifyKey(alg, kid, verifyKey); err != nil {
+		return err
+	}
+
+	if a.signingKeys == nil {
+		a.signingKeys = make(map[string]interface{})
+	}
+	a.signingKeys[signingKeyID(alg, kid)] = signingKey
+
+	return nil
+}
+
+// addMethodVerifyKey makes sure tokens signed with alg by the key of kid are
+// accepted, registering verifyKey where needed. It must be called with keyMu
+// held.
+func (a *Auth) addMethodVerifyKey(alg string, kid string, verifyKey interface{}) error {
+	if alg == a.method.Alg() {
+		switch {
+		case a.secrets != nil:
+			return a.addSecret(kid, string(verifyKey.([]byte)))
+		case a.publicKeys != nil:
+			if kid == "" {
+				return errors.New("key id is empty")
+			}
+			if existing, exists := a.publicKeys[kid]; exists {
+				if !sameKey(existing, verifyKey) {
+					return errors.Errorf("key id %q is already registered", kid)
+				}
+				return nil
+			}
+			a.publicKeys[kid] = verifyKey
+			return nil
+		}
+		return errors.Errorf("signing keys can't be added for %s", alg)
+	}
+
+	if existing, exists := a.methodKeys[signingKeyID(alg, kid)]; exists {
+		if !sameKey(existing, verifyKey) {
+			return errors.Errorf("key id %q is already registered for %s", kid, alg)
+		}
+		return nil
+	}
+
+	if a.methodAlgs[alg] {
+		a.addMethodKey(alg, kid, verifyKey)
+		return nil
+	}
+
+	for _, valid := range a.parser.ValidMethods {
+		if valid == alg {
+			return errors.Errorf("signing keys can't be added for %s", alg)
+		}
+	}
+
+	a.acceptMethod(alg, kid, verifyKey)
+
+	return nil
+}
+
+// addMethodKey registers the key verifying tokens signed with alg by the key
+// of kid. It must be called with keyMu held.
+func (a *Auth) addMethodKey(alg string, kid string, verifyKey interface{}) {
+	if a.methodKeys == nil {
+		a.methodKeys = make(map[string]interface{})
+		a.methodAlgs = make(map[string]bool)
+	}
+	a.methodKeys[signingKeyID(alg, kid)] = verifyKey
+	a.methodAlgs[alg] = true
+}
+
+// sameKey returns true if the verification keys are equal.
+func sameKey(a, b interface{}) bool {
+	if secret, ok := a.([]byte); ok {
+		other, ok := b.([]byte)
+		return ok && hmac.Equal(secret, other)
+	}
+
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
+
+// signingKeyID is the key of the key for alg and kid in the signingKeys and
+// methodKeys maps.
+func signingKeyID(alg, kid string) string {
+	return alg + " " + kid
+}
+
+// GenerateTokenWithMethod is GenerateToken but signs with method using the
+// key registered for it and kid with AddSigningKey, and sets kid as the kid
+// header when it isn't empty. This lets one Auth issue, say, RS256 tokens to
+// new clients and HS256 tokens to a legacy integration. The signing method
+// and active kid of the Auth itself use its own key. It fails when no key is
+// registered for the method and kid.
+func (a *Auth) GenerateTokenWithMethod(claims Claims, method jwt.SigningMethod, kid string) (string, error) {
+	if method == nil {
+		return "", errors.New("signing method is nil")
+	}
+
+	if _, activeKID := a.activeKey(); method.Alg() == a.method.Alg() && kid == activeKID {
+		return a.GenerateToken(claims)
+	}
+
+	a.keyMu.RLock()
+	signingKey, exists := a.signingKeys[signingKeyID(method.Alg(), kid)]
+	a.keyMu.RUnlock()
+
+	if !exists {
+		return "", errors.Errorf("no signing key registered for %s with key id %q", method.Alg(), kid)
+	}
+
+	gen := func(claims Claims) (string, error) {
+		return a.generateWith(claims, method, signingKey, kid, nil)
+	}
+
+	return a.issue(context.Background(), claims, gen)
+}
+
+// verifyParser returns a copy of the parser that is safe to use while
+// AcceptMethod changes the accepted methods.
+func (a *Auth) verifyParser() jwt.Parser {
+	a.keyMu.RLock()
+	defer a.keyMu.RUnlock()
+
+	return a.parser
+}
+
+// methodKeyFunc returns a key function using the keys of the methods added
+// with AcceptMethod and AddSigningKey for tokens signed with them and keyFunc
+// otherwise. The key is the one registered for the kid of the token, or the
+// one given to AcceptMethod when the kid has none.
+func (a *Auth) methodKeyFunc(keyFunc jwt.Keyfunc) jwt.Keyfunc {
+	f := func(t *jwt.Token) (interface{}, error) {
+		alg := t.Method.Alg()
+		kid, _ := t.Header["kid"].(string)
+
+		a.keyMu.RLock()
+		accepted := a.methodAlgs[alg]
+		key, exists := a.methodKeys[signingKeyID(alg, kid)]
+		if !exists {
+			key, exists = a.methodKeys[signingKeyID(alg, "")]
+		}
+		a.keyMu.RUnlock()
+
+		switch {
+		case exists:
+			return key, nil
+		case accepted:
+			return nil, errors.Errorf("unknown key id (kid) %q for %s", kid, alg)
+		}
+		return keyFunc(t)
+	}
+
+	return f
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+func TestAddSigningKey(t *testing.T) {
+	a := newTestAuth(t)
+
+	priv1, _ := newRSAKeyPEM(t)
+	priv2, _ := newRSAKeyPEM(t)
+
+	if err := a.AddSigningKey("RS256", "k1", priv1); err != nil {
+		t.Fatalf("adding k1: %v", err)
+	}
+	if err := a.AddSigningKey("RS256", "k2", priv2); err != nil {
+		t.Fatalf("adding k2: %v", err)
+	}
+	if err := a.AddSigningKey("RS256", "k1", priv1); err != nil {
+		t.Errorf("adding k1 again: %v", err)
+	}
+	if err := a.AddSigningKey("RS256", "k1", priv2); err == nil {
+		t.Error("changing the key of k1 succeeded")
+	}
+
+	other, _ := newRSASigner(t, "k3")
+	forged, _ := newRSASigner(t, "k1")
+
+	tests := []struct {
+		name     string
+		generate func() (string, error)
+		valid    bool
+	}{
+		{
+			name:     "own method",
+			generate: func() (string, error) { return a.GenerateToken(newClaims("user")) },
+			valid:    true,
+		},
+		{
+			name: "first key",
+			generate: func() (string, error) {
+				return a.GenerateTokenWithMethod(newClaims("user"), jwt.SigningMethodRS256, "k1")
+			},
+			valid: true,
+		},
+		{
+			name: "second key",
+			generate: func() (string, error) {
+				return a.GenerateTokenWithMethod(newClaims("user"), jwt.SigningMethodRS256, "k2")
+			},
+			valid: true,
+		},
+		{
+			name:     "unknown kid",
+			generate: func() (string, error) { return other.GenerateToken(newClaims("user")) },
+		},
+		{
+			name:     "known kid other key",
+			generate: func() (string, error) { return forged.GenerateToken(newClaims("user")) },
+		},
+		{
+			name: "unregistered method",
+			generate: func() (string, error) {
+				return a.GenerateTokenWithMethod(newClaims("user"), jwt.SigningMethodRS256, "k4")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenStr, err := tt.generate()
+			if err != nil {
+				if tt.valid {
+					t.Fatalf("generating token: %v", err)
+				}
+				return
+			}
+
+			_, err = a.ValidateToken(tokenStr)
+			if tt.valid && err != nil {
+				t.Errorf("validating token: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("token was accepted")
+			}
+		})
+	}
+}
+
+func TestAcceptMethod(t *testing.T) {
+	a := newTestAuth(t)
+
+	priv, pub := newRSAKeyPEM(t)
+	signer, err := NewAsymmetric(priv, pub, "RS256", WithKeyID("legacy"), WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	// An HS256 secret made from the public key must not be accepted.
+	confused, err := New(string(pub), "HS256", WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	if err := a.AcceptMethod("RS256", pub); err != nil {
+		t.Fatalf("accepting RS256: %v", err)
+	}
+	if err := a.AcceptMethod("RS256", pub); err == nil {
+		t.Error("accepting RS256 twice succeeded")
+	}
+
+	kidPriv, _ := newRSAKeyPEM(t)
+	if err := a.AddSigningKey("RS256", "k1", kidPriv); err != nil {
+		t.Fatalf("adding k1: %v", err)
+	}
+	kidToken, err := a.GenerateTokenWithMethod(newClaims("user"), jwt.SigningMethodRS256, "k1")
+	if err != nil {
+		t.Fatalf("generating token: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tokenStr string
+		valid    bool
+	}{
+		{name: "accepted key", tokenStr: mustGenerate(t, signer, newClaims("user")), valid: true},
+		{name: "kid key", tokenStr: kidToken, valid: true},
+		{name: "own method", tokenStr: mustGenerate(t, a, newClaims("user")), valid: true},
+		{name: "public key as secret", tokenStr: mustGenerate(t, confused, newClaims("user"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.ValidateToken(tt.tokenStr)
+			if tt.valid && err != nil {
+				t.Errorf("validating token: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("token was accepted")
+			}
+		})
+	}
+}
+
+func TestAcceptMethodErrors(t *testing.T) {
+	_, pub := newRSAKeyPEM(t)
+
+	tests := []struct {
+		name string
+		alg  string
+		key  []byte
+	}{
+		{name: "none", alg: "none", key: []byte("key")},
+		{name: "unknown algorithm", alg: "XX256", key: []byte("key")},
+		{name: "own algorithm", alg: "HS256", key: []byte(testSecret)},
+		{name: "empty secret", alg: "HS384"},
+		{name: "malformed key", alg: "RS256", key: []byte("not a key")},
+		{name: "wrong key type", alg: "ES256", key: pub},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t)
+			if err := a.AcceptMethod(tt.alg, tt.key); err == nil {
+				t.Fatal("method was accepted")
+			}
+			if methods := a.verifyParser().ValidMethods; len(methods) != 1 {
+				t.Errorf("valid methods are %v, want only HS256", methods)
+			}
+		})
+	}
+}
+
+func TestValidateTokenUnacceptedMethod(t *testing.T) {
+	a := newTestAuth(t)
+
+	signer, _ := newRSASigner(t, "k1")
+	tokenStr := mustGenerate(t, signer, newClaims("user"))
+
+	if _, err := a.ValidateToken(tokenStr); err == nil {
+		t.Error("token signed with an unaccepted method was accepted")
+	}
+}
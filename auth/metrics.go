@@ -0,0 +1,27 @@
+package auth
+
+// MetricsCollector receives a call for every token operation. The
+// auth/metrics package provides a Prometheus backed implementation.
+type MetricsCollector interface {
+	// TokenGenerated is called each time a token is signed.
+	TokenGenerated()
+
+	// TokenValidated is called each time a token is validated. The reason is
+	// empty for a valid token, otherwise it is the Code of the Reason the
+	// token was rejected.
+	TokenValidated(reason string)
+}
+
+// WithMetrics configures the collector that records token operations. By
+// default nothing is recorded.
+func WithMetrics(m MetricsCollector) Option {
+	return func(a *Auth) {
+		a.metrics = m
+	}
+}
+
+// noopMetrics is the default MetricsCollector, which records nothing.
+type noopMetrics struct{}
+
+func (noopMetrics) TokenGenerated()              {}
+func (noopMetrics) TokenValidated(reason string) {}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// AMRMFA is the authentication method reference (amr) value indicating the
+// user completed multi-factor authentication, as registered by RFC 8176.
+const AMRMFA = "mfa"
+
+// ErrMFARequired is given to the forbidden handler by RequireMFA when the
+// token doesn't show multi-factor authentication.
+var ErrMFARequired = errors.New("multi-factor authentication required")
+
+// HasAMR returns true if the claims list the authentication method.
+func (c Claims) HasAMR(method string) bool {
+	for _, has := range c.AMR {
+		if has == method {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireMFA returns middleware that only lets requests through when the
+// Claims in the request context show the user completed multi-factor
+// authentication. It must run after Authenticate. Requests without Claims
+// are handed to the unauthorized handler with ErrNoClaims and requests
+// without MFA are handed to the forbidden handler with ErrMFARequired.
+func (a *Auth) RequireMFA() func(http.Handler) http.Handler {
+	m := func(next http.Handler) http.Handler {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := FromContext(r.Context())
+			if !ok {
+				a.unauthorized(w, r, ErrNoClaims)
+				return
+			}
+
+			if !claims.HasAMR(AMRMFA) {
+				a.forbidden(w, r, ErrMFARequired)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(h)
+	}
+
+	return m
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRequireMFA(t *testing.T) {
+	tests := []struct {
+		name   string
+		amr    []string
+		token  bool
+		status int
+	}{
+		{name: "mfa", amr: []string{"pwd", AMRMFA}, token: true, status: http.StatusOK},
+		{name: "mfa only", amr: []string{AMRMFA}, token: true, status: http.StatusOK},
+		{name: "single factor", amr: []string{"pwd"}, token: true, status: http.StatusForbidden},
+		{name: "other case", amr: []string{"pwd", "MFA"}, token: true, status: http.StatusForbidden},
+		{name: "no amr", token: true, status: http.StatusForbidden},
+		{name: "no claims", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got error
+			record := func(w http.ResponseWriter, r *http.Request, err error) {
+				got = err
+				WriteErrorResponse(w, http.StatusForbidden, err)
+			}
+			a := newTestAuth(t, WithForbiddenHandler(record))
+
+			next := &okHandler{}
+			h := a.RequireMFA()(next)
+
+			tokenStr := ""
+			if tt.token {
+				claims := newClaims("user", RoleUser)
+				claims.AMR = tt.amr
+				tokenStr = mustGenerate(t, a, claims)
+				h = a.Authenticate(h)
+			}
+
+			if status := serveToken(t, h, tokenStr); status != tt.status {
+				t.Fatalf("status is %d, want %d", status, tt.status)
+			}
+			if next.ok != (tt.status == http.StatusOK) {
+				t.Errorf("next handler reached is %v, want %v", next.ok, tt.status == http.StatusOK)
+			}
+			if tt.status == http.StatusForbidden && !errors.Is(got, ErrMFARequired) {
+				t.Errorf("forbidden error is %v, want %v", got, ErrMFARequired)
+			}
+		})
+	}
+}
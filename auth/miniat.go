@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTokenInvalidated is returned by ValidateToken when the token was issued
+// before the subject's tokens were invalidated.
+var ErrTokenInvalidated = errors.New("token issued before invalidation")
+
+// MinIATStore provides, per subject, the time before which issued tokens are
+// no longer valid. A zero time means no tokens have been invalidated. This
+// implements "log out everywhere" without tracking every token.
+type MinIATStore interface {
+	MinIAT(subject string) (time.Time, error)
+}
+
+// WithMinIATStore configures ValidateToken to reject tokens issued before
+// the threshold the store holds for the token's subject. Tokens without an
+// iat are rejected once their subject has a threshold.
+func WithMinIATStore(s MinIATStore) Option {
+	return func(a *Auth) {
+		a.minIAT = s
+	}
+}
+
+// checkMinIAT returns ErrTokenInvalidated when a MinIATStore is configured
+// and the token predates the subject's threshold. The iat only has second
+// precision, so the comparison is made in whole seconds and tokens issued
+// during the same second as the threshold remain valid.
+func (a *Auth) checkMinIAT(claims Claims) error {
+	if a.minIAT == nil {
+		return nil
+	}
+
+	threshold, err := a.minIAT.MinIAT(claims.Subject)
+	if err != nil {
+		return errors.Wrap(err, "checking min issued at")
+	}
+
+	if threshold.IsZero() {
+		return nil
+	}
+
+	if claims.IssuedAt == 0 || claims.IssuedAt < threshold.Unix() {
+		return ErrTokenInvalidated
+	}
+
+	return nil
+}
+
+// MemoryMinIATStore is an in-memory MinIATStore. It is safe for concurrent
+// use.
+type MemoryMinIATStore struct {
+	mu      sync.RWMutex
+	minIATs map[string]time.Time
+}
+
+// NewMemoryMinIATStore constructs an empty MemoryMinIATStore.
+func NewMemoryMinIATStore() *MemoryMinIATStore {
+	return &MemoryMinIATStore{
+		minIATs: make(map[string]time.Time),
+	}
+}
+
+// MinIAT implements the MinIATStore interface.
+func (m *MemoryMinIATStore) MinIAT(subject string) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.minIATs[subject], nil
+}
+
+// SetMinIAT invalidates every token of the subject issued before t. Passing
+// time.Now() logs the subject out everywhere.
+func (m *MemoryMinIATStore) SetMinIAT(subject string, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.minIATs[subject] = t
+
+	return nil
+}
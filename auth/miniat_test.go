@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// failingMinIATStore is a MinIATStore that always fails.
+type failingMinIATStore struct{}
+
+// MinIAT implements the MinIATStore interface.
+func (failingMinIATStore) MinIAT(subject string) (time.Time, error) {
+	return time.Time{}, errors.New("store unavailable")
+}
+
+func TestMinIATStore(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold time.Time
+		subject   string
+		store     MinIATStore
+		valid     bool
+		err       error
+	}{
+		{name: "no threshold", valid: true},
+		{name: "issued after", threshold: testNow.Add(-time.Minute), valid: true},
+		{name: "issued same second", threshold: testNow.Add(500 * time.Millisecond), valid: true},
+		{name: "issued before", threshold: testNow.Add(time.Second), err: ErrTokenInvalidated},
+		{name: "other subject", threshold: testNow.Add(time.Hour), subject: "other", valid: true},
+		{name: "store error", store: failingMinIATStore{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := tt.store
+			if store == nil {
+				m := NewMemoryMinIATStore()
+				if !tt.threshold.IsZero() {
+					subject := tt.subject
+					if subject == "" {
+						subject = "user"
+					}
+					if err := m.SetMinIAT(subject, tt.threshold); err != nil {
+						t.Fatalf("setting min iat: %v", err)
+					}
+				}
+				store = m
+			}
+
+			a := newTestAuth(t, WithMinIATStore(store))
+			_, err := a.ValidateToken(mustGenerate(t, a, newClaims("user")))
+
+			switch {
+			case tt.valid && err != nil:
+				t.Fatalf("validating token: %v", err)
+			case !tt.valid && err == nil:
+				t.Fatal("token was accepted")
+			case tt.err != nil && !errors.Is(err, tt.err):
+				t.Fatalf("error is %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package auth
+
+// OIDCProfile holds the OpenID Connect standard claims describing the user.
+type OIDCProfile struct {
+	Name              string `json:"name,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     bool   `json:"email_verified,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+}
+
+// OIDCProfile returns the profile claims, as served by a userinfo endpoint.
+// The username is returned as the preferred_username.
+func (c Claims) OIDCProfile() OIDCProfile {
+	return OIDCProfile{
+		Name:              c.Name,
+		GivenName:         c.GivenName,
+		FamilyName:        c.FamilyName,
+		PreferredUsername: c.UserName,
+		Email:             c.Email,
+		EmailVerified:     c.EmailVerified,
+		Picture:           c.Picture,
+	}
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// opaqueTokenBytes is the amount of randomness in an opaque token.
+const opaqueTokenBytes = 32
+
+// ErrUnknownToken is returned when an opaque token isn't in the TokenStore.
+var ErrUnknownToken = errors.New("unknown token")
+
+// TokenStore persists the claims behind opaque reference tokens. The key is
+// the SHA-256 hash of the token, never the token itself, so a leaked store
+// doesn't leak usable tokens.
+type TokenStore interface {
+	Put(key string, claims Claims, exp time.Time) error
+	Get(key string) (Claims, error)
+	Delete(key string) error
+}
+
+// WithTokenStore configures the store backing opaque reference tokens.
+func WithTokenStore(s TokenStore) Option {
+	return func(a *Auth) {
+		a.tokens = s
+	}
+}
+
+// GenerateOpaqueToken stores the claims in the TokenStore and returns a
+// random opaque token referencing them that expires ttl from now. This is for
+// clients that want a short bearer string rather than a JWT.
+func (a *Auth) GenerateOpaqueToken(claims Claims, ttl time.Duration) (string, error) {
+	if a.tokens == nil {
+		return "", errors.New("token store not configured")
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", errors.Wrap(err, "generating token")
+	}
+
+	if claims.Id == "" {
+		id, err := a.tokenID()
+		if err != nil {
+			return "", errors.Wrap(err, "generating token id")
+		}
+		claims.Id = id
+	}
+
+	now := a.clock()
+	exp := now.Add(ttl)
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = exp.Unix()
+
+	if err := a.tokens.Put(hashToken(token), claims, exp); err != nil {
+		return "", errors.Wrap(err, "storing token")
+	}
+
+	return token, nil
+}
+
+// ValidateOpaqueToken looks up the claims referenced by an opaque token and
+// validates them. Errors are returned as a *ValidationError.
+func (a *Auth) ValidateOpaqueToken(token string) (Claims, error) {
+	if a.tokens == nil {
+		return Claims{}, newValidationError(errors.New("token store not configured"))
+	}
+
+	claims, err := a.tokens.Get(hashToken(token))
+	if err != nil {
+		return Claims{}, newValidationError(errors.Wrap(err, "looking up token"))
+	}
+
+	if err := (&claimsValidation{a: a}).validate(claims); err != nil {
+		return Claims{}, newValidationError(errors.Wrap(err, "validating claims"))
+	}
+	if err := a.validateClaims(claims); err != nil {
+		return Claims{}, newValidationError(errors.Wrap(err, "validating claims"))
+	}
+
+	if err := a.checkPolicies(claims); err != nil {
+		return Claims{}, newValidationError(err)
+	}
+
+	return claims, nil
+}
+
+// Validate accepts either kind of token. It validates the token as a JWT
+// and, when it isn't one and a TokenStore is configured, falls back to
+// looking it up as an opaque token. This eases migrating between the two.
+func (a *Auth) Validate(token string) (Claims, error) {
+	claims, err := a.ValidateToken(token)
+	if err == nil || a.tokens == nil || ReasonOf(err) != ReasonMalformed {
+		return claims, err
+	}
+
+	return a.ValidateOpaqueToken(token)
+}
+
+// TokenHash returns the hex encoded SHA-256 hash of the token. It is stable
+// and can't be reversed to the token, so it can be logged to correlate a
+// token across logs and stores without exposing the token itself.
+func (a *Auth) TokenHash(tokenStr string) string {
+	return hashToken(tokenStr)
+}
+
+// newOpaqueToken generates a random opaque token.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the store key for an opaque token or API key.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// opaqueEntry is the value the MemoryTokenStore tracks per token.
+type opaqueEntry struct {
+	claims Claims
+	exp    time.Time
+}
+
+// MemoryTokenStore is an in-memory TokenStore. Tokens are removed once they
+// have expired, according to the clock of the Auth it is attached to. It is
+// safe for concurrent use.
+type MemoryTokenStore struct {
+	mu          sync.Mutex
+	tokens      map[string]opaqueEntry
+	clock       func() time.Time
+	lastCleanup time.Time
+}
+
+// NewMemoryTokenStore constructs an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens:      make(map[string]opaqueEntry),
+		clock:       time.Now,
+		lastCleanup: time.Now(),
+	}
+}
+
+// useClock implements the clockUser interface.
+func (m *MemoryTokenStore) useClock(clock func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = clock
+	m.lastCleanup = clock()
+}
+
+// Put implements the TokenStore interface.
+func (m *MemoryTokenStore) Put(key string, claims Claims, exp time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens[key] = opaqueEntry{claims: cloneClaims(claims), exp: exp}
+	m.cleanup()
+
+	return nil
+}
+
+// Get implements the TokenStore interface.
+func (m *MemoryTokenStore) Get(key string) (Claims, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, exists := m.tokens[key]
+	if !exists {
+		return Claims{}, ErrUnknownToken
+	}
+
+	if m.clock().After(entry.exp) {
+		delete(m.tokens, key)
+		return Claims{}, ErrUnknownToken
+	}
+
+	return cloneClaims(entry.claims), nil
+}
+
+// Delete implements the TokenStore interface.
+func (m *MemoryTokenStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tokens, key)
+
+	return nil
+}
+
+// cleanup removes the expired tokens. It runs at most once per
+// cleanupInterval and must be called with the mutex held.
+func (m *MemoryTokenStore) cleanup() {
+	now := m.clock()
+	if now.Sub(m.lastCleanup) < cleanupInterval {
+		return
+	}
+
+	for key, entry := range m.tokens {
+		if now.After(entry.exp) {
+			delete(m.tokens, key)
+		}
+	}
+	m.lastCleanup = now
+}
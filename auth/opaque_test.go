@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestValidateOpaqueToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   func(token string) string
+		advance time.Duration
+		err     error
+	}{
+		{name: "valid"},
+		{name: "within ttl", advance: 59 * time.Minute},
+		{name: "expired", advance: time.Hour + time.Second, err: ErrUnknownToken},
+		{name: "unknown", token: func(string) string { return "unknown" }, err: ErrUnknownToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, WithClock(clock.Now), WithTokenStore(NewMemoryTokenStore()))
+
+			token, err := a.GenerateOpaqueToken(newClaims("user", RoleUser), time.Hour)
+			if err != nil {
+				t.Fatalf("generating token: %v", err)
+			}
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+			clock.Advance(tt.advance)
+
+			claims, err := a.ValidateOpaqueToken(token)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("err is %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("token was rejected: %v", err)
+			}
+			if claims.Subject != "user" || !claims.HasRole(RoleUser) {
+				t.Errorf("claims are %+v", claims)
+			}
+		})
+	}
+}
+
+func TestValidateFallsBackToOpaque(t *testing.T) {
+	a := newTestAuth(t, WithTokenStore(NewMemoryTokenStore()))
+
+	opaque, err := a.GenerateOpaqueToken(newClaims("opaque"), time.Hour)
+	if err != nil {
+		t.Fatalf("generating token: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		subject string
+		err     error
+	}{
+		{name: "jwt", token: mustGenerate(t, a, newClaims("jwt")), subject: "jwt"},
+		{name: "opaque", token: opaque, subject: "opaque"},
+		{name: "unknown", token: "unknown", err: ErrUnknownToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := a.Validate(tt.token)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("err is %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("token was rejected: %v", err)
+			}
+			if claims.Subject != tt.subject {
+				t.Errorf("subject is %q, want %q", claims.Subject, tt.subject)
+			}
+		})
+	}
+}
+
+func TestTokenHash(t *testing.T) {
+	a := newTestAuth(t)
+
+	tests := []struct {
+		name     string
+		tokenStr string
+		want     string
+	}{
+		{name: "empty", tokenStr: "", want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{name: "abc", tokenStr: "abc", want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.TokenHash(tt.tokenStr); got != tt.want {
+				t.Errorf("hash is %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if a.TokenHash("abc") == a.TokenHash("abd") {
+		t.Error("different tokens have the same hash")
+	}
+}
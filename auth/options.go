@@ -0,0 +1,121 @@
+package auth
+
+import "time"
+
+// Option configures an Auth during construction.
+type Option func(*Auth)
+
+// WithIssuer sets the issuer (iss) stamped on generated tokens and required
+// on validated tokens.
+func WithIssuer(iss string) Option {
+	return func(a *Auth) {
+		a.issuer = iss
+	}
+}
+
+// WithTrustedIssuers sets the issuers accepted by ValidateToken. A token is
+// valid when its iss matches any of them. This takes precedence over the
+// issuer set with WithIssuer, which is still stamped on generated tokens.
+func WithTrustedIssuers(iss ...string) Option {
+	return func(a *Auth) {
+		a.trustedIssuers = iss
+	}
+}
+
+// WithAudience sets the audience (aud) stamped on generated tokens and
+// required on validated tokens.
+func WithAudience(aud string) Option {
+	return func(a *Auth) {
+		a.audience = aud
+	}
+}
+
+// WithLeeway sets the tolerance applied to the exp, nbf and iat checks to
+// account for clock drift between servers.
+func WithLeeway(d time.Duration) Option {
+	return func(a *Auth) {
+		a.leeway = d
+	}
+}
+
+// WithIssuanceSkew backdates the iat and nbf of generated tokens by d, so
+// receivers whose clock is up to d behind ours accept a token as soon as it
+// is minted. A scheduled nbf in the future is left alone.
+//
+// Issuance skew and WithLeeway address the same clock drift from both ends:
+// the skew is applied by the issuer and helps every receiver, the leeway is
+// applied by a receiver to every issuer. Tokens are accepted early when the
+// drift is within their sum, so only one of them needs to cover it. Neither
+// extends the exp of a token.
+func WithIssuanceSkew(d time.Duration) Option {
+	return func(a *Auth) {
+		a.issuanceSkew = d
+	}
+}
+
+// WithClock replaces the function used to get the current time, which
+// defaults to time.Now. Every exp, nbf and iat check made by ValidateToken and
+// every time stamped on a generated token uses this clock, so tests can mint
+// a token at a fixed time and advance the clock instead of sleeping. The
+// in-memory stores attached to the Auth, like a MemoryRevoker, expire their
+// entries with this clock too.
+func WithClock(clock func() time.Time) Option {
+	return func(a *Auth) {
+		a.clock = clock
+	}
+}
+
+// clockUser is implemented by the in-memory stores so they check the
+// expiries they record against the clock of the Auth they are attached to,
+// which is the same clock the expiries were computed with.
+type clockUser interface {
+	useClock(clock func() time.Time)
+}
+
+// shareClock hands the clock of the Auth to the stores that use one.
+func (a *Auth) shareClock() {
+	stores := []interface{}{a.revoker, a.families, a.tokens, a.limiter, a.sessions, a.sessionStore, a.idempotency}
+	for _, s := range stores {
+		if c, ok := s.(clockUser); ok {
+			c.useClock(a.clock)
+		}
+	}
+}
+
+// AllowNoExpiry lets GenerateToken sign claims without an expiration. Such
+// tokens are valid forever unless revoked, so use this with care.
+func AllowNoExpiry() Option {
+	return func(a *Auth) {
+		a.allowNoExpiry = true
+	}
+}
+
+// WithAutoClaims makes GenerateToken fill in registered claims the caller
+// left empty. See fillRegisteredClaims for exactly which fields are set.
+func WithAutoClaims() Option {
+	return func(a *Auth) {
+		a.autoClaims = true
+	}
+}
+
+// WithIDGenerator replaces the function generating the token id (jti) for
+// claims that don't provide one, which defaults to a random UUID. Ids must be
+// unique since they key revocation, so only use a deterministic generator in
+// tests.
+func WithIDGenerator(gen func() string) Option {
+	return func(a *Auth) {
+		a.idGenerator = gen
+	}
+}
+
+// WithCompactClaims makes GenerateToken omit the name, username and roles
+// claims when they are empty, instead of writing empty strings and a null
+// roles claim. This shrinks tokens and suits validators rejecting null roles.
+// ValidateToken decodes such tokens either way, but other parsers of our
+// tokens that require these claims to be present will reject them, so
+// enable this once they all treat the claims as optional.
+func WithCompactClaims() Option {
+	return func(a *Auth) {
+		a.compactClaims = true
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"unicode/utf8"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The scrypt parameters NewFromPassphrase derives the signing key with. They
+// take roughly 100ms and 32MiB on current hardware, so guessing a passphrase
+// from a stolen token is slow.
+const (
+	passphraseN = 1 << 15
+	passphraseR = 8
+	passphraseP = 1
+)
+
+// MinSaltLength is the minimum length in bytes of the salt passed to
+// NewFromPassphrase.
+const MinSaltLength = 16
+
+// MinPassphraseLength is the minimum length in characters of the passphrase
+// passed to NewFromPassphrase.
+const MinPassphraseLength = 20
+
+// NewFromPassphrase creates an Auth signing with an HMAC key derived from a
+// passphrase, for environments that only have a human passphrase instead of
+// a high-entropy key. The key is derived with scrypt (N=32768, r=8, p=1) and
+// is as long as the hash of the algorithm, like 32 bytes for HS256.
+//
+// The salt must be at least MinSaltLength random bytes. It isn't secret but
+// must be stored by the operator, since the same passphrase and salt are
+// needed to derive the same key again. Changing the passphrase or the salt
+// invalidates every token signed before. Derivation can't add entropy the
+// passphrase doesn't have, so passphrases shorter than MinPassphraseLength
+// characters are rejected. Use several random words.
+func NewFromPassphrase(passphrase string, salt []byte, alg string, opts ...Option) (*Auth, error) {
+	method, err := hmacMethod(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if utf8.RuneCountInString(passphrase) < MinPassphraseLength {
+		return nil, errors.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	}
+	if len(salt) < MinSaltLength {
+		return nil, errors.Errorf("salt must be at least %d bytes", MinSaltLength)
+	}
+
+	keyLen := method.(*jwt.SigningMethodHMAC).Hash.Size()
+	key, err := scrypt.Key([]byte(passphrase), salt, passphraseN, passphraseR, passphraseP, keyLen)
+	if err != nil {
+		return nil, errors.Wrap(err, "deriving signing key")
+	}
+
+	return newHMACAuth(method, map[string][]byte{"": key}, "", opts), nil
+}
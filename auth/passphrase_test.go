@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+var testSalt = bytes.Repeat([]byte{7}, MinSaltLength)
+
+// newPassphraseAuth constructs an Auth from the passphrase whose clock is
+// stopped at testNow.
+func newPassphraseAuth(t *testing.T, passphrase string, salt []byte, alg string) *Auth {
+	t.Helper()
+
+	a, err := NewFromPassphrase(passphrase, salt, alg, WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	return a
+}
+
+func TestNewFromPassphrase(t *testing.T) {
+	signer := newPassphraseAuth(t, testPassphrase, testSalt, "HS256")
+	tokenStr := mustGenerate(t, signer, newClaims("user"))
+
+	tests := []struct {
+		name       string
+		passphrase string
+		salt       []byte
+		err        error
+	}{
+		{name: "same passphrase and salt", passphrase: testPassphrase, salt: testSalt},
+		{name: "other salt", passphrase: testPassphrase, salt: bytes.Repeat([]byte{8}, MinSaltLength), err: ErrInvalidSignature},
+		{name: "other passphrase", passphrase: testPassphrase + "!", salt: testSalt, err: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newPassphraseAuth(t, tt.passphrase, tt.salt, "HS256")
+
+			_, err := a.ValidateToken(tokenStr)
+			if tt.err == nil && err != nil {
+				t.Fatalf("token was rejected: %v", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err is %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNewFromPassphraseKeyLength(t *testing.T) {
+	for alg, size := range map[string]int{"HS256": 32, "HS384": 48, "HS512": 64} {
+		a := newPassphraseAuth(t, testPassphrase, testSalt, alg)
+
+		key, _ := a.activeKey()
+		if n := len(key.([]byte)); n != size {
+			t.Errorf("%s key is %d bytes, want %d", alg, n, size)
+		}
+	}
+}
+
+func TestNewFromPassphraseErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+		salt       []byte
+		alg        string
+	}{
+		{name: "empty passphrase", salt: testSalt, alg: "HS256"},
+		{name: "short passphrase", passphrase: "hunter2", salt: testSalt, alg: "HS256"},
+		{name: "short multibyte passphrase", passphrase: strings.Repeat("é", MinPassphraseLength-1), salt: testSalt, alg: "HS256"},
+		{name: "no salt", passphrase: testPassphrase, alg: "HS256"},
+		{name: "short salt", passphrase: testPassphrase, salt: testSalt[:MinSaltLength-1], alg: "HS256"},
+		{name: "asymmetric algorithm", passphrase: testPassphrase, salt: testSalt, alg: "RS256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFromPassphrase(tt.passphrase, tt.salt, tt.alg); err == nil {
+				t.Error("auth was constructed")
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Package password provides bcrypt password hashing for the credentials
+// verified before a token is issued by the auth package.
+package password
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultCost is the bcrypt cost used by Hash.
+const DefaultCost = 12
+
+// MaxLength is the longest password, in bytes, bcrypt can hash.
+const MaxLength = 72
+
+// These are the errors returned by Compare.
+var (
+	ErrMismatch      = errors.New("password doesn't match")
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// These are the errors returned by Hash and HashWithCost.
+var (
+	ErrTooLong     = errors.Errorf("password is longer than %d bytes", MaxLength)
+	ErrInvalidCost = errors.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+)
+
+// Hash hashes the password with bcrypt using DefaultCost.
+func Hash(password string) (string, error) {
+	return HashWithCost(password, DefaultCost)
+}
+
+// HashWithCost hashes the password with bcrypt using the cost. It returns
+// ErrTooLong when the password is longer than MaxLength bytes, rather than
+// hashing only a prefix of it, and ErrInvalidCost when the cost isn't between
+// bcrypt.MinCost and bcrypt.MaxCost, rather than using a default cost.
+func HashWithCost(password string, cost int) (string, error) {
+	if len(password) > MaxLength {
+		return "", ErrTooLong
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return "", errors.Wrapf(ErrInvalidCost, "cost %d", cost)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", errors.Wrap(err, "hashing password")
+	}
+
+	return string(hash), nil
+}
+
+// Compare checks the password against a hash made by Hash. It returns
+// ErrMismatch when the password is wrong and ErrMalformedHash when the hash
+// can't be used.
+func Compare(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return ErrMismatch
+	default:
+		return errors.Wrap(ErrMalformedHash, err.Error())
+	}
+}
+
+// NeedsRehash reports whether the hash was made with a cost other than the
+// given one, or can't be read at all. Rehash the password after a successful
+// Compare when it does, to move stored hashes to a new cost over time.
+func NeedsRehash(hash string, cost int) bool {
+	current, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+
+	return current != cost
+}
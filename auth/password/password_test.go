@@ -0,0 +1,106 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashWithCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		cost     int
+		err      error
+	}{
+		{name: "min cost", password: "correct horse", cost: bcrypt.MinCost},
+		{name: "empty", password: "", cost: bcrypt.MinCost},
+		{name: "max length", password: strings.Repeat("a", MaxLength), cost: bcrypt.MinCost},
+		{name: "too long", password: strings.Repeat("a", MaxLength+1), cost: bcrypt.MinCost, err: ErrTooLong},
+		{name: "cost too low", password: "correct horse", cost: bcrypt.MinCost - 1, err: ErrInvalidCost},
+		{name: "zero cost", password: "correct horse", cost: 0, err: ErrInvalidCost},
+		{name: "cost too high", password: "correct horse", cost: bcrypt.MaxCost + 1, err: ErrInvalidCost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := HashWithCost(tt.password, tt.cost)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error is %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("hashing password: %v", err)
+			}
+
+			if err := Compare(hash, tt.password); err != nil {
+				t.Errorf("comparing password: %v", err)
+			}
+			if NeedsRehash(hash, tt.cost) {
+				t.Errorf("hash needs rehash at cost %d", tt.cost)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	hash, err := HashWithCost("correct horse", bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		err      error
+	}{
+		{name: "match", hash: hash, password: "correct horse"},
+		{name: "mismatch", hash: hash, password: "battery staple", err: ErrMismatch},
+		{name: "empty password", hash: hash, password: "", err: ErrMismatch},
+		{name: "malformed hash", hash: "not a hash", password: "correct horse", err: ErrMalformedHash},
+		{name: "empty hash", hash: "", password: "correct horse", err: ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Compare(tt.hash, tt.password)
+			if tt.err == nil && err != nil {
+				t.Fatalf("comparing password: %v", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error is %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := HashWithCost("correct horse", bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		cost int
+		want bool
+	}{
+		{name: "same cost", hash: hash, cost: bcrypt.MinCost},
+		{name: "other cost", hash: hash, cost: DefaultCost, want: true},
+		{name: "malformed hash", hash: "not a hash", cost: bcrypt.MinCost, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash, tt.cost); got != tt.want {
+				t.Errorf("NeedsRehash is %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownPolicy is returned by Policies.Authorize for a policy that wasn't
+// registered.
+var ErrUnknownPolicy = errors.New("unknown policy")
+
+// Resource is what a policy authorizes access to.
+type Resource interface {
+	OwnerID() string
+	Attribute(name string) (string, bool)
+}
+
+// Attributes is a Resource described by a set of attributes. The owner is the
+// "owner_id" attribute.
+type Attributes map[string]string
+
+// OwnerID implements the Resource interface.
+func (a Attributes) OwnerID() string {
+	return a["owner_id"]
+}
+
+// Attribute implements the Resource interface.
+func (a Attributes) Attribute(name string) (string, bool) {
+	v, exists := a[name]
+	return v, exists
+}
+
+// PolicyFunc reports whether the claims may access the resource.
+type PolicyFunc func(claims Claims, resource Resource) bool
+
+// OwnerOrAdmin is a PolicyFunc letting admins access any resource and other
+// users only the resources they own:
+//
+//	p := auth.NewPolicies()
+//	p.RegisterPolicy("document:edit", auth.OwnerOrAdmin)
+//
+//	if err := p.Authorize(r.Context(), "document:edit", auth.Attributes{"owner_id": doc.OwnerID}); err != nil {
+//		...
+//	}
+func OwnerOrAdmin(claims Claims, resource Resource) bool {
+	if claims.HasRole(RoleAdmin) {
+		return true
+	}
+	return claims.Subject != "" && claims.Subject == resource.OwnerID()
+}
+
+// Policies holds named authorization policies so the rules live in one
+// place rather than in each handler. It is safe for concurrent use.
+type Policies struct {
+	mu       sync.RWMutex
+	policies map[string]PolicyFunc
+}
+
+// NewPolicies constructs an empty set of policies.
+func NewPolicies() *Policies {
+	return &Policies{
+		policies: make(map[string]PolicyFunc),
+	}
+}
+
+// RegisterPolicy adds the policy under the name, replacing any policy already
+// registered under it.
+func (p *Policies) RegisterPolicy(name string, fn PolicyFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.policies[name] = fn
+}
+
+// Authorize evaluates the named policy for the Claims in the context, as
+// stored by Authenticate, and the resource. It returns ErrNoClaims when the
+// context has no Claims and ErrForbidden when the policy denies access.
+func (p *Policies) Authorize(ctx context.Context, name string, resource Resource) error {
+	p.mu.RLock()
+	fn, exists := p.policies[name]
+	p.mu.RUnlock()
+
+	if !exists {
+		return errors.Wrapf(ErrUnknownPolicy, "policy %q", name)
+	}
+
+	claims, ok := FromContext(ctx)
+	if !ok {
+		return ErrNoClaims
+	}
+
+	if !fn(claims, resource) {
+		return ErrForbidden
+	}
+
+	return nil
+}
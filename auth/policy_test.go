@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestPoliciesAuthorize(t *testing.T) {
+	p := NewPolicies()
+	p.RegisterPolicy("document:edit", OwnerOrAdmin)
+	p.RegisterPolicy("document:read", func(claims Claims, resource Resource) bool {
+		level, _ := resource.Attribute("level")
+		return level == "public" || claims.HasRole(RoleUser)
+	})
+
+	withClaims := func(subject string, roles ...string) context.Context {
+		return context.WithValue(context.Background(), Key, newClaims(subject, roles...))
+	}
+	doc := Attributes{"owner_id": "owner", "level": "private"}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		policy   string
+		resource Resource
+		err      error
+	}{
+		{name: "allowed", ctx: withClaims("reader", RoleUser), policy: "document:read", resource: doc},
+		{name: "denied", ctx: withClaims("reader"), policy: "document:read", resource: doc, err: ErrForbidden},
+		{name: "allowed by attribute", ctx: withClaims("reader"), policy: "document:read", resource: Attributes{"level": "public"}},
+		{name: "unknown policy", ctx: withClaims("owner"), policy: "document:delete", resource: doc, err: ErrUnknownPolicy},
+		{name: "no claims", ctx: context.Background(), policy: "document:edit", resource: doc, err: ErrNoClaims},
+		{name: "owner", ctx: withClaims("owner", RoleUser), policy: "document:edit", resource: doc},
+		{name: "admin", ctx: withClaims("admin", RoleAdmin), policy: "document:edit", resource: doc},
+		{name: "neither owner nor admin", ctx: withClaims("other", RoleUser), policy: "document:edit", resource: doc, err: ErrForbidden},
+		{name: "no owner", ctx: withClaims("", RoleUser), policy: "document:edit", resource: Attributes{}, err: ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Authorize(tt.ctx, tt.policy, tt.resource)
+			if tt.err == nil && err != nil {
+				t.Fatalf("access was denied: %v", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err is %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRegisterPolicyReplaces(t *testing.T) {
+	p := NewPolicies()
+	p.RegisterPolicy("any", func(Claims, Resource) bool { return false })
+	p.RegisterPolicy("any", func(Claims, Resource) bool { return true })
+
+	ctx := context.WithValue(context.Background(), Key, newClaims("user"))
+	if err := p.Authorize(ctx, "any", Attributes{}); err != nil {
+		t.Errorf("access was denied: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ClaimHeaders maps the name of a header to the function deriving its value
+// from the Claims, for PropagateClaims.
+type ClaimHeaders map[string]func(Claims) string
+
+// DefaultClaimHeaders returns the headers set by PropagateClaims when none
+// are given: X-User-Id holds the subject, X-User-Name the username and
+// X-User-Roles the roles separated by commas.
+func DefaultClaimHeaders() ClaimHeaders {
+	return ClaimHeaders{
+		"X-User-Id":    func(c Claims) string { return c.Subject },
+		"X-User-Name":  func(c Claims) string { return c.UserName },
+		"X-User-Roles": func(c Claims) string { return strings.Join(c.Roles, ",") },
+	}
+}
+
+// PropagateClaims returns middleware that sets the headers on the request
+// from the Claims in its context, so the next handler, like a reverse proxy
+// to internal services, passes them on. Every header is first removed from
+// the request, so clients can never supply their own, and headers with an
+// empty value are left unset. The headers are set on a clone of the request,
+// leaving the one of the caller untouched. A nil headers uses
+// DefaultClaimHeaders. It must run after Authenticate or OptionalAuth;
+// requests without Claims are passed on stripped of the headers.
+func PropagateClaims(headers ClaimHeaders) func(http.Handler) http.Handler {
+	if headers == nil {
+		headers = DefaultClaimHeaders()
+	}
+
+	m := func(next http.Handler) http.Handler {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			r = r.Clone(r.Context())
+
+			for name := range headers {
+				r.Header.Del(name)
+			}
+
+			if claims, ok := FromContext(r.Context()); ok {
+				for name, value := range headers {
+					if v := value(claims); v != "" {
+						r.Header.Set(name, v)
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(h)
+	}
+
+	return m
+}
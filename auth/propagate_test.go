@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPropagateClaims(t *testing.T) {
+	claims := newClaims("user", RoleUser, RoleAdmin)
+	claims.UserName = "jane"
+
+	tests := []struct {
+		name    string
+		headers ClaimHeaders
+		claims  *Claims
+		sent    map[string]string
+		want    map[string]string
+	}{
+		{
+			name:   "default headers",
+			claims: &claims,
+			want:   map[string]string{"X-User-Id": "user", "X-User-Name": "jane", "X-User-Roles": "USER,ADMIN"},
+		},
+		{
+			name:   "spoofed headers",
+			claims: &claims,
+			sent:   map[string]string{"X-User-Id": "admin", "X-User-Roles": "admin"},
+			want:   map[string]string{"X-User-Id": "user", "X-User-Name": "jane", "X-User-Roles": "USER,ADMIN"},
+		},
+		{
+			name: "no claims",
+			sent: map[string]string{"X-User-Id": "admin", "X-User-Name": "root"},
+			want: map[string]string{"X-User-Id": "", "X-User-Name": "", "X-User-Roles": ""},
+		},
+		{
+			name:    "empty value",
+			headers: ClaimHeaders{"X-Acr": func(c Claims) string { return c.ACR }},
+			claims:  &claims,
+			sent:    map[string]string{"X-Acr": "other"},
+			want:    map[string]string{"X-Acr": ""},
+		},
+		{
+			name:    "custom headers",
+			headers: ClaimHeaders{"X-Subject": func(c Claims) string { return c.Subject }},
+			claims:  &claims,
+			sent:    map[string]string{"X-User-Id": "admin"},
+			want:    map[string]string{"X-Subject": "user", "X-User-Id": "admin"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got http.Header
+			next := func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header
+			}
+			h := PropagateClaims(tt.headers)(http.HandlerFunc(next))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.sent {
+				r.Header.Set(name, value)
+			}
+			if tt.claims != nil {
+				r = r.WithContext(context.WithValue(r.Context(), Key, *tt.claims))
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			for name, want := range tt.want {
+				if v := got.Get(name); v != want {
+					t.Errorf("%s is %q, want %q", name, v, want)
+				}
+			}
+
+			// The request of the caller is left as it was sent.
+			if len(r.Header) != len(tt.sent) {
+				t.Errorf("request has %d headers, want %d", len(r.Header), len(tt.sent))
+			}
+			for name, value := range tt.sent {
+				if v := r.Header.Get(name); v != value {
+					t.Errorf("%s of the request is %q, want %q", name, v, value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// These are the values for Claims.TokenType. The access and refresh types
+// are set on a token pair, the others name common single purpose tokens.
+const (
+	TokenTypeAccess            = "access"
+	TokenTypeRefresh           = "refresh"
+	TokenTypeEmailVerification = "email_verification"
+	TokenTypePasswordReset     = "password_reset"
+	TokenTypeService           = "service"
+)
+
+// These are the lifetimes Refresh uses for the tokens it mints unless
+// configured with WithTokenPairTTL.
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 7 * 24 * time.Hour
+)
+
+// ErrInvalidTokenType is returned when a token is presented for a purpose
+// that doesn't match its type.
+var ErrInvalidTokenType = errors.New("invalid token type")
+
+// TokenPair is a short lived access token with the long lived refresh token
+// that can be exchanged for a new pair.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// WithTokenPairTTL sets the lifetimes of the access and refresh tokens minted
+// by Refresh.
+func WithTokenPairTTL(accessTTL, refreshTTL time.Duration) Option {
+	return func(a *Auth) {
+		a.accessTTL = accessTTL
+		a.refreshTTL = refreshTTL
+	}
+}
+
+// GenerateTokenPair generates an access token that expires after accessTTL
+// and a refresh token that expires after refreshTTL for the same claims.
+// When a FamilyStore is configured the refresh token starts a new family.
+func (a *Auth) GenerateTokenPair(claims Claims, accessTTL, refreshTTL time.Duration) (TokenPair, error) {
+	if a.families == nil {
+		claims.Family = ""
+		return a.generatePair(claims, "", a.clock(), accessTTL, refreshTTL)
+	}
+
+	family, err := newTokenID()
+	if err != nil {
+		return TokenPair{}, errors.Wrap(err, "generating family id")
+	}
+
+	refreshID, err := newTokenID()
+	if err != nil {
+		return TokenPair{}, errors.Wrap(err, "generating token id")
+	}
+
+	now := a.clock()
+	if err := a.families.Start(family, refreshID, now.Add(refreshTTL)); err != nil {
+		return TokenPair{}, errors.Wrap(err, "starting token family")
+	}
+
+	claims.Family = family
+	return a.generatePair(claims, refreshID, now, accessTTL, refreshTTL)
+}
+
+// ValidateTokenOfType is ValidateToken for tokens of the given purpose, such
+// as TokenTypePasswordReset. Tokens of any other type are rejected with
+// ErrInvalidTokenType, so a token minted for one purpose can't be used for
+// another.
+func (a *Auth) ValidateTokenOfType(tokenStr string, typ string) (Claims, error) {
+	claims, _, err := a.validateToken(context.Background(), tokenStr, typ)
+	return claims, err
+}
+
+// generatePair mints the token pair. The refresh token is assigned refreshID,
+// which is generated when empty.
+func (a *Auth) generatePair(claims Claims, refreshID string, now time.Time, accessTTL, refreshTTL time.Duration) (TokenPair, error) {
+	// Each token gets its own id so they can be revoked independently.
+	claims.Id = ""
+	claims.IssuedAt = now.Unix()
+
+	access := claims
+	access.TokenType = TokenTypeAccess
+	access.Family = ""
+	access.ExpiresAt = now.Add(accessTTL).Unix()
+
+	accessToken, err := a.GenerateToken(access)
+	if err != nil {
+		return TokenPair{}, errors.Wrap(err, "generating access token")
+	}
+
+	refresh := claims
+	refresh.Id = refreshID
+	refresh.TokenType = TokenTypeRefresh
+	refresh.ExpiresAt = now.Add(refreshTTL).Unix()
+
+	refreshToken, err := a.GenerateToken(refresh)
+	if err != nil {
+		return TokenPair{}, errors.Wrap(err, "generating refresh token")
+	}
+
+	tp := TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+
+	return tp, nil
+}
+
+// Refresh validates the refresh token and mints a new token pair for its
+// claims. Tokens that aren't refresh tokens are rejected with
+// ErrInvalidTokenType.
+//
+// When a FamilyStore is configured the presented token must be the latest
+// refresh token of its family. Presenting an older one means the token was
+// replayed, so the whole family is revoked and ErrRefreshTokenReuse is
+// returned.
+func (a *Auth) Refresh(refreshToken string) (TokenPair, error) {
+	claims, _, err := a.validate(context.Background(), refreshToken)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if claims.TokenType != TokenTypeRefresh {
+		return TokenPair{}, newValidationError(errors.Wrapf(ErrInvalidTokenType, "expected %s token", TokenTypeRefresh))
+	}
+
+	if a.families == nil {
+		return a.generatePair(claims, "", a.clock(), a.accessTTL, a.refreshTTL)
+	}
+
+	if claims.Family == "" {
+		return TokenPair{}, errors.New("refresh token has no family")
+	}
+
+	refreshID, err := newTokenID()
+	if err != nil {
+		return TokenPair{}, errors.Wrap(err, "generating token id")
+	}
+
+	now := a.clock()
+	if err := a.families.Rotate(claims.Family, claims.Id, refreshID, now.Add(a.refreshTTL)); err != nil {
+		if !errors.Is(err, ErrRefreshTokenReuse) {
+			return TokenPair{}, errors.Wrap(err, "rotating token family")
+		}
+
+		if err := a.families.RevokeFamily(claims.Family); err != nil {
+			return TokenPair{}, errors.Wrap(err, "revoking token family")
+		}
+		return TokenPair{}, ErrRefreshTokenReuse
+	}
+
+	return a.generatePair(claims, refreshID, now, a.accessTTL, a.refreshTTL)
+}
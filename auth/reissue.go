@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AllowExpiredReissue lets Reissue replace tokens that have already expired.
+// The signature and every other claim are still verified.
+func AllowExpiredReissue() Option {
+	return func(a *Auth) {
+		a.reissueExpired = true
+	}
+}
+
+// Reissue validates the token and mints a replacement carrying the new roles
+// that expires ttl from now, for when the privileges of a user change. The
+// replacement gets a new jti and keeps the other claims, like the subject and
+// username. When a Revoker is configured the old token is revoked, so the
+// stale roles stop working immediately. Only access tokens are reissued.
+// Expired tokens are rejected unless the Auth was configured with
+// AllowExpiredReissue.
+func (a *Auth) Reissue(tokenStr string, newRoles []string, ttl time.Duration) (string, error) {
+	return a.ReissueWithScopes(tokenStr, newRoles, nil, ttl)
+}
+
+// ReissueWithScopes is Reissue but also replaces the scopes of the token.
+// Nil scopes keep the scopes of the token.
+func (a *Auth) ReissueWithScopes(tokenStr string, newRoles []string, scopes []string, ttl time.Duration) (string, error) {
+	var claims Claims
+	var err error
+	if a.reissueExpired {
+		claims, err = a.ClaimsFromExpired(tokenStr)
+		if err == nil && claims.TokenType != "" && claims.TokenType != TokenTypeAccess {
+			err = newValidationError(errors.Wrapf(ErrInvalidTokenType, "expected %s token", TokenTypeAccess))
+		}
+	} else {
+		claims, err = a.ValidateToken(tokenStr)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	next := claims
+	next.Id = ""
+	next.NotBefore = 0
+	next.Roles = append([]string(nil), newRoles...)
+	if scopes != nil {
+		next.Scope = strings.Join(scopes, " ")
+	}
+
+	str, err := a.GenerateTokenWithTTL(next, ttl)
+	if err != nil {
+		return "", errors.Wrap(err, "reissuing token")
+	}
+
+	if a.revoker != nil && claims.Id != "" {
+		exp := time.Unix(maxUnixSeconds, 0)
+		if claims.ExpiresAt != 0 {
+			exp = time.Unix(claims.ExpiresAt, 0)
+		}
+		if err := a.revoker.Revoke(claims.Id, exp); err != nil {
+			return "", errors.Wrap(err, "revoking token")
+		}
+	}
+
+	return str, nil
+}
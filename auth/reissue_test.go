@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestReissue(t *testing.T) {
+	refresh := newClaims("user", "USER")
+	refresh.TokenType = TokenTypeRefresh
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		claims  Claims
+		advance time.Duration
+		err     error
+	}{
+		{name: "not expired", claims: newClaims("user", "USER")},
+		{name: "expired", claims: newClaims("user", "USER"), advance: 2 * time.Hour, err: ErrTokenExpired},
+		{name: "expired allowed", opts: []Option{AllowExpiredReissue()}, claims: newClaims("user", "USER"), advance: 2 * time.Hour},
+		{name: "refresh token", claims: refresh, err: ErrInvalidTokenType},
+		{name: "expired refresh token allowed", opts: []Option{AllowExpiredReissue()}, claims: refresh, advance: 2 * time.Hour, err: ErrInvalidTokenType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, append([]Option{WithClock(clock.Now)}, tt.opts...)...)
+
+			tokenStr := mustGenerate(t, a, tt.claims)
+			old, err := ParseUnverified(tokenStr)
+			if err != nil {
+				t.Fatalf("parsing token: %v", err)
+			}
+			clock.Advance(tt.advance)
+
+			str, err := a.Reissue(tokenStr, []string{"USER", "ADMIN"}, 30*time.Minute)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err is %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reissuing token: %v", err)
+			}
+
+			got, err := a.ValidateToken(str)
+			if err != nil {
+				t.Fatalf("validating reissued token: %v", err)
+			}
+			if got.Subject != "user" {
+				t.Errorf("subject is %q, want %q", got.Subject, "user")
+			}
+			if want := []string{"USER", "ADMIN"}; !reflect.DeepEqual(got.Roles, want) {
+				t.Errorf("roles are %v, want %v", got.Roles, want)
+			}
+			if got.Id == "" || got.Id == old.Id {
+				t.Errorf("token id is %q, want a new one", got.Id)
+			}
+			if want := clock.Now().Add(30 * time.Minute).Unix(); got.ExpiresAt != want {
+				t.Errorf("exp is %v, want %v", time.Unix(got.ExpiresAt, 0).UTC(), time.Unix(want, 0).UTC())
+			}
+		})
+	}
+}
+
+func TestReissueWithScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		want   string
+	}{
+		{name: "narrowed", scopes: []string{"read"}, want: "read"},
+		{name: "kept", want: "read write"},
+		{name: "cleared", scopes: []string{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t)
+
+			claims := newClaims("user", "USER")
+			claims.Scope = "read write"
+			tokenStr := mustGenerate(t, a, claims)
+
+			str, err := a.ReissueWithScopes(tokenStr, []string{"USER"}, tt.scopes, time.Hour)
+			if err != nil {
+				t.Fatalf("reissuing token: %v", err)
+			}
+
+			got, err := a.ValidateToken(str)
+			if err != nil {
+				t.Fatalf("validating reissued token: %v", err)
+			}
+			if got.Scope != tt.want {
+				t.Errorf("scope is %q, want %q", got.Scope, tt.want)
+			}
+		})
+	}
+}
+
+func TestReissueRevokesOldToken(t *testing.T) {
+	a := newTestAuth(t, WithRevoker(NewMemoryRevoker()))
+
+	tokenStr := mustGenerate(t, a, newClaims("user", "ADMIN"))
+	if _, err := a.Reissue(tokenStr, []string{"USER"}, time.Hour); err != nil {
+		t.Fatalf("reissuing token: %v", err)
+	}
+
+	if _, err := a.ValidateToken(tokenStr); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("err is %v, want %v", err, ErrTokenRevoked)
+	}
+}
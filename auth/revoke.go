@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTokenRevoked is returned by ValidateToken when the token's id (jti) has
+// been revoked.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// Revoker tracks tokens that have been revoked before their expiration. The
+// token id (jti) is the key. The exp value is the expiration of the token so
+// implementations can forget the entry once the token would be invalid anyway.
+//
+// To back a Revoker with Redis, store the jti with SET using an expiration of
+// time.Until(exp) and implement IsRevoked with EXISTS. Redis then performs the
+// cleanup of expired entries for you.
+type Revoker interface {
+	IsRevoked(jti string) (bool, error)
+	Revoke(jti string, exp time.Time) error
+}
+
+// WithRevoker configures ValidateToken to reject tokens whose id has been
+// revoked. The check happens after the signature is verified. Tokens without
+// an id can't be revoked.
+func WithRevoker(r Revoker) Option {
+	return func(a *Auth) {
+		a.revoker = r
+	}
+}
+
+// ErrNoRevoker is returned by Auth.Revoke when no Revoker is configured.
+var ErrNoRevoker = errors.New("revoker not configured")
+
+// Revoke revokes the token, for example on logout, so ValidateToken rejects
+// it from now on. The signature of the token is verified first so nobody can
+// revoke tokens on behalf of others by guessing ids. The token is revoked
+// until its expiration, after which the Revoker can forget it. Revoking an
+// expired token does nothing.
+func (a *Auth) Revoke(tokenStr string) error {
+	if a.revoker == nil {
+		return ErrNoRevoker
+	}
+
+	claims, _, err := a.parse(context.Background(), tokenStr)
+	if err != nil {
+		return newValidationError(err)
+	}
+
+	if claims.Id == "" {
+		return errors.New("token has no id (jti) to revoke")
+	}
+
+	// Tokens without an expiration have to be remembered for good.
+	exp := time.Unix(maxUnixSeconds, 0)
+	if claims.ExpiresAt != 0 {
+		exp = time.Unix(claims.ExpiresAt, 0)
+		if a.clock().After(exp.Add(a.leeway)) {
+			return nil
+		}
+	}
+
+	if err := a.revoker.Revoke(claims.Id, exp); err != nil {
+		return errors.Wrap(err, "revoking token")
+	}
+
+	return nil
+}
+
+// checkRevoked returns ErrTokenRevoked when a Revoker is configured and the
+// token's id has been revoked.
+func (a *Auth) checkRevoked(claims Claims) error {
+	if a.revoker == nil || claims.Id == "" {
+		return nil
+	}
+
+	revoked, err := a.revoker.IsRevoked(claims.Id)
+	if err != nil {
+		return errors.Wrap(err, "checking revocation")
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// cleanupInterval is how often the MemoryRevoker purges expired entries.
+const cleanupInterval = time.Minute
+
+// MemoryRevoker is an in-memory Revoker. Entries are removed once the
+// token they represent has expired, according to the clock of the Auth it is
+// attached to. It is safe for concurrent use.
+type MemoryRevoker struct {
+	mu          sync.Mutex
+	revoked     map[string]time.Time
+	clock       func() time.Time
+	lastCleanup time.Time
+}
+
+// NewMemoryRevoker constructs an empty MemoryRevoker.
+func NewMemoryRevoker() *MemoryRevoker {
+	return &MemoryRevoker{
+		revoked:     make(map[string]time.Time),
+		clock:       time.Now,
+		lastCleanup: time.Now(),
+	}
+}
+
+// useClock implements the clockUser interface.
+func (m *MemoryRevoker) useClock(clock func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = clock
+	m.lastCleanup = clock()
+}
+
+// IsRevoked implements the Revoker interface.
+func (m *MemoryRevoker) IsRevoked(jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	exp, exists := m.revoked[jti]
+	if !exists {
+		return false, nil
+	}
+
+	// An expired entry no longer needs to be tracked.
+	if m.clock().After(exp) {
+		delete(m.revoked, jti)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Revoke implements the Revoker interface.
+func (m *MemoryRevoker) Revoke(jti string, exp time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revoked[jti] = exp
+	m.cleanup()
+
+	return nil
+}
+
+// cleanup removes the expired entries. It runs at most once per
+// cleanupInterval and must be called with the mutex held.
+func (m *MemoryRevoker) cleanup() {
+	now := m.clock()
+	if now.Sub(m.lastCleanup) < cleanupInterval {
+		return
+	}
+
+	for jti, exp := range m.revoked {
+		if now.After(exp) {
+			delete(m.revoked, jti)
+		}
+	}
+	m.lastCleanup = now
+}
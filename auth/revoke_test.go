@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestRevoke(t *testing.T) {
+	tests := []struct {
+		name    string
+		revoke  bool
+		advance time.Duration
+		err     error
+	}{
+		{name: "not revoked"},
+		{name: "revoked", revoke: true, err: ErrTokenRevoked},
+		{name: "revoked until expiry", revoke: true, advance: 30 * time.Minute, err: ErrTokenRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, WithClock(clock.Now), WithRevoker(NewMemoryRevoker()))
+
+			tokenStr := mustGenerate(t, a, newClaims("user"))
+			other := mustGenerate(t, a, newClaims("user"))
+
+			if tt.revoke {
+				if err := a.Revoke(tokenStr); err != nil {
+					t.Fatalf("revoking token: %v", err)
+				}
+			}
+			clock.Advance(tt.advance)
+
+			_, err := a.ValidateToken(tokenStr)
+			if tt.err == nil && err != nil {
+				t.Errorf("token was rejected: %v", err)
+			}
+			if tt.err != nil && (!errors.Is(err, tt.err) || ReasonOf(err) != ReasonRevoked) {
+				t.Errorf("err is %v, want %v", err, tt.err)
+			}
+
+			if _, err := a.ValidateToken(other); err != nil {
+				t.Errorf("other token was rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestRevokeWithoutRevoker(t *testing.T) {
+	a := newTestAuth(t)
+
+	if err := a.Revoke(mustGenerate(t, a, newClaims("user"))); !errors.Is(err, ErrNoRevoker) {
+		t.Errorf("err is %v, want %v", err, ErrNoRevoker)
+	}
+}
+
+func TestMemoryRevokerUsesAuthClock(t *testing.T) {
+	clock := newTestClock()
+	r := NewMemoryRevoker()
+	newTestAuth(t, WithClock(clock.Now), WithRevoker(r))
+
+	// The entry expires with the clock of the Auth, not the wall clock. The
+	// steps advance the clock one after the other.
+	exp := testNow.Add(time.Hour)
+	if err := r.Revoke("jti", exp); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		revoked bool
+	}{
+		{name: "before expiry", advance: 0, revoked: true},
+		{name: "at expiry", advance: time.Hour, revoked: true},
+		{name: "after expiry", advance: time.Second, revoked: false},
+	}
+
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+
+		revoked, err := r.IsRevoked("jti")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if revoked != tt.revoked {
+			t.Errorf("%s: revoked is %v, want %v", tt.name, revoked, tt.revoked)
+		}
+	}
+}
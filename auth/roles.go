@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+)
+
+// RoleComparator reports whether a role held by the claims matches a role
+// being asked for.
+//
+// Claims have no access to the configuration of an Auth, so Claims.Authorized
+// and Claims.HasAllRoles always compare exactly. The middleware passes the
+// comparator configured on the Auth to AuthorizedWith instead. Handlers that
+// check roles by hand must do the same to get consistent results.
+type RoleComparator func(has, want string) bool
+
+// ExactRoleMatch is the default RoleComparator. Roles must be identical.
+func ExactRoleMatch(has, want string) bool {
+	return has == want
+}
+
+// FoldRoleMatch is a RoleComparator that ignores case, so "Admin" matches
+// RoleAdmin.
+func FoldRoleMatch(has, want string) bool {
+	return strings.EqualFold(has, want)
+}
+
+// WithCaseInsensitiveRoles makes the middleware compare roles using
+// FoldRoleMatch. This tolerates identity providers that don't emit roles in
+// the expected case, at the cost of treating roles that only differ by case
+// as the same role.
+func WithCaseInsensitiveRoles() Option {
+	return func(a *Auth) {
+		a.roleMatch = FoldRoleMatch
+	}
+}
+
+// AuthorizedWith returns true if the claims has at least one of the provided
+// roles according to the comparator.
+func (c Claims) AuthorizedWith(match RoleComparator, roles ...string) bool {
+	for _, has := range c.Roles {
+		for _, want := range roles {
+			if match(has, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AuthorizedConstantTime returns the same result as Authorized, but compares
+// every role of the claims with every provided role, in time depending only
+// on the number of roles. Roles are hashed first so their lengths don't
+// matter either.
+//
+// The threat is an attacker measuring response times to learn which role of
+// a check matched, or how much of a role name they guessed. Role names are
+// rarely secret and the difference is nanoseconds, so this only matters when
+// roles encode secret information and attackers can time many requests
+// precisely. Authorized is fine otherwise.
+func (c Claims) AuthorizedConstantTime(roles ...string) bool {
+	wants := make([][sha256.Size]byte, len(roles))
+	for i, want := range roles {
+		wants[i] = sha256.Sum256([]byte(want))
+	}
+
+	var match int
+	for _, has := range c.Roles {
+		h := sha256.Sum256([]byte(has))
+		for i := range wants {
+			match |= subtle.ConstantTimeCompare(h[:], wants[i][:])
+		}
+	}
+	return match == 1
+}
+
+// HasAllRolesWith returns true if the claims has every one of the provided
+// roles according to the comparator. It returns false when no roles are
+// provided.
+func (c Claims) HasAllRolesWith(match RoleComparator, roles ...string) bool {
+	if len(roles) == 0 {
+		return false
+	}
+
+	for _, want := range roles {
+		if !c.AuthorizedWith(match, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithRoleHierarchy configures roles that imply other roles. For example,
+// ADMIN implying MANAGER and MANAGER implying USER lets a token holding only
+// ADMIN pass a check for USER. Implications are followed transitively and
+// cycles in the hierarchy are tolerated. Only Auth.Authorized and the
+// middleware use the hierarchy, Claims.Authorized stays literal.
+func WithRoleHierarchy(hierarchy map[string][]string) Option {
+	return func(a *Auth) {
+		h := make(map[string][]string, len(hierarchy))
+		for role, implies := range hierarchy {
+			h[role] = append([]string(nil), implies...)
+		}
+		a.hierarchy = h
+	}
+}
+
+// Authorized returns true if the claims has at least one of the provided
+// roles, after expanding the roles of the claims through the configured role
+// hierarchy. Roles are compared using the configured RoleComparator.
+func (a *Auth) Authorized(claims Claims, roles ...string) bool {
+	expanded := Claims{Roles: a.expandRoles(claims.Roles)}
+	return expanded.AuthorizedWith(a.roleMatch, roles...)
+}
+
+// expandRoles returns the roles along with every role they imply. The
+// visited set guards against cycles in the hierarchy.
+func (a *Auth) expandRoles(roles []string) []string {
+	if len(a.hierarchy) == 0 {
+		return roles
+	}
+
+	visited := make(map[string]bool)
+	queue := append([]string(nil), roles...)
+	var expanded []string
+
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+
+		if visited[role] {
+			continue
+		}
+		visited[role] = true
+		expanded = append(expanded, role)
+
+		for parent, implies := range a.hierarchy {
+			if a.roleMatch(role, parent) {
+				queue = append(queue, implies...)
+			}
+		}
+	}
+
+	return expanded
+}
+
+// Authorize validates the token and checks its claims have at least one of
+// the roles, as Authorized does. No roles means any valid token is allowed.
+// Invalid tokens fail with the *ValidationError of ValidateToken, and valid
+// tokens lacking the roles fail with ErrForbidden, so callers can tell
+// unauthorized from forbidden.
+func (a *Auth) Authorize(tokenStr string, roles ...string) (Claims, error) {
+	claims, err := a.ValidateToken(tokenStr)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if len(roles) > 0 && !a.Authorized(claims, roles...) {
+		return Claims{}, ErrForbidden
+	}
+
+	return claims, nil
+}
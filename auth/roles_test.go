@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAuthorize(t *testing.T) {
+	a := newTestAuth(t, WithRoleHierarchy(map[string][]string{RoleAdmin: {RoleUser}}))
+
+	user := mustGenerate(t, a, newClaims("user", RoleUser))
+	admin := mustGenerate(t, a, newClaims("admin", RoleAdmin))
+
+	tests := []struct {
+		name     string
+		tokenStr string
+		roles    []string
+		subject  string
+		err      error
+	}{
+		{name: "no roles", tokenStr: user, subject: "user"},
+		{name: "has role", tokenStr: user, roles: []string{RoleUser}, subject: "user"},
+		{name: "one of", tokenStr: user, roles: []string{RoleAdmin, RoleUser}, subject: "user"},
+		{name: "implied role", tokenStr: admin, roles: []string{RoleUser}, subject: "admin"},
+		{name: "lacks role", tokenStr: user, roles: []string{RoleAdmin}, err: ErrForbidden},
+		{name: "invalid token", tokenStr: "invalid", roles: []string{RoleUser}, err: ErrTokenMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := a.Authorize(tt.tokenStr, tt.roles...)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error is %v, want %v", err, tt.err)
+				}
+				if claims.Subject != "" {
+					t.Errorf("claims returned with the error: %+v", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authorizing: %v", err)
+			}
+			if claims.Subject != tt.subject {
+				t.Errorf("subject is %q, want %q", claims.Subject, tt.subject)
+			}
+		})
+	}
+
+	// Callers tell unauthorized from forbidden by the error type.
+	var ve *ValidationError
+	if _, err := a.Authorize("invalid"); !errors.As(err, &ve) {
+		t.Errorf("invalid token error %v isn't a *ValidationError", err)
+	}
+	if _, err := a.Authorize(user, RoleAdmin); errors.As(err, &ve) {
+		t.Errorf("forbidden error %v is a *ValidationError", err)
+	}
+}
+
+func TestAuthorizedConstantTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		has     []string
+		roles   []string
+		allowed bool
+	}{
+		{name: "has role", has: []string{RoleUser}, roles: []string{RoleUser}, allowed: true},
+		{name: "one of", has: []string{RoleUser}, roles: []string{RoleAdmin, RoleUser}, allowed: true},
+		{name: "several matches", has: []string{RoleUser, RoleAdmin}, roles: []string{RoleAdmin, RoleUser}, allowed: true},
+		{name: "lacks role", has: []string{RoleUser}, roles: []string{RoleAdmin}},
+		{name: "prefix", has: []string{"ADM"}, roles: []string{RoleAdmin}},
+		{name: "other case", has: []string{"admin"}, roles: []string{RoleAdmin}},
+		{name: "no roles held", roles: []string{RoleUser}},
+		{name: "no roles asked", has: []string{RoleUser}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Claims{Roles: tt.has}
+
+			if got := c.AuthorizedConstantTime(tt.roles...); got != tt.allowed {
+				t.Errorf("AuthorizedConstantTime is %v, want %v", got, tt.allowed)
+			}
+			if got := c.Authorized(tt.roles...); got != tt.allowed {
+				t.Errorf("Authorized is %v, want %v", got, tt.allowed)
+			}
+		})
+	}
+}
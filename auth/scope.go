@@ -0,0 +1,44 @@
+package auth
+
+import "strings"
+
+// Scopes returns the space-delimited scopes of the claims as a slice.
+func (c Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScope returns true if the claims has the provided scope.
+func (c Claims) HasScope(scope string) bool {
+	for _, has := range c.Scopes() {
+		if has == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyScope returns true if the claims has at least one of the provided
+// scopes.
+func (c Claims) HasAnyScope(scopes ...string) bool {
+	for _, want := range scopes {
+		if c.HasScope(want) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAllScopes returns true if the claims has every one of the provided
+// scopes. It returns false when no scopes are provided.
+func (c Claims) HasAllScopes(scopes ...string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+
+	for _, want := range scopes {
+		if !c.HasScope(want) {
+			return false
+		}
+	}
+	return true
+}
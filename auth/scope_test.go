@@ -0,0 +1,60 @@
+package auth
+
+import "testing"
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope string
+		want  string
+		has   bool
+	}{
+		{name: "single", scope: "read", want: "read", has: true},
+		{name: "first", scope: "read write", want: "read", has: true},
+		{name: "last", scope: "read write", want: "write", has: true},
+		{name: "extra whitespace", scope: "  read\twrite  ", want: "write", has: true},
+		{name: "missing", scope: "read write", want: "delete"},
+		{name: "prefix", scope: "read:users", want: "read"},
+		{name: "substring", scope: "unread", want: "read"},
+		{name: "other case", scope: "READ", want: "read"},
+		{name: "no scopes", want: "read"},
+		{name: "empty scope", scope: "read", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Claims{Scope: tt.scope}
+
+			if got := c.HasScope(tt.want); got != tt.has {
+				t.Errorf("HasScope(%q) is %v, want %v", tt.want, got, tt.has)
+			}
+		})
+	}
+}
+
+func TestHasAnyAndAllScopes(t *testing.T) {
+	c := Claims{Scope: "read write"}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		any    bool
+		all    bool
+	}{
+		{name: "all held", scopes: []string{"read", "write"}, any: true, all: true},
+		{name: "some held", scopes: []string{"read", "delete"}, any: true},
+		{name: "none held", scopes: []string{"delete"}},
+		{name: "no scopes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.HasAnyScope(tt.scopes...); got != tt.any {
+				t.Errorf("HasAnyScope is %v, want %v", got, tt.any)
+			}
+			if got := c.HasAllScopes(tt.scopes...); got != tt.all {
+				t.Errorf("HasAllScopes is %v, want %v", got, tt.all)
+			}
+		})
+	}
+}
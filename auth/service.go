@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrServiceTokenID is returned for service tokens without an expiration
+// that don't carry an id (jti), since they could never be revoked.
+var ErrServiceTokenID = errors.New("service token has no id")
+
+// ServiceTokenRecorder is implemented by a MetricsCollector that also counts
+// the service tokens accepted by an Auth configured with WithServiceTokens.
+type ServiceTokenRecorder interface {
+	// ServiceTokenAccepted is called each time a service token is
+	// validated successfully.
+	ServiceTokenAccepted()
+}
+
+// WithServiceTokens accepts tokens of type TokenTypeService without an
+// expiration, for long-lived tokens of internal services, and rejects every
+// other token lacking one with ErrNoExpiry. Service tokens are accepted
+// wherever access tokens are and each one validated is reported to a
+// MetricsCollector implementing ServiceTokenRecorder.
+//
+// A service token without an expiration stays valid until it is revoked or
+// the signing key is rotated, so anyone who obtains one keeps its access
+// indefinitely. To keep revocation possible they must carry an id (jti),
+// which GenerateToken always sets, and ErrServiceTokenID is returned for
+// those that don't. Prefer short lived tokens wherever the service can
+// refresh them, and grant service tokens as few roles as possible.
+func WithServiceTokens() Option {
+	return func(a *Auth) {
+		a.serviceTokens = true
+	}
+}
+
+// isServiceToken returns true if the claims are accepted as a service token.
+func (a *Auth) isServiceToken(claims Claims) bool {
+	return a.serviceTokens && claims.TokenType == TokenTypeService
+}
+
+// checkNoExpiry validates claims without an expiration.
+func (a *Auth) checkNoExpiry(claims Claims) error {
+	if !a.serviceTokens {
+		return nil
+	}
+
+	if claims.TokenType != TokenTypeService {
+		return ErrNoExpiry
+	}
+
+	if claims.Id == "" {
+		return ErrServiceTokenID
+	}
+
+	return nil
+}
+
+// recordServiceToken reports an accepted service token to the metrics.
+func (a *Auth) recordServiceToken() {
+	if r, ok := a.metrics.(ServiceTokenRecorder); ok {
+		r.ServiceTokenAccepted()
+	}
+}
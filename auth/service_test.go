@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// serviceMetrics is a MetricsCollector counting the accepted service tokens.
+type serviceMetrics struct {
+	noopMetrics
+	accepted int
+}
+
+func (m *serviceMetrics) ServiceTokenAccepted() {
+	m.accepted++
+}
+
+func TestWithServiceTokens(t *testing.T) {
+	iat := testNow.Unix()
+
+	tests := []struct {
+		name     string
+		service  bool
+		payload  string
+		err      error
+		accepted int
+	}{
+		{
+			name:     "service token",
+			service:  true,
+			payload:  fmt.Sprintf(`{"sub":"billing","typ":"service","jti":"id","iat":%d}`, iat),
+			accepted: 1,
+		},
+		{
+			name:     "expiring service token",
+			service:  true,
+			payload:  fmt.Sprintf(`{"sub":"billing","typ":"service","jti":"id","iat":%d,"exp":%d}`, iat, testNow.Add(time.Hour).Unix()),
+			accepted: 1,
+		},
+		{
+			name:    "service token without id",
+			service: true,
+			payload: fmt.Sprintf(`{"sub":"billing","typ":"service","iat":%d}`, iat),
+			err:     ErrServiceTokenID,
+		},
+		{
+			name:    "user token without expiration",
+			service: true,
+			payload: fmt.Sprintf(`{"sub":"user","jti":"id","iat":%d}`, iat),
+			err:     ErrNoExpiry,
+		},
+		{
+			name:    "expiring user token",
+			service: true,
+			payload: fmt.Sprintf(`{"sub":"user","jti":"id","iat":%d,"exp":%d}`, iat, testNow.Add(time.Hour).Unix()),
+		},
+		{
+			name:    "service tokens not accepted",
+			payload: fmt.Sprintf(`{"sub":"billing","typ":"service","jti":"id","iat":%d}`, iat),
+			err:     ErrInvalidTokenType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := &serviceMetrics{}
+			opts := []Option{WithMetrics(metrics), WithMaxTokenLifetime(24 * time.Hour)}
+			if tt.service {
+				opts = append(opts, WithServiceTokens())
+			}
+			a := newTestAuth(t, opts...)
+
+			_, err := a.ValidateToken(signPayload(t, tt.payload))
+			if tt.err == nil && err != nil {
+				t.Fatalf("token was rejected: %v", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err is %v, want %v", err, tt.err)
+			}
+			if metrics.accepted != tt.accepted {
+				t.Errorf("%d service tokens accepted, want %d", metrics.accepted, tt.accepted)
+			}
+		})
+	}
+}
+
+func TestGenerateServiceToken(t *testing.T) {
+	a := newTestAuth(t, WithServiceTokens())
+
+	claims := newClaims("billing")
+	claims.TokenType = TokenTypeService
+	claims.ExpiresAt = 0
+	tokenStr := mustGenerate(t, a, claims)
+
+	got, err := a.ValidateToken(tokenStr)
+	if err != nil {
+		t.Fatalf("validating token: %v", err)
+	}
+	if got.Id == "" {
+		t.Error("service token has no id")
+	}
+
+	// The expiration is still required of the other tokens.
+	if _, err := a.GenerateToken(newClaims("user")); err != nil {
+		t.Errorf("generating user token: %v", err)
+	}
+	user := newClaims("user")
+	user.ExpiresAt = 0
+	if _, err := a.GenerateToken(user); !errors.Is(err, ErrNoExpiry) {
+		t.Errorf("err is %v, want %v", err, ErrNoExpiry)
+	}
+}
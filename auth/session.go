@@ -0,0 +1,392 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownSession is returned when a refresh token isn't in the
+// RefreshStore, because it expired, was revoked or was already used.
+var ErrUnknownSession = errors.New("unknown session")
+
+// RefreshStore persists the sessions behind opaque refresh tokens. The key is
+// the SHA-256 hash of the refresh token, never the token itself. Take must
+// get and remove the session atomically so each refresh token can only be
+// used once, even by concurrent requests.
+type RefreshStore interface {
+	Put(hash string, claims Claims, exp time.Time) error
+	Take(hash string) (Claims, error)
+	Delete(hash string) error
+}
+
+// Session describes a session started by GenerateDeviceSession.
+type Session struct {
+	ID         string    `json:"id"`
+	Subject    string    `json:"sub"`
+	DeviceName string    `json:"device_name"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// SessionStore records the sessions of users so they can be listed and
+// revoked. Create stores the session, replacing any session with the same
+// id, which is how the expiration is extended when the session is
+// refreshed. Get returns ErrUnknownSession for sessions that were revoked or
+// have expired.
+type SessionStore interface {
+	Create(s Session) error
+	Get(sessionID string) (Session, error)
+	ListByUser(subject string) ([]Session, error)
+	Revoke(sessionID string) error
+}
+
+// WithSessionStore configures the store recording the sessions started by
+// GenerateDeviceSession. It requires a RefreshStore.
+func WithSessionStore(s SessionStore) Option {
+	return func(a *Auth) {
+		a.sessionStore = s
+	}
+}
+
+// WithRefreshStore configures the store backing the sessions of
+// GenerateSession.
+func WithRefreshStore(s RefreshStore) Option {
+	return func(a *Auth) {
+		a.sessions = s
+	}
+}
+
+// GenerateSession starts a session for the claims. It returns a JWT access
+// token that expires after accessTTL and an opaque refresh token, stored in
+// the RefreshStore, that expires after refreshTTL. Unlike a JWT refresh token
+// the session can be revoked by removing it from the store.
+func (a *Auth) GenerateSession(claims Claims, accessTTL, refreshTTL time.Duration) (TokenPair, error) {
+	return a.GenerateDeviceSession(claims, "", accessTTL, refreshTTL)
+}
+
+// GenerateDeviceSession is GenerateSession for a session on the named device,
+// such as "Firefox on Linux". When a SessionStore is configured the session
+// is recorded there so the user can list and revoke their sessions. The
+// session id is carried in the sid claim of the access tokens.
+func (a *Auth) GenerateDeviceSession(claims Claims, deviceName string, accessTTL, refreshTTL time.Duration) (TokenPair, error) {
+	if a.sessions == nil {
+		return TokenPair{}, errors.New("refresh store not configured")
+	}
+
+	claims.SessionID = ""
+	if a.sessionStore != nil {
+		if claims.Subject == "" {
+			return TokenPair{}, errors.New("sessions require a subject")
+		}
+
+		id, err := newTokenID()
+		if err != nil {
+			return TokenPair{}, errors.Wrap(err, "generating session id")
+		}
+
+		now := a.clock()
+		s := Session{
+			ID:         id,
+			Subject:    claims.Subject,
+			DeviceName: deviceName,
+			CreatedAt:  now,
+			ExpiresAt:  now.Add(refreshTTL),
+		}
+		if err := a.sessionStore.Create(s); err != nil {
+			return TokenPair{}, errors.Wrap(err, "recording session")
+		}
+		claims.SessionID = id
+	}
+
+	return a.issueSession(claims, accessTTL, refreshTTL)
+}
+
+// RefreshSession exchanges the opaque refresh token for a new access token and
+// a new refresh token. The presented refresh token can't be used again. The
+// tokens get the lifetimes configured with WithTokenPairTTL.
+func (a *Auth) RefreshSession(refreshToken string) (TokenPair, error) {
+	if a.sessions == nil {
+		return TokenPair{}, errors.New("refresh store not configured")
+	}
+
+	claims, err := a.sessions.Take(hashToken(refreshToken))
+	if err != nil {
+		return TokenPair{}, newValidationError(errors.Wrap(err, "looking up session"))
+	}
+
+	if err := a.checkPolicies(claims); err != nil {
+		return TokenPair{}, newValidationError(err)
+	}
+
+	if a.sessionStore != nil && claims.SessionID != "" {
+		s, err := a.sessionStore.Get(claims.SessionID)
+		if err != nil {
+			return TokenPair{}, newValidationError(errors.Wrap(err, "looking up session"))
+		}
+
+		s.ExpiresAt = a.clock().Add(a.refreshTTL)
+		if err := a.sessionStore.Create(s); err != nil {
+			return TokenPair{}, errors.Wrap(err, "recording session")
+		}
+	}
+
+	return a.issueSession(claims, a.accessTTL, a.refreshTTL)
+}
+
+// RevokeRefreshToken ends the session of the refresh token. Access tokens
+// already issued for it stay valid until they expire.
+func (a *Auth) RevokeRefreshToken(refreshToken string) error {
+	if a.sessions == nil {
+		return errors.New("refresh store not configured")
+	}
+
+	if err := a.sessions.Delete(hashToken(refreshToken)); err != nil {
+		return errors.Wrap(err, "deleting session")
+	}
+
+	return nil
+}
+
+// issueSession stores a new refresh token for the claims and mints the access
+// token.
+func (a *Auth) issueSession(claims Claims, accessTTL, refreshTTL time.Duration) (TokenPair, error) {
+	refreshToken, err := newOpaqueToken()
+	if err != nil {
+		return TokenPair{}, errors.Wrap(err, "generating refresh token")
+	}
+
+	// The session keeps the claims the access tokens are minted from, without
+	// the registered claims of any one of them.
+	claims.Id = ""
+	claims.IssuedAt = 0
+	claims.NotBefore = 0
+	claims.ExpiresAt = 0
+	claims.TokenType = ""
+
+	now := a.clock()
+	if err := a.sessions.Put(hashToken(refreshToken), claims, now.Add(refreshTTL)); err != nil {
+		return TokenPair{}, errors.Wrap(err, "storing session")
+	}
+
+	access := claims
+	access.TokenType = TokenTypeAccess
+	access.IssuedAt = now.Unix()
+	access.ExpiresAt = now.Add(accessTTL).Unix()
+
+	accessToken, err := a.GenerateToken(access)
+	if err != nil {
+		return TokenPair{}, errors.Wrap(err, "generating access token")
+	}
+
+	tp := TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+
+	return tp, nil
+}
+
+// ListSessions returns the active sessions of the subject, such as the
+// devices the user is logged in on.
+func (a *Auth) ListSessions(subject string) ([]Session, error) {
+	if a.sessionStore == nil {
+		return nil, errors.New("session store not configured")
+	}
+
+	sessions, err := a.sessionStore.ListByUser(subject)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing sessions")
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession ends the session with the id, as returned by ListSessions.
+// Its refresh token stops working immediately. Access tokens already issued
+// for it stay valid until they expire.
+func (a *Auth) RevokeSession(sessionID string) error {
+	if a.sessionStore == nil {
+		return errors.New("session store not configured")
+	}
+
+	if err := a.sessionStore.Revoke(sessionID); err != nil {
+		return errors.Wrap(err, "revoking session")
+	}
+
+	return nil
+}
+
+// MemoryRefreshStore is an in-memory RefreshStore. Sessions are removed once
+// they have expired, according to the clock of the Auth it is attached to.
+// It is safe for concurrent use.
+type MemoryRefreshStore struct {
+	mu          sync.Mutex
+	sessions    map[string]opaqueEntry
+	clock       func() time.Time
+	lastCleanup time.Time
+}
+
+// NewMemoryRefreshStore constructs an empty MemoryRefreshStore.
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{
+		sessions:    make(map[string]opaqueEntry),
+		clock:       time.Now,
+		lastCleanup: time.Now(),
+	}
+}
+
+// useClock implements the clockUser interface.
+func (m *MemoryRefreshStore) useClock(clock func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = clock
+	m.lastCleanup = clock()
+}
+
+// Put implements the RefreshStore interface.
+func (m *MemoryRefreshStore) Put(hash string, claims Claims, exp time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[hash] = opaqueEntry{claims: cloneClaims(claims), exp: exp}
+	m.cleanup()
+
+	return nil
+}
+
+// Take implements the RefreshStore interface.
+func (m *MemoryRefreshStore) Take(hash string) (Claims, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, exists := m.sessions[hash]
+	if !exists {
+		return Claims{}, ErrUnknownSession
+	}
+	delete(m.sessions, hash)
+
+	if m.clock().After(entry.exp) {
+		return Claims{}, ErrUnknownSession
+	}
+
+	return entry.claims, nil
+}
+
+// Delete implements the RefreshStore interface.
+func (m *MemoryRefreshStore) Delete(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, hash)
+
+	return nil
+}
+
+// cleanup removes the expired sessions. It runs at most once per
+// cleanupInterval and must be called with the mutex held.
+func (m *MemoryRefreshStore) cleanup() {
+	now := m.clock()
+	if now.Sub(m.lastCleanup) < cleanupInterval {
+		return
+	}
+
+	for hash, entry := range m.sessions {
+		if now.After(entry.exp) {
+			delete(m.sessions, hash)
+		}
+	}
+	m.lastCleanup = now
+}
+
+// MemorySessionStore is an in-memory SessionStore. Sessions are removed once
+// they have expired, according to the clock of the Auth it is attached to.
+// It is safe for concurrent use.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+	clock    func() time.Time
+}
+
+// NewMemorySessionStore constructs an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]Session),
+		clock:    time.Now,
+	}
+}
+
+// useClock implements the clockUser interface.
+func (m *MemorySessionStore) useClock(clock func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = clock
+}
+
+// Create implements the SessionStore interface.
+func (m *MemorySessionStore) Create(s Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[s.ID] = s
+
+	return nil
+}
+
+// Get implements the SessionStore interface.
+func (m *MemorySessionStore) Get(sessionID string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, exists := m.sessions[sessionID]
+	if !exists {
+		return Session{}, ErrUnknownSession
+	}
+
+	if m.clock().After(s.ExpiresAt) {
+		delete(m.sessions, sessionID)
+		return Session{}, ErrUnknownSession
+	}
+
+	return s, nil
+}
+
+// ListByUser implements the SessionStore interface. The sessions are ordered
+// from the oldest to the newest.
+func (m *MemorySessionStore) ListByUser(subject string) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock()
+
+	var sessions []Session
+	for id, s := range m.sessions {
+		if now.After(s.ExpiresAt) {
+			delete(m.sessions, id)
+			continue
+		}
+		if s.Subject == subject {
+			sessions = append(sessions, s)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+// Revoke implements the SessionStore interface.
+func (m *MemorySessionStore) Revoke(sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, sessionID)
+
+	return nil
+}
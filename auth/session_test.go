@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestRefreshSession(t *testing.T) {
+	tests := []struct {
+		name string
+		// refresh uses the refresh token of the first pair and returns the
+		// error of the last exchange.
+		refresh func(a *Auth, clock *testClock, first TokenPair) error
+		err     error
+	}{
+		{
+			name: "rotation",
+			refresh: func(a *Auth, clock *testClock, first TokenPair) error {
+				second, err := a.RefreshSession(first.RefreshToken)
+				if err != nil {
+					return err
+				}
+				_, err = a.RefreshSession(second.RefreshToken)
+				return err
+			},
+		},
+		{
+			name: "used twice",
+			refresh: func(a *Auth, clock *testClock, first TokenPair) error {
+				if _, err := a.RefreshSession(first.RefreshToken); err != nil {
+					return err
+				}
+				_, err := a.RefreshSession(first.RefreshToken)
+				return err
+			},
+			err: ErrUnknownSession,
+		},
+		{
+			name: "revoked",
+			refresh: func(a *Auth, clock *testClock, first TokenPair) error {
+				if err := a.RevokeRefreshToken(first.RefreshToken); err != nil {
+					return err
+				}
+				_, err := a.RefreshSession(first.RefreshToken)
+				return err
+			},
+			err: ErrUnknownSession,
+		},
+		{
+			name: "within ttl",
+			refresh: func(a *Auth, clock *testClock, first TokenPair) error {
+				clock.Advance(24 * time.Hour)
+				_, err := a.RefreshSession(first.RefreshToken)
+				return err
+			},
+		},
+		{
+			name: "expired",
+			refresh: func(a *Auth, clock *testClock, first TokenPair) error {
+				clock.Advance(24*time.Hour + time.Second)
+				_, err := a.RefreshSession(first.RefreshToken)
+				return err
+			},
+			err: ErrUnknownSession,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, WithClock(clock.Now), WithRefreshStore(NewMemoryRefreshStore()))
+
+			first, err := a.GenerateSession(newClaims("user", RoleUser), time.Minute, 24*time.Hour)
+			if err != nil {
+				t.Fatalf("generating session: %v", err)
+			}
+
+			claims, err := a.ValidateToken(first.AccessToken)
+			if err != nil {
+				t.Fatalf("access token was rejected: %v", err)
+			}
+			if claims.Subject != "user" || !claims.HasRole(RoleUser) {
+				t.Errorf("claims are %+v", claims)
+			}
+
+			err = tt.refresh(a, clock, first)
+			if tt.err == nil && err != nil {
+				t.Errorf("refresh failed: %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("err is %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDeviceSessions(t *testing.T) {
+	devices := []string{"phone", "laptop", "tablet"}
+
+	tests := []struct {
+		name    string
+		revoke  []string
+		advance time.Duration
+		listed  []string
+	}{
+		{name: "all devices", listed: devices},
+		{name: "revoke one", revoke: []string{"laptop"}, listed: []string{"phone", "tablet"}},
+		{name: "revoke all", revoke: devices},
+		{name: "expired", advance: 24*time.Hour + time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, WithClock(clock.Now), WithRefreshStore(NewMemoryRefreshStore()), WithSessionStore(NewMemorySessionStore()))
+
+			// The sessions of another user are never listed.
+			if _, err := a.GenerateDeviceSession(newClaims("other"), "desktop", time.Minute, 24*time.Hour); err != nil {
+				t.Fatalf("generating session: %v", err)
+			}
+
+			ids := make(map[string]string)
+			pairs := make(map[string]TokenPair)
+			for _, device := range devices {
+				clock.Advance(time.Second)
+
+				tp, err := a.GenerateDeviceSession(newClaims("user"), device, time.Minute, 24*time.Hour)
+				if err != nil {
+					t.Fatalf("generating session: %v", err)
+				}
+				claims, err := a.ValidateToken(tp.AccessToken)
+				if err != nil {
+					t.Fatalf("access token was rejected: %v", err)
+				}
+				if claims.SessionID == "" || claims.Family != "" {
+					t.Fatalf("session id is %q and family %q", claims.SessionID, claims.Family)
+				}
+				ids[device] = claims.SessionID
+				pairs[device] = tp
+			}
+
+			for _, device := range tt.revoke {
+				if err := a.RevokeSession(ids[device]); err != nil {
+					t.Fatalf("revoking session: %v", err)
+				}
+			}
+			clock.Advance(tt.advance)
+
+			sessions, err := a.ListSessions("user")
+			if err != nil {
+				t.Fatalf("listing sessions: %v", err)
+			}
+			if len(sessions) != len(tt.listed) {
+				t.Fatalf("listed %d sessions, want %d", len(sessions), len(tt.listed))
+			}
+			for i, s := range sessions {
+				if s.DeviceName != tt.listed[i] || s.ID != ids[s.DeviceName] || s.Subject != "user" {
+					t.Errorf("session %d is %+v, want device %s", i, s, tt.listed[i])
+				}
+			}
+
+			// Only the refresh tokens of the listed sessions still work.
+			for _, device := range devices {
+				listed := false
+				for _, d := range tt.listed {
+					listed = listed || d == device
+				}
+
+				_, err := a.RefreshSession(pairs[device].RefreshToken)
+				if listed && err != nil {
+					t.Errorf("refreshing %s failed: %v", device, err)
+				}
+				if !listed && !errors.Is(err, ErrUnknownSession) {
+					t.Errorf("refreshing %s returned %v, want %v", device, err, ErrUnknownSession)
+				}
+			}
+		})
+	}
+}
+
+func TestDeviceSessionKeepsFamily(t *testing.T) {
+	a := newTestAuth(t, WithRefreshStore(NewMemoryRefreshStore()), WithSessionStore(NewMemorySessionStore()))
+
+	claims := newClaims("user")
+	claims.Family = "family"
+	tp, err := a.GenerateDeviceSession(claims, "phone", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("generating session: %v", err)
+	}
+
+	claims, err = a.ValidateToken(tp.AccessToken)
+	if err != nil {
+		t.Fatalf("access token was rejected: %v", err)
+	}
+
+	// The session id has its own claim, so it can't clash with the family
+	// of refresh token rotation.
+	if claims.Family != "family" || claims.SessionID == "" || claims.SessionID == claims.Family {
+		t.Errorf("family is %q and session id %q", claims.Family, claims.SessionID)
+	}
+}
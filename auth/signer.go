@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"strings"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// Signer signs tokens on behalf of an Auth, so the private key can be kept
+// in a KMS or HSM rather than in process memory. Sign returns the raw JWS
+// signature of the signing input for the algorithm named by Alg. For ECDSA
+// that is the concatenation of r and s, not the DER encoding most KMS APIs
+// return, so an adapter must convert it.
+type Signer interface {
+	Sign(signingInput []byte) ([]byte, error)
+	Alg() string
+}
+
+// NewWithSigner creates an Auth that generates tokens using the signer and
+// validates them with the matching PEM encoded public key.
+func NewWithSigner(signer Signer, publicKey []byte, opts ...Option) (*Auth, error) {
+	method, err := signingMethod(signer.Alg())
+	if err != nil {
+		return nil, err
+	}
+
+	verifyKey, err := parsePublicKey(method, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	a := newAsymmetricAuth(method, nil, verifyKey, opts)
+	a.signer = signer
+
+	return a, nil
+}
+
+// localSigner is a Signer holding the private key in memory.
+type localSigner struct {
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+// NewLocalSigner returns a Signer using a PEM encoded private key held in
+// memory, for development and tests of code written against Signer.
+func NewLocalSigner(privateKey []byte, alg string) (Signer, error) {
+	method, err := signingMethod(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parsePrivateKey(method, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &localSigner{method: method, key: key}, nil
+}
+
+// Sign implements the Signer interface.
+func (s *localSigner) Sign(signingInput []byte) ([]byte, error) {
+	sig, err := s.method.Sign(string(signingInput), s.key)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.DecodeSegment(sig)
+}
+
+// Alg implements the Signer interface.
+func (s *localSigner) Alg() string {
+	return s.method.Alg()
+}
+
+// signWith signs the encoded header and payload of a token using the signer.
+func signWith(signer Signer, input string) (string, error) {
+	sig, err := signer.Sign([]byte(input))
+	if err != nil {
+		return "", errors.Wrap(err, "signing token")
+	}
+
+	return strings.Join([]string{input, jwt.EncodeSegment(sig)}, "."), nil
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fakeSigner is a Signer standing in for a KMS. It signs RS256 with a key
+// held in memory, records the signing inputs and can be made to fail or to
+// return a corrupt signature.
+type fakeSigner struct {
+	key     *rsa.PrivateKey
+	alg     string
+	err     error
+	corrupt bool
+	inputs  []string
+}
+
+// Sign implements the Signer interface.
+func (s *fakeSigner) Sign(signingInput []byte) ([]byte, error) {
+	s.inputs = append(s.inputs, string(signingInput))
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	digest := sha256.Sum256(signingInput)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	if s.corrupt {
+		sig[0] ^= 0xff
+	}
+	return sig, nil
+}
+
+// Alg implements the Signer interface.
+func (s *fakeSigner) Alg() string {
+	return s.alg
+}
+
+// newFakeSigner returns a fakeSigner for a new RSA key and the PEM encoded
+// public key.
+func newFakeSigner(t testing.TB) (*fakeSigner, []byte) {
+	t.Helper()
+
+	priv, _ := newRSAKeyPEM(t)
+	block, _ := pem.Decode(priv)
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("parsing private key: %v", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("encoding public key: %v", err)
+	}
+
+	return &fakeSigner{key: key, alg: "RS256"}, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestNewWithSigner(t *testing.T) {
+	errKMS := errors.New("kms unavailable")
+
+	tests := []struct {
+		name     string
+		setup    func(s *fakeSigner)
+		genErr   error
+		rejected bool
+	}{
+		{name: "valid", setup: func(s *fakeSigner) {}},
+		{name: "signer error", setup: func(s *fakeSigner) { s.err = errKMS }, genErr: errKMS},
+		{name: "corrupt signature", setup: func(s *fakeSigner) { s.corrupt = true }, rejected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, pub := newFakeSigner(t)
+			tt.setup(signer)
+
+			a, err := NewWithSigner(signer, pub, WithClock(func() time.Time { return testNow }))
+			if err != nil {
+				t.Fatalf("constructing auth: %v", err)
+			}
+
+			tokenStr, err := a.GenerateToken(newClaims("user"))
+			if tt.genErr != nil {
+				if !errors.Is(err, tt.genErr) {
+					t.Fatalf("error is %v, want %v", err, tt.genErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("generating token: %v", err)
+			}
+
+			if len(signer.inputs) != 1 {
+				t.Fatalf("signer was called %d times, want 1", len(signer.inputs))
+			}
+			if want := tokenStr[:len(signer.inputs[0])]; signer.inputs[0] != want {
+				t.Errorf("signing input is %q, want %q", signer.inputs[0], want)
+			}
+
+			_, err = a.ValidateToken(tokenStr)
+			if tt.rejected && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("error is %v, want %v", err, ErrInvalidSignature)
+			}
+			if !tt.rejected && err != nil {
+				t.Errorf("validating token: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewWithSignerErrors(t *testing.T) {
+	signer, pub := newFakeSigner(t)
+	_, otherPub := newRSAKeyPEM(t)
+
+	tests := []struct {
+		name string
+		alg  string
+		pub  []byte
+	}{
+		{name: "none", alg: "none", pub: pub},
+		{name: "hmac", alg: "HS256", pub: pub},
+		{name: "wrong key type", alg: "ES256", pub: pub},
+		{name: "malformed key", alg: "RS256", pub: []byte("not a key")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := *signer
+			s.alg = tt.alg
+			if _, err := NewWithSigner(&s, tt.pub); err == nil {
+				t.Error("auth was constructed")
+			}
+		})
+	}
+
+	// A public key that doesn't match the signer constructs, but its tokens
+	// are rejected.
+	a, err := NewWithSigner(signer, otherPub, WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+	if _, err := a.ValidateToken(mustGenerate(t, a, newClaims("user"))); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("error is %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestNewLocalSigner(t *testing.T) {
+	priv, pub := newRSAKeyPEM(t)
+
+	signer, err := NewLocalSigner(priv, "RS256")
+	if err != nil {
+		t.Fatalf("constructing signer: %v", err)
+	}
+
+	a, err := NewWithSigner(signer, pub, WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+	if _, err := a.ValidateToken(mustGenerate(t, a, newClaims("user"))); err != nil {
+		t.Errorf("validating token: %v", err)
+	}
+
+	if _, err := NewLocalSigner(priv, "HS256"); err == nil {
+		t.Error("local signer for HS256 was constructed")
+	}
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrTokenTooLarge is returned by GenerateToken for tokens longer than the
+// limit set with WithMaxTokenBytes.
+var ErrTokenTooLarge = errors.New("token too large")
+
+// WithMaxTokenBytes makes GenerateToken fail with ErrTokenTooLarge instead of
+// issuing a token longer than n bytes, for proxies that drop requests with
+// large headers. Remember the header also carries the "Bearer " scheme.
+func WithMaxTokenBytes(n int) Option {
+	return func(a *Auth) {
+		a.maxTokenBytes = n
+	}
+}
+
+// EstimateTokenSize returns the length in bytes of the token GenerateToken
+// would issue for the claims. The token is signed and measured but never
+// returned. The length can vary by a few bytes between calls, for example
+// when the claims leave the jti to be generated.
+func (a *Auth) EstimateTokenSize(claims Claims) (int, error) {
+	str, err := a.generate(claims)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(str), nil
+}
+
+// checkTokenSize enforces the limit set with WithMaxTokenBytes.
+func (a *Auth) checkTokenSize(str string) error {
+	if a.maxTokenBytes > 0 && len(str) > a.maxTokenBytes {
+		return errors.Wrapf(ErrTokenTooLarge, "%d bytes exceeds the limit of %d", len(str), a.maxTokenBytes)
+	}
+	return nil
+}
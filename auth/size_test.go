@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestEstimateTokenSize(t *testing.T) {
+	a := newTestAuth(t)
+
+	tests := []struct {
+		name   string
+		claims Claims
+	}{
+		{name: "few roles", claims: newClaims("user", RoleUser)},
+		{name: "many roles", claims: newClaims("user", manyRoles(50)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A fixed jti keeps the length of the token stable.
+			claims := tt.claims
+			claims.Id = "fixed-token-id"
+
+			size, err := a.EstimateTokenSize(claims)
+			if err != nil {
+				t.Fatalf("estimating size: %v", err)
+			}
+			if got := len(mustGenerate(t, a, claims)); size != got {
+				t.Errorf("estimate is %d bytes, token is %d", size, got)
+			}
+		})
+	}
+
+	claims := newClaims("user")
+	claims.ExpiresAt = 0
+	if _, err := a.EstimateTokenSize(claims); !errors.Is(err, ErrNoExpiry) {
+		t.Errorf("error is %v, want %v", err, ErrNoExpiry)
+	}
+}
+
+func TestMaxTokenBytes(t *testing.T) {
+	small := newClaims("user", RoleUser)
+	large := newClaims("user", manyRoles(50)...)
+
+	size, err := newTestAuth(t).EstimateTokenSize(small)
+	if err != nil {
+		t.Fatalf("estimating size: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		max      int
+		compress bool
+		claims   Claims
+		err      error
+	}{
+		{name: "no limit", claims: large},
+		{name: "under limit", max: size + 64, claims: small},
+		{name: "over limit", max: size + 64, claims: large, err: ErrTokenTooLarge},
+		{name: "compressed under limit", max: 1024, compress: true, claims: large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithMaxTokenBytes(tt.max)}
+			if tt.compress {
+				opts = append(opts, WithCompression())
+			}
+			a := newTestAuth(t, opts...)
+
+			tokenStr, err := a.GenerateToken(tt.claims)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error is %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && tt.max > 0 && len(tokenStr) > tt.max {
+				t.Errorf("token is %d bytes, over the limit of %d", len(tokenStr), tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateAndMaybeRefresh validates the token like ValidateToken and, once
+// the token is past half of its lifetime, mints a replacement for the same
+// claims that expires ttl from now. This keeps a session alive as long as the
+// user is active. When no replacement is needed, newToken is empty and
+// refreshed is false. The lifetime is taken from the iat and exp of the
+// token, falling back to ttl for tokens without an iat.
+func (a *Auth) ValidateAndMaybeRefresh(tokenStr string, ttl time.Duration) (claims Claims, newToken string, refreshed bool, err error) {
+	claims, err = a.ValidateToken(tokenStr)
+	if err != nil {
+		return Claims{}, "", false, err
+	}
+
+	if claims.ExpiresAt == 0 {
+		return claims, "", false, nil
+	}
+
+	exp := time.Unix(claims.ExpiresAt, 0)
+	lifetime := ttl
+	if claims.IssuedAt != 0 {
+		lifetime = exp.Sub(time.Unix(claims.IssuedAt, 0))
+	}
+
+	if exp.Sub(a.clock()) > lifetime/2 {
+		return claims, "", false, nil
+	}
+
+	// The replacement is a new token so it gets its own id.
+	next := claims
+	next.Id = ""
+
+	newToken, err = a.GenerateTokenWithTTL(next, ttl)
+	if err != nil {
+		return Claims{}, "", false, errors.Wrap(err, "refreshing token")
+	}
+
+	return claims, newToken, true, nil
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestValidateAndMaybeRefresh(t *testing.T) {
+	tests := []struct {
+		name      string
+		advance   time.Duration
+		refreshed bool
+		err       error
+	}{
+		{name: "fresh", refreshed: false},
+		{name: "outside the window", advance: 29 * time.Minute, refreshed: false},
+		{name: "half of the lifetime", advance: 30 * time.Minute, refreshed: true},
+		{name: "inside the window", advance: 50 * time.Minute, refreshed: true},
+		{name: "expired", advance: 2 * time.Hour, err: ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, WithClock(clock.Now))
+
+			tokenStr := mustGenerate(t, a, newClaims("user", RoleUser))
+			old, err := ParseUnverified(tokenStr)
+			if err != nil {
+				t.Fatalf("parsing token: %v", err)
+			}
+			clock.Advance(tt.advance)
+
+			claims, newToken, refreshed, err := a.ValidateAndMaybeRefresh(tokenStr, 2*time.Hour)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err is %v, want %v", err, tt.err)
+				}
+				if newToken != "" || refreshed {
+					t.Errorf("rejected token was refreshed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validating token: %v", err)
+			}
+			if claims.Id != old.Id {
+				t.Errorf("claims are of token %q, want %q", claims.Id, old.Id)
+			}
+
+			if refreshed != tt.refreshed {
+				t.Fatalf("refreshed is %v, want %v", refreshed, tt.refreshed)
+			}
+			if !refreshed {
+				if newToken != "" {
+					t.Errorf("new token is %q, want none", newToken)
+				}
+				return
+			}
+
+			next, err := a.ValidateToken(newToken)
+			if err != nil {
+				t.Fatalf("validating new token: %v", err)
+			}
+			if next.Subject != "user" || !next.HasRole(RoleUser) {
+				t.Errorf("new claims are %+v, want the user with the USER role", next)
+			}
+			if next.Id == "" || next.Id == old.Id {
+				t.Errorf("new token id is %q, want a new one", next.Id)
+			}
+			if want := clock.Now().Add(2 * time.Hour).Unix(); next.ExpiresAt != want {
+				t.Errorf("exp is %v, want %v", time.Unix(next.ExpiresAt, 0).UTC(), time.Unix(want, 0).UTC())
+			}
+		})
+	}
+}
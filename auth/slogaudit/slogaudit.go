@@ -0,0 +1,64 @@
+//go:build go1.21
+
+// Package slogaudit provides an auth.AuditSink logging with log/slog. It
+// lives in its own package since log/slog needs Go 1.21.
+package slogaudit
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mromero1591/web-foundation/auth"
+)
+
+// Sink logs every audit event as a structured record. Successful decisions
+// are logged at the info level and failures at the warn level. Records are
+// written synchronously by the handler of the logger.
+type Sink struct {
+	logger *slog.Logger
+}
+
+// New constructs a Sink logging to logger, or to slog.Default when nil.
+func New(logger *slog.Logger) *Sink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Sink{logger: logger}
+}
+
+// OnIssue implements the auth.AuditSink interface.
+func (s *Sink) OnIssue(e auth.AuditEvent) {
+	s.log("token issued", e)
+}
+
+// OnValidate implements the auth.AuditSink interface.
+func (s *Sink) OnValidate(e auth.AuditEvent) {
+	s.log("token validated", e)
+}
+
+// log writes the record for the event.
+func (s *Sink) log(msg string, e auth.AuditEvent) {
+	level := slog.LevelInfo
+	if e.Outcome != auth.AuditOutcomeSuccess {
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.Time("event_time", e.Time),
+		slog.String("outcome", e.Outcome),
+	}
+	for _, a := range []struct{ key, value string }{
+		{"reason", e.Reason},
+		{"jti", e.TokenID},
+		{"sub", e.Subject},
+		{"typ", e.TokenType},
+		{"username", e.UserName},
+		{"email", e.Email},
+	} {
+		if a.value != "" {
+			attrs = append(attrs, slog.String(a.key, a.value))
+		}
+	}
+
+	s.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
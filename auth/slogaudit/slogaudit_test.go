@@ -0,0 +1,81 @@
+//go:build go1.21
+
+package slogaudit
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mromero1591/web-foundation/auth"
+)
+
+func TestSink(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		log   func(s *Sink, e auth.AuditEvent)
+		event auth.AuditEvent
+		want  map[string]any
+	}{
+		{
+			name:  "issued",
+			log:   (*Sink).OnIssue,
+			event: auth.AuditEvent{Time: now, Outcome: auth.AuditOutcomeSuccess, TokenID: "id", Subject: "user"},
+			want: map[string]any{
+				"level":      "INFO",
+				"msg":        "token issued",
+				"event_time": now.Format(time.RFC3339),
+				"outcome":    "success",
+				"jti":        "id",
+				"sub":        "user",
+			},
+		},
+		{
+			name:  "rejected",
+			log:   (*Sink).OnValidate,
+			event: auth.AuditEvent{Time: now, Outcome: auth.AuditOutcomeFailure, Reason: "token_expired", Subject: "user", UserName: "jdoe"},
+			want: map[string]any{
+				"level":      "WARN",
+				"msg":        "token validated",
+				"event_time": now.Format(time.RFC3339),
+				"outcome":    "failure",
+				"reason":     "token_expired",
+				"sub":        "user",
+				"username":   "jdoe",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey && len(groups) == 0 {
+						return slog.Attr{}
+					}
+					return a
+				},
+			}))
+
+			tt.log(New(logger), tt.event)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("decoding record %q: %v", buf.String(), err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("record is %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s is %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// WithStrictClaims rejects tokens whose claims don't have the expected JSON
+// types, like roles given as a string instead of an array of strings, with
+// an error naming the claim. By default such claims are decoded leniently.
+func WithStrictClaims() Option {
+	return func(a *Auth) {
+		a.strictClaims = true
+	}
+}
+
+// strictClaimTypes are the claims checked by WithStrictClaims and the JSON
+// type each must have.
+var strictClaimTypes = []struct {
+	name string
+	kind string
+}{
+	{"sub", "string"},
+	{"iss", "string"},
+	{"jti", "string"},
+	{"name", "string"},
+	{"username", "string"},
+	{"scope", "string"},
+	{"typ", "string"},
+	{"roles", "array of strings"},
+	{"amr", "array of strings"},
+	{"exp", "number"},
+	{"nbf", "number"},
+	{"iat", "number"},
+}
+
+// checkClaimTypes returns an error wrapping ErrTokenMalformed for the first
+// claim in the decoded payload that doesn't have its expected type. Claims
+// that are missing or null are accepted.
+func checkClaimTypes(payload []byte) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return errors.Wrap(ErrTokenMalformed, "decoding payload")
+	}
+
+	for _, c := range strictClaimTypes {
+		v, exists := m[c.name]
+		if !exists || v == nil {
+			continue
+		}
+
+		if got := jsonKind(v); got != c.kind {
+			return errors.Wrapf(ErrTokenMalformed, "claim %q must be %s, got %s", c.name, c.kind, got)
+		}
+	}
+
+	return nil
+}
+
+// jsonKind describes the JSON type of the decoded value.
+func jsonKind(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		for _, e := range v {
+			if _, ok := e.(string); !ok {
+				return fmt.Sprintf("array containing %s", jsonKind(e))
+			}
+		}
+		return "array of strings"
+	}
+	return "null"
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+// signPayload returns an HS256 token signed with testSecret for the JSON
+// payload, so tests can present claims GenerateToken would never write.
+func signPayload(t testing.TB, payload string) string {
+	t.Helper()
+
+	input := jwt.EncodeSegment([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + jwt.EncodeSegment([]byte(payload))
+	sig, err := jwt.SigningMethodHS256.Sign(input, []byte(testSecret))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+
+	return input + "." + sig
+}
+
+func TestStrictClaims(t *testing.T) {
+	exp := testNow.Add(time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		payload string
+		claim   string
+	}{
+		{name: "valid", payload: `{"sub":"user","roles":["USER"],"amr":["pwd"]}`},
+		{name: "null claims", payload: `{"sub":"user","roles":null,"name":null}`},
+		{name: "missing claims", payload: `{"sub":"user"}`},
+		{name: "extra claims", payload: `{"sub":"user","tenant":5}`},
+		{name: "roles string", payload: `{"sub":"user","roles":"ADMIN"}`, claim: "roles"},
+		{name: "roles mixed", payload: `{"sub":"user","roles":["USER",1]}`, claim: "roles"},
+		{name: "roles object", payload: `{"sub":"user","roles":{"ADMIN":true}}`, claim: "roles"},
+		{name: "subject number", payload: `{"sub":42}`, claim: "sub"},
+		{name: "amr string", payload: `{"sub":"user","amr":"pwd"}`, claim: "amr"},
+		{name: "iat string", payload: `{"sub":"user","iat":"now"}`, claim: "iat"},
+		{name: "scope array", payload: `{"sub":"user","scope":["read"]}`, claim: "scope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t, WithStrictClaims())
+
+			payload := strings.TrimSuffix(tt.payload, "}") + fmt.Sprintf(`,"exp":%d}`, exp)
+			_, err := a.ValidateToken(signPayload(t, payload))
+
+			if tt.claim == "" {
+				if err != nil {
+					t.Fatalf("validating token: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrTokenMalformed) {
+				t.Fatalf("error is %v, want %v", err, ErrTokenMalformed)
+			}
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("error is %T, want *ValidationError", err)
+			}
+			if want := fmt.Sprintf("claim %q", tt.claim); !strings.Contains(ve.Unredacted().Error(), want) {
+				t.Errorf("error %q doesn't name the %s claim", ve.Unredacted(), tt.claim)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package auth
+
+import "github.com/pkg/errors"
+
+// ErrTenantMismatch is returned by ValidateTokenForTenant, wrapped in a
+// *ValidationError, when the token was issued for a different tenant.
+var ErrTenantMismatch = errors.New("token issued for a different tenant")
+
+// ValidateTokenForTenant validates the token like ValidateToken and also
+// requires its tenant (tid) to match the expected tenant. This prevents a
+// valid token for one tenant being replayed against another tenant.
+func (a *Auth) ValidateTokenForTenant(tokenStr string, tenant string) (Claims, error) {
+	claims, err := a.ValidateToken(tokenStr)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if tenant == "" || claims.TenantID != tenant {
+		return Claims{}, newValidationError(ErrTenantMismatch)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,18 @@
+package auth
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans for token operations.
+var tracer = otel.Tracer("github.com/mromero1591/web-foundation/auth")
+
+// recordError marks the span as failed. Validation errors are recorded in
+// their redacted form so the span doesn't carry values from the token.
+func recordError(span trace.Span, err error) {
+	err = redact(err)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
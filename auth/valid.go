@@ -0,0 +1,39 @@
+package auth
+
+// claimsValidation carries the configuration of an Auth into Claims.Valid.
+type claimsValidation struct {
+	a *Auth
+}
+
+// validate checks the issuer and audience of the claims.
+func (v *claimsValidation) validate(c Claims) error {
+	if err := v.a.validateIssuer(c.Issuer); err != nil {
+		return err
+	}
+
+	if v.a.audience != "" && !c.Audience.Contains(v.a.audience) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+// Valid implements the jwt.Claims interface, so the parser validates the
+// claims while decoding them.
+//
+// When an Auth parses a token it attaches its configuration to the Claims it
+// decodes into, and Valid checks the issuer against WithIssuer or
+// WithTrustedIssuers and the audience against WithAudience. The expiration,
+// not before and issued at times are then checked by the Auth itself, which
+// honors WithClock and WithLeeway and lets ClaimsFromExpired accept expired
+// tokens. The configuration is dropped before the Claims are returned.
+//
+// Claims without an Auth configuration, like when they are passed to
+// jwt.ParseWithClaims directly, are validated by jwt.StandardClaims.Valid.
+func (c Claims) Valid() error {
+	if c.validation == nil {
+		return c.StandardClaims.Valid()
+	}
+
+	return c.validation.validate(c)
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// These are the errors returned by ValidateToken. They are wrapped with
+// context, so use errors.Is to match them.
+var (
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	ErrInvalidSignature = errors.New("token signature is invalid")
+	ErrTokenMalformed   = errors.New("token is malformed")
+	ErrInvalidIssuer    = errors.New("token has invalid issuer")
+	ErrInvalidAudience  = errors.New("token has invalid audience")
+)
+
+// ValidateFunc performs custom validation of claims that passed the
+// standard validation. Returning a non-nil error rejects the token.
+type ValidateFunc func(claims Claims) error
+
+// WithClaimsValidator registers a ValidateFunc that ValidateToken invokes
+// after the standard validation succeeds. Validators run in the order they
+// are registered and stop at the first failure.
+func WithClaimsValidator(fn ValidateFunc) Option {
+	return func(a *Auth) {
+		a.validators = append(a.validators, fn)
+	}
+}
+
+// ClaimsFromExpired returns the claims of a token that is valid apart from
+// having expired, so the caller can decide whether to refresh it. The
+// signature and every other claim are still verified.
+func (a *Auth) ClaimsFromExpired(tokenStr string) (Claims, error) {
+	claims, _, err := a.parse(context.Background(), tokenStr)
+	if err != nil {
+		return Claims{}, newValidationError(err)
+	}
+
+	if err := a.validateClaims(claims); err != nil && !errors.Is(err, ErrTokenExpired) {
+		return Claims{}, newValidationError(errors.Wrap(err, "validating claims"))
+	}
+
+	if err := a.checkPolicies(claims); err != nil {
+		return Claims{}, newValidationError(err)
+	}
+
+	return claims, nil
+}
+
+// ValidateTokenWithGrace is ValidateToken but also accepts access tokens that
+// expired within the grace period, for endpoints where a recently expired
+// token is harmless, like idempotent reads during a refresh race. The expired
+// result tells the caller to nudge the client to refresh. Unlike the leeway,
+// the grace period only applies to the expiration and only for this call.
+func (a *Auth) ValidateTokenWithGrace(tokenStr string, grace time.Duration) (claims Claims, expired bool, err error) {
+	claims, err = a.ClaimsFromExpired(tokenStr)
+	if err != nil {
+		return Claims{}, false, err
+	}
+
+	if claims.TokenType != "" && claims.TokenType != TokenTypeAccess {
+		return Claims{}, false, newValidationError(errors.Wrapf(ErrInvalidTokenType, "expected %s token", TokenTypeAccess))
+	}
+
+	if claims.ExpiresAt != 0 {
+		exp := time.Unix(claims.ExpiresAt, 0).Add(a.leeway)
+		now := a.clock()
+		if now.After(exp.Add(grace)) {
+			return Claims{}, false, newValidationError(errors.Wrap(ErrTokenExpired, "validating claims"))
+		}
+		expired = now.After(exp)
+	}
+
+	return claims, expired, nil
+}
+
+// checkPolicies runs the checks that apply once the token is known to be
+// authentic: revocation, per-subject invalidation and the custom validators.
+func (a *Auth) checkPolicies(claims Claims) error {
+	if err := a.checkRevoked(claims); err != nil {
+		return err
+	}
+
+	if err := a.checkMinIAT(claims); err != nil {
+		return err
+	}
+
+	return a.runValidators(claims)
+}
+
+// runValidators runs the registered validators in order.
+func (a *Auth) runValidators(claims Claims) error {
+	for _, fn := range a.validators {
+		if err := fn(claims); err != nil {
+			return errors.Wrap(err, "custom claims validation")
+		}
+	}
+	return nil
+}
+
+// fillRegisteredClaims sets the registered claims that were left empty:
+//
+//   - IssuedAt is set to now when it is zero.
+//   - NotBefore is set to IssuedAt when it is zero.
+//   - Subject is set to UserName when it is empty.
+//
+// Values already present in the claims are never changed.
+func fillRegisteredClaims(claims *Claims, now time.Time) {
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = now.Unix()
+	}
+	if claims.NotBefore == 0 {
+		claims.NotBefore = claims.IssuedAt
+	}
+	if claims.Subject == "" {
+		claims.Subject = claims.UserName
+	}
+}
+
+// backdateClaims moves the iat and nbf that aren't in the future to skew
+// before now.
+func backdateClaims(claims *Claims, now time.Time, skew time.Duration) {
+	backdated := now.Add(-skew).Unix()
+	if claims.IssuedAt > backdated && claims.IssuedAt <= now.Unix() {
+		claims.IssuedAt = backdated
+	}
+	if claims.NotBefore > backdated && claims.NotBefore <= now.Unix() {
+		claims.NotBefore = backdated
+	}
+}
+
+// validateClaims checks the times of the registered claims against the
+// configured clock and leeway. The issuer and audience are checked by the
+// parser, see Claims.Valid.
+func (a *Auth) validateClaims(claims Claims) error {
+	now := a.clock()
+
+	// A token issued in the future is treated as not valid yet.
+	if claims.IssuedAt != 0 {
+		iat := time.Unix(claims.IssuedAt, 0)
+		if now.Add(a.leeway).Before(iat) {
+			return ErrTokenNotYetValid
+		}
+	}
+
+	if claims.NotBefore != 0 {
+		nbf := time.Unix(claims.NotBefore, 0)
+		if now.Add(a.leeway).Before(nbf) {
+			return ErrTokenNotYetValid
+		}
+	}
+
+	// The expiration is checked last so ClaimsFromExpired can ignore it
+	// knowing every other check passed.
+	if claims.ExpiresAt == 0 {
+		return a.checkNoExpiry(claims)
+	}
+
+	if err := a.checkLifetime(claims.ExpiresAt, now); err != nil {
+		return err
+	}
+	exp := time.Unix(claims.ExpiresAt, 0)
+	if now.After(exp.Add(a.leeway)) {
+		return ErrTokenExpired
+	}
+
+	return nil
+}
+
+// validateIssuer checks the issuer against the trusted issuers, or the
+// configured issuer when no trusted issuers are set. When neither is set any
+// issuer is accepted. The error names the unexpected issuer.
+func (a *Auth) validateIssuer(iss string) error {
+	trusted := a.trustedIssuers
+	if len(trusted) == 0 && a.issuer != "" {
+		trusted = []string{a.issuer}
+	}
+
+	if len(trusted) == 0 {
+		return nil
+	}
+
+	for _, t := range trusted {
+		if iss == t {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(ErrInvalidIssuer, "unexpected issuer %q", iss)
+}
+
+// ParseUnverified decodes the claims of the token WITHOUT verifying its
+// signature or validating any claim. It exists to help diagnose rejected
+// tokens, for example to look at the iss, aud or exp of a token signed with
+// the wrong key. It is UNSAFE to use the result for authorization decisions.
+// An error is only returned when the token is malformed.
+func ParseUnverified(tokenStr string) (Claims, error) {
+	claims, _, err := parseUnverified(tokenStr)
+	return claims, err
+}
+
+// parseUnverified decodes the claims and header of the token without
+// verifying it, decompressing the payload when needed.
+func parseUnverified(tokenStr string) (Claims, map[string]interface{}, error) {
+	parts, header, err := splitToken(tokenStr)
+	if err != nil {
+		return Claims{}, nil, errors.Wrap(err, "parsing token")
+	}
+
+	payload, err := decodePayload(header, parts[1])
+	if err != nil {
+		return Claims{}, nil, errors.Wrap(err, "parsing token")
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, nil, errors.Wrap(ErrTokenMalformed, "parsing token: decoding claims")
+	}
+
+	return claims, header, nil
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestIssuanceSkew(t *testing.T) {
+	tests := []struct {
+		name    string
+		skew    time.Duration
+		nbf     time.Time
+		wantIat time.Time
+		wantNbf time.Time
+		behind  time.Duration
+		valid   bool
+	}{
+		{name: "no skew", wantIat: testNow, valid: true},
+		{name: "no skew receiver behind", wantIat: testNow, behind: time.Minute},
+		{name: "skew", skew: time.Minute, wantIat: testNow.Add(-time.Minute), valid: true},
+		{
+			name:    "skew covers receiver",
+			skew:    time.Minute,
+			wantIat: testNow.Add(-time.Minute),
+			behind:  time.Minute,
+			valid:   true,
+		},
+		{
+			name:    "skew short of receiver",
+			skew:    time.Minute,
+			wantIat: testNow.Add(-time.Minute),
+			behind:  2 * time.Minute,
+		},
+		{
+			name:    "past nbf within skew",
+			skew:    time.Minute,
+			nbf:     testNow.Add(-30 * time.Second),
+			wantIat: testNow.Add(-time.Minute),
+			wantNbf: testNow.Add(-time.Minute),
+			valid:   true,
+		},
+		{
+			name:    "past nbf beyond skew",
+			skew:    time.Minute,
+			nbf:     testNow.Add(-time.Hour),
+			wantIat: testNow.Add(-time.Minute),
+			wantNbf: testNow.Add(-time.Hour),
+			valid:   true,
+		},
+		{
+			name:    "scheduled nbf",
+			skew:    time.Minute,
+			nbf:     testNow.Add(10 * time.Minute),
+			wantIat: testNow.Add(-time.Minute),
+			wantNbf: testNow.Add(10 * time.Minute),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t, WithIssuanceSkew(tt.skew))
+
+			claims := newClaims("user")
+			claims.IssuedAt = 0
+			if !tt.nbf.IsZero() {
+				claims.NotBefore = tt.nbf.Unix()
+			}
+			tokenStr := mustGenerate(t, a, claims)
+
+			got, err := ParseUnverified(tokenStr)
+			if err != nil {
+				t.Fatalf("reading claims: %v", err)
+			}
+			if got.IssuedAt != tt.wantIat.Unix() {
+				t.Errorf("iat is %v, want %v", time.Unix(got.IssuedAt, 0).UTC(), tt.wantIat)
+			}
+			var wantNbf int64
+			if !tt.wantNbf.IsZero() {
+				wantNbf = tt.wantNbf.Unix()
+			}
+			if got.NotBefore != wantNbf {
+				t.Errorf("nbf is %v, want %v", time.Unix(got.NotBefore, 0).UTC(), tt.wantNbf)
+			}
+			if got.ExpiresAt != claims.ExpiresAt {
+				t.Errorf("exp is %v, want %v", time.Unix(got.ExpiresAt, 0).UTC(), time.Unix(claims.ExpiresAt, 0).UTC())
+			}
+
+			receiver := newTestAuth(t, WithClock(func() time.Time { return testNow.Add(-tt.behind) }))
+			_, err = receiver.ValidateToken(tokenStr)
+			if tt.valid && err != nil {
+				t.Errorf("receiver rejected the token: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("receiver accepted the token")
+			}
+		})
+	}
+}
+
+func TestValidateTokenWithGrace(t *testing.T) {
+	refresh := newClaims("user")
+	refresh.TokenType = TokenTypeRefresh
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		claims  Claims
+		advance time.Duration
+		expired bool
+		err     error
+	}{
+		{name: "valid", claims: newClaims("user")},
+		{name: "within the leeway", opts: []Option{WithLeeway(time.Minute)}, claims: newClaims("user"), advance: time.Hour + 30*time.Second},
+		{name: "within the grace period", claims: newClaims("user"), advance: time.Hour + 2*time.Minute, expired: true},
+		{name: "end of the grace period", claims: newClaims("user"), advance: time.Hour + 5*time.Minute, expired: true},
+		{name: "past the grace period", claims: newClaims("user"), advance: time.Hour + 6*time.Minute, err: ErrTokenExpired},
+		{name: "refresh token", claims: refresh, err: ErrInvalidTokenType},
+		{name: "expired refresh token", claims: refresh, advance: time.Hour + 2*time.Minute, err: ErrInvalidTokenType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, append([]Option{WithClock(clock.Now)}, tt.opts...)...)
+
+			tokenStr := mustGenerate(t, a, tt.claims)
+			clock.Advance(tt.advance)
+
+			claims, expired, err := a.ValidateTokenWithGrace(tokenStr, 5*time.Minute)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err is %v, want %v", err, tt.err)
+				}
+				var ve *ValidationError
+				if !errors.As(err, &ve) {
+					t.Errorf("error is %T, want *ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("token was rejected: %v", err)
+			}
+			if expired != tt.expired {
+				t.Errorf("expired is %v, want %v", expired, tt.expired)
+			}
+			if claims.Subject != "user" {
+				t.Errorf("subject is %q, want %q", claims.Subject, "user")
+			}
+		})
+	}
+}
+
+func TestClaimsFromExpired(t *testing.T) {
+	forger, err := New("another-secret-of-at-least-32-bytes", "HS256", WithClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	notYetValid := newClaims("user")
+	notYetValid.NotBefore = testNow.Add(3 * time.Hour).Unix()
+	notYetValid.ExpiresAt = testNow.Add(4 * time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		signer  *Auth
+		claims  Claims
+		advance time.Duration
+		err     error
+	}{
+		{name: "valid", claims: newClaims("user")},
+		{name: "expired", claims: newClaims("user"), advance: 2 * time.Hour},
+		{name: "long expired", claims: newClaims("user"), advance: 30 * 24 * time.Hour},
+		{name: "bad signature", signer: forger, claims: newClaims("user"), advance: 2 * time.Hour, err: ErrInvalidSignature},
+		{name: "not valid yet", claims: notYetValid, advance: 2 * time.Hour, err: ErrTokenNotYetValid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			a := newTestAuth(t, WithClock(clock.Now))
+
+			signer := tt.signer
+			if signer == nil {
+				signer = a
+			}
+			tokenStr := mustGenerate(t, signer, tt.claims)
+			clock.Advance(tt.advance)
+
+			claims, err := a.ClaimsFromExpired(tokenStr)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err is %v, want %v", err, tt.err)
+				}
+				if claims.Subject != "" {
+					t.Errorf("claims returned with the error: %+v", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("token was rejected: %v", err)
+			}
+			if claims.Subject != "user" {
+				t.Errorf("subject is %q, want %q", claims.Subject, "user")
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultWebSocketParam is the query parameter and subprotocol marker
+// AuthenticateWebSocket looks for unless configured with
+// WithWebSocketParam.
+const DefaultWebSocketParam = "access_token"
+
+// WithWebSocketParam sets the query parameter and subprotocol marker that
+// AuthenticateWebSocket reads the token from.
+func WithWebSocketParam(name string) Option {
+	return func(a *Auth) {
+		a.wsParam = name
+	}
+}
+
+// AuthenticateWebSocket validates the token of a WebSocket handshake before
+// the connection is upgraded. Browsers can't set headers on the handshake, so
+// the token is read from, in order:
+//
+//   - the query parameter, as in ?access_token=<token>
+//   - the Sec-WebSocket-Protocol header, as the protocol following the
+//     marker, as in "access_token, <token>"
+//   - the configured token extractor, for clients that can set headers
+//
+// It operates on the *http.Request only, so it works with any WebSocket
+// library, such as gorilla/websocket or nhooyr.io/websocket. When using the
+// subprotocol, the upgrader must respond with the marker, never the token.
+//
+// Tokens in query parameters end up in access logs, proxy logs and browser
+// history. Prefer the subprotocol, keep the tokens short lived, and make sure
+// query strings aren't logged for these endpoints.
+func (a *Auth) AuthenticateWebSocket(r *http.Request) (Claims, error) {
+	tokenStr, err := a.webSocketToken(r)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	return a.ValidateTokenContext(r.Context(), tokenStr)
+}
+
+// webSocketToken extracts the token from the handshake.
+func (a *Auth) webSocketToken(r *http.Request) (string, error) {
+	param := a.wsParam
+	if param == "" {
+		param = DefaultWebSocketParam
+	}
+
+	if token := r.URL.Query().Get(param); token != "" {
+		return token, nil
+	}
+
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	for i := 0; i < len(protocols)-1; i++ {
+		if protocols[i] == param && protocols[i+1] != "" {
+			return protocols[i+1], nil
+		}
+	}
+
+	return a.extractor(r)
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAuthenticateWebSocket(t *testing.T) {
+	a := newTestAuth(t)
+	custom := newTestAuth(t, WithWebSocketParam("token"))
+	tokenStr := mustGenerate(t, a, newClaims("user"))
+
+	tests := []struct {
+		name     string
+		auth     *Auth
+		target   string
+		protocol []string
+		header   string
+		err      error
+	}{
+		{name: "subprotocol", protocol: []string{"access_token, " + tokenStr}},
+		{name: "subprotocol among others", protocol: []string{"chat", "access_token, " + tokenStr + ", json"}},
+		{name: "query", target: "/ws?access_token=" + tokenStr},
+		{name: "header", header: "Bearer " + tokenStr},
+		{name: "custom param query", auth: custom, target: "/ws?token=" + tokenStr},
+		{name: "custom param subprotocol", auth: custom, protocol: []string{"token, " + tokenStr}},
+		{name: "default param with custom", auth: custom, target: "/ws?access_token=" + tokenStr, err: ErrMissingToken},
+		{name: "marker without token", protocol: []string{"access_token"}, err: ErrMissingToken},
+		{name: "rejected subprotocol", protocol: []string{"access_token, not-a-token"}, err: ErrTokenMalformed},
+		{name: "rejected query", target: "/ws?access_token=not-a-token", err: ErrTokenMalformed},
+		{name: "missing", err: ErrMissingToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if target == "" {
+				target = "/ws"
+			}
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			for _, p := range tt.protocol {
+				r.Header.Add("Sec-WebSocket-Protocol", p)
+			}
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+
+			auth := tt.auth
+			if auth == nil {
+				auth = a
+			}
+
+			claims, err := auth.AuthenticateWebSocket(r)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err is %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authenticating handshake: %v", err)
+			}
+			if claims.Subject != "user" {
+				t.Errorf("subject is %q, want %q", claims.Subject, "user")
+			}
+		})
+	}
+}